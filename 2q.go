@@ -2,7 +2,9 @@ package mcache
 
 import (
 	"fmt"
+	"io"
 	"sync"
+	"time"
 
 	"github.com/songangweb/mcache/simplelru"
 )
@@ -33,7 +35,12 @@ type TwoQueueCache struct {
 	recent      simplelru.LRUCache
 	frequent    simplelru.LRUCache
 	recentEvict simplelru.LRUCache
+	versions    uint64 // recent, frequent 共用的版本号计数器
+	onEvict     func(key interface{}, value interface{}, expirationTime int64, reason EvictionReason)
+	stats       *statsCounter
+	wal         *WAL
 	lock        sync.RWMutex
+	settings    settingsRecorder
 }
 
 // New2Q creates a new TwoQueueCache using the default
@@ -42,9 +49,32 @@ func New2Q(size int) (*TwoQueueCache, error) {
 	return New2QParams(size, Default2QRecentRatio, Default2QGhostEntries)
 }
 
+// New2QWithEvict creates a new TwoQueueCache using the default values for
+// the parameters and the given eviction callback. The callback is called
+// once for every value leaving the cache, moves between the internal lists
+// are not reported.
+// New2QWithEvict 使用默认参数构造2Q缓存, 缓存中的值被淘汰时回调, 内部列表之间的移动不会回调
+func New2QWithEvict(size int, onEvicted func(key interface{}, value interface{}, expirationTime int64)) (*TwoQueueCache, error) {
+	return New2QParamsWithEvictReason(size, Default2QRecentRatio, Default2QGhostEntries, reasonCallback(onEvicted))
+}
+
+// New2QWithEvictReason creates a new TwoQueueCache using the default values
+// for the parameters, whose eviction callback receives the eviction reason.
+// New2QWithEvictReason 使用默认参数构造2Q缓存, 淘汰回调中包含淘汰原因
+func New2QWithEvictReason(size int, onEvicted func(key interface{}, value interface{}, expirationTime int64, reason EvictionReason)) (*TwoQueueCache, error) {
+	return New2QParamsWithEvictReason(size, Default2QRecentRatio, Default2QGhostEntries, onEvicted)
+}
+
 // New2QParams creates a new TwoQueueCache using the provided
 // parameter values.
 func New2QParams(size int, recentRatio float64, ghostRatio float64) (*TwoQueueCache, error) {
+	return New2QParamsWithEvictReason(size, recentRatio, ghostRatio, nil)
+}
+
+// New2QParamsWithEvictReason creates a new TwoQueueCache using the provided
+// parameter values, whose eviction callback receives the eviction reason.
+// New2QParamsWithEvictReason 使用给定参数构造2Q缓存, 淘汰回调中包含淘汰原因
+func New2QParamsWithEvictReason(size int, recentRatio float64, ghostRatio float64, onEvicted func(key interface{}, value interface{}, expirationTime int64, reason EvictionReason)) (*TwoQueueCache, error) {
 	if size <= 0 {
 		return nil, fmt.Errorf("invalid size")
 	}
@@ -59,12 +89,21 @@ func New2QParams(size int, recentRatio float64, ghostRatio float64) (*TwoQueueCa
 	recentSize := int(float64(size) * recentRatio)
 	evictSize := int(float64(size) * ghostRatio)
 
+	stats := &statsCounter{}
+	c := &TwoQueueCache{
+		size:       size,
+		recentSize: recentSize,
+		onEvict:    stats.evicted(onEvicted),
+		stats:      stats,
+	}
+
 	// Allocate the LRUs
-	recent, err := simplelru.NewLRU(size, nil)
+	// recentEvict 只保存被淘汰的键, 不需要回调
+	recent, err := simplelru.NewLRUWithEvictReason(size, c.listEvicted)
 	if err != nil {
 		return nil, err
 	}
-	frequent, err := simplelru.NewLRU(size, nil)
+	frequent, err := simplelru.NewLRUWithEvictReason(size, c.listEvicted)
 	if err != nil {
 		return nil, err
 	}
@@ -74,42 +113,267 @@ func New2QParams(size int, recentRatio float64, ghostRatio float64) (*TwoQueueCa
 	}
 
 	// Initialize the cache
-	c := &TwoQueueCache{
-		size:        size,
-		recentSize:  recentSize,
-		recent:      recent,
-		frequent:    frequent,
-		recentEvict: recentEvict,
-	}
+	recent.ShareVersions(&c.versions)
+	frequent.ShareVersions(&c.versions)
+	c.recent = recent
+	c.frequent = frequent
+	c.recentEvict = recentEvict
 	return c, nil
 }
 
 // Get looks up a key's value from the cache.
+// Get 从缓存中查找一个键的值。
 func (c *TwoQueueCache) Get(key interface{}) (value interface{}, expirationTime int64, ok bool) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
+	e, ok := c.getEntry(key)
+	c.stats.get(key, ok)
+	return e.Value, e.ExpirationTime, ok
+}
+
+// GetEntry looks up a key's entry and metadata from the cache, updating
+// the cache state like Get.
+// GetEntry 从缓存中查找一个键的条目及元数据, 与 Get 一样更新缓存的状态
+func (c *TwoQueueCache) GetEntry(key interface{}) (e Entry, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e, ok = c.getEntry(key)
+	c.stats.get(key, ok)
+	return e, ok
+}
 
+// getEntry looks up a key's entry, promoting it from recent to frequent.
+// getEntry 查找一个键的条目, 命中 recent 时移动到 frequent
+func (c *TwoQueueCache) getEntry(key interface{}) (e Entry, ok bool) {
 	// Check if this is a frequent value
-	if val, expirationTime, ok := c.frequent.Get(key); ok {
-		return val, expirationTime, ok
+	if e, ok := c.frequent.GetEntry(key); ok {
+		return e, ok
 	}
 
 	// If the value is contained in recent, then we
 	// promote it to frequent
-	if val, expirationTime, ok := c.recent.Peek(key); ok {
+	if e, ok := c.recent.GetEntry(key); ok {
 		c.recent.Remove(key)
-		c.frequent.Add(key, val, expirationTime)
-		return val, expirationTime, ok
+		c.frequent.AddEntry(e)
+		return e, ok
 	}
 
 	// No hit
-	return nil, expirationTime, false
+	return e, false
+}
+
+// GetWithVersion looks up a key's value and version from the cache,
+// updating the cache state like Get.
+// GetWithVersion 从缓存中查找一个键的值及版本号, 与 Get 一样更新缓存的状态
+func (c *TwoQueueCache) GetWithVersion(key interface{}) (value interface{}, expirationTime int64, version uint64, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e, ok := c.getEntry(key)
+	c.stats.get(key, ok)
+	return e.Value, e.ExpirationTime, e.Version, ok
+}
+
+// CompareAndSwap replaces the value of a key in place, keeping its
+// expiration time, if its version is expectedVersion. Unlike Add it does
+// not move the key between the internal lists. Returns the current version
+// and whether the value was replaced.
+// CompareAndSwap 当键的版本号等于 expectedVersion 时原地替换其值并保留过期时间,
+// 与 Add 不同, 不会在内部列表之间移动。返回当前版本号及是否替换成功
+func (c *TwoQueueCache) CompareAndSwap(key interface{}, expectedVersion uint64, value interface{}) (version uint64, swapped bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	defer c.logKey(key)
+	return c.swap(key, expectedVersion, value, c.frequent.Contains(key))
+}
+
+// swap replaces the value of a key in frequent or recent in place if its
+// version is expectedVersion, the lock must be held.
+// swap 当键的版本号等于 expectedVersion 时原地替换其在 frequent 或 recent 中的值, 调用前需持有锁
+func (c *TwoQueueCache) swap(key interface{}, expectedVersion uint64, value interface{}, inFrequent bool) (version uint64, swapped bool) {
+	if inFrequent {
+		version, swapped = c.frequent.CompareAndSwap(key, expectedVersion, value)
+	} else {
+		version, swapped = c.recent.CompareAndSwap(key, expectedVersion, value)
+	}
+	if swapped {
+		c.stats.add(key)
+	}
+	return version, swapped
+}
+
+// CompareAndDelete removes a key if its version is expectedVersion.
+// CompareAndDelete 当键的版本号等于 expectedVersion 时移除该键
+func (c *TwoQueueCache) CompareAndDelete(key interface{}, expectedVersion uint64) (deleted bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	defer c.logKey(key)
+	if e, ok := c.frequent.PeekEntry(key); ok {
+		if e.Version != expectedVersion {
+			return false
+		}
+		c.frequent.Remove(key)
+		c.evicted(key, e.Value, e.ExpirationTime, ReasonRemoved)
+		return true
+	}
+	if e, ok := c.recent.PeekEntry(key); ok {
+		if e.Version != expectedVersion {
+			return false
+		}
+		c.recent.Remove(key)
+		c.evicted(key, e.Value, e.ExpirationTime, ReasonRemoved)
+		return true
+	}
+	return false
+}
+
+// Update replaces the value of a key in place by the result of fn, keeping
+// its expiration time, atomically under the cache lock. fn receives the
+// current value and whether the key exists, the key is removed when fn
+// returns keep false and new keys, which are added like Add, never expire.
+// Returns the value in the cache and whether the key is present. fn must
+// not use the cache.
+// Update 在缓存锁内原子地用 fn 的结果原地替换一个键的值并保留过期时间。fn 接收当前值及键是否存在,
+// 返回 keep 为false时移除该键, 新增的键与 Add 一样放入且永不过期。返回缓存中的值及键是否存在。fn 中不能访问缓存
+func (c *TwoQueueCache) Update(key interface{}, fn func(old interface{}, exists bool) (value interface{}, keep bool)) (value interface{}, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	defer c.logKey(key)
+	e, inFrequent, exists := c.peekEntry(key)
+	value, keep := fn(e.Value, exists)
+	switch {
+	case !keep:
+		if exists {
+			c.removeEntry(e, inFrequent)
+		}
+		return nil, false
+	case !exists:
+		c.add(key, value, 0)
+	default:
+		c.swap(key, e.Version, value, inFrequent)
+	}
+	return value, true
+}
+
+// Compute replaces the value of a key by the result of fn with the given
+// expiration time like Add, atomically under the cache lock. fn receives
+// the current value and whether the key exists, the key is removed when fn
+// returns keep false. Returns the value in the cache and whether the key
+// is present. fn must not use the cache.
+// Compute 在缓存锁内原子地用 fn 的结果及给定的过期时间与 Add 一样替换一个键的值。fn 接收当前值及键是否存在,
+// 返回 keep 为false时移除该键。返回缓存中的值及键是否存在。fn 中不能访问缓存
+func (c *TwoQueueCache) Compute(key interface{}, expirationTime int64, fn func(old interface{}, exists bool) (value interface{}, keep bool)) (value interface{}, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	defer c.logKey(key)
+	e, inFrequent, exists := c.peekEntry(key)
+	value, keep := fn(e.Value, exists)
+	if !keep {
+		if exists {
+			c.removeEntry(e, inFrequent)
+		}
+		return nil, false
+	}
+	c.add(key, value, expirationTime)
+	return value, true
+}
+
+// ComputeIfAbsent returns the value of a key like Get, or adds the result
+// of fn with the given expiration time like Add if the key is missing and
+// fn returns keep true, atomically under the cache lock. Returns the value
+// in the cache and whether the key is present. fn must not use the cache.
+// ComputeIfAbsent 在缓存锁内原子地执行: 与 Get 一样返回一个键的值, 键不存在时调用 fn,
+// 返回 keep 为true时以给定的过期时间与 Add 一样放入缓存。返回缓存中的值及键是否存在。fn 中不能访问缓存
+func (c *TwoQueueCache) ComputeIfAbsent(key interface{}, expirationTime int64, fn func() (value interface{}, keep bool)) (value interface{}, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.getEntry(key); ok {
+		return e.Value, true
+	}
+	value, keep := fn()
+	if !keep {
+		return nil, false
+	}
+	c.add(key, value, expirationTime)
+	c.logKey(key)
+	return value, true
+}
+
+// Incr adds delta to the counter of a key and returns the new value. A
+// missing key is created with the given expiration time, an existing one
+// keeps its expiration time. Returns ErrNotInteger if the value is not an
+// integer and ErrOverflow if the counter does not fit in an int64, counters
+// are stored as int64.
+// Incr 将一个键的计数加上 delta 并返回新值。键不存在时以给定的过期时间创建, 已存在时保留过期时间。
+// 值不是整数时返回 ErrNotInteger, 计数超出 int64 范围时返回 ErrOverflow, 计数以 int64 保存
+func (c *TwoQueueCache) Incr(key interface{}, delta int64, expirationTime int64) (value int64, err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	defer c.logKey(key)
+	e, inFrequent, ok := c.peekEntry(key)
+	if !ok {
+		c.add(key, delta, expirationTime)
+		return delta, nil
+	}
+	n, err := addDelta(e.Value, delta)
+	if err != nil {
+		return 0, err
+	}
+	c.swap(key, e.Version, n, inFrequent)
+	return n, nil
+}
+
+// Decr subtracts delta from the counter of a key, see Incr.
+// Decr 将一个键的计数减去 delta, 参见 Incr
+func (c *TwoQueueCache) Decr(key interface{}, delta int64, expirationTime int64) (value int64, err error) {
+	return c.Incr(key, -delta, expirationTime)
+}
+
+// peekEntry returns the entry of a key and whether it is in frequent.
+// peekEntry 返回一个键的条目及其是否在 frequent 中
+func (c *TwoQueueCache) peekEntry(key interface{}) (e Entry, inFrequent bool, ok bool) {
+	if e, ok := c.frequent.PeekEntry(key); ok {
+		return e, true, true
+	}
+	e, ok = c.recent.PeekEntry(key)
+	return e, false, ok
+}
+
+// removeEntry removes an entry from frequent or recent and reports it.
+// removeEntry 从 frequent 或 recent 中移除一个条目并回调
+func (c *TwoQueueCache) removeEntry(e Entry, inFrequent bool) {
+	if inFrequent {
+		c.frequent.Remove(e.Key)
+	} else {
+		c.recent.Remove(e.Key)
+	}
+	c.evicted(e.Key, e.Value, e.ExpirationTime, ReasonRemoved)
 }
 
 // Add adds a value to the cache.
 func (c *TwoQueueCache) Add(key, value interface{}, expirationTime int64,) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
+	defer c.logKey(key)
+	c.add(key, value, expirationTime)
+}
+
+// AddSliding adds a value which expires after being idle for the given
+// duration, every Get extends its expiration time.
+// AddSliding 向缓存添加一个滑动过期的值, 空闲 idle 时长后过期, 每次 Get 都会延长过期时间
+func (c *TwoQueueCache) AddSliding(key, value interface{}, idle time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	defer c.logKey(key)
+	c.add(key, value, 0)
+	if !c.frequent.ExpireSliding(key, idle) {
+		c.recent.ExpireSliding(key, idle)
+	}
+}
+
+// add adds a value to the cache, the lock must be held.
+// add 向缓存添加一个值, 调用前需持有锁
+func (c *TwoQueueCache) add(key, value interface{}, expirationTime int64) {
+	c.stats.add(key)
 
 	// Check if the value is frequently used already,
 	// and just update the value
@@ -120,9 +384,16 @@ func (c *TwoQueueCache) Add(key, value interface{}, expirationTime int64,) {
 
 	// Check if the value is recently used, and promote
 	// the value into the frequent list
-	if c.recent.Contains(key) {
+	if old, ok := c.recent.PeekEntry(key); ok {
 		c.recent.Remove(key)
-		c.frequent.Add(key, value, expirationTime)
+		e := old
+		e.Value, e.ExpirationTime = value, expirationTime
+		e.Lifetime, e.Sliding = 0, false
+		touchEntry(&e)
+		// 值已改变, 从共用的计数器分配新的版本号使之前的版本失效
+		e.Version = 0
+		c.frequent.AddEntry(e)
+		c.evicted(key, old.Value, old.ExpirationTime, ReasonReplaced)
 		return
 	}
 
@@ -153,13 +424,36 @@ func (c *TwoQueueCache) ensureSpace(recentEvict bool) {
 	// If the recent buffer is larger than
 	// the target, evict from there
 	if recentLen > 0 && (recentLen > c.recentSize || (recentLen == c.recentSize && !recentEvict)) {
-		k, _, _, _ := c.recent.RemoveOldest()
-		c.recentEvict.Add(k, nil, 0)
+		k, v, expirationTime, ok := c.recent.RemoveOldest()
+		if ok {
+			c.recentEvict.Add(k, nil, 0)
+			c.evicted(k, v, expirationTime, ReasonCapacity)
+		}
 		return
 	}
 
 	// Remove from the frequent list otherwise
-	c.frequent.RemoveOldest()
+	if k, v, expirationTime, ok := c.frequent.RemoveOldest(); ok {
+		c.evicted(k, v, expirationTime, ReasonCapacity)
+	}
+}
+
+// listEvicted receives the evictions of the recent and frequent lists.
+// Entries removed by the cache itself are moved between lists or reported
+// by the cache.
+// listEvicted 接收 recent, frequent 的淘汰回调, 主动移除的条目在列表之间移动或由缓存自行回调
+func (c *TwoQueueCache) listEvicted(key interface{}, value interface{}, expirationTime int64, reason EvictionReason) {
+	if reason != ReasonRemoved {
+		c.evicted(key, value, expirationTime, reason)
+	}
+}
+
+// evicted calls the eviction callback if there is one.
+// evicted 调用淘汰回调
+func (c *TwoQueueCache) evicted(key interface{}, value interface{}, expirationTime int64, reason EvictionReason) {
+	if c.onEvict != nil {
+		c.onEvict(key, value, expirationTime, reason)
+	}
 }
 
 // Len returns the number of items in the cache.
@@ -169,8 +463,22 @@ func (c *TwoQueueCache) Len() int {
 	return c.recent.Len() + c.frequent.Len()
 }
 
+// Cap returns the capacity of the cache.
+// Cap 返回缓存的容量
+func (c *TwoQueueCache) Cap() int {
+	return c.size
+}
+
+// Settings returns the configuration of the cache.
+// Settings 返回缓存的配置
+func (c *TwoQueueCache) Settings() Settings {
+	return c.settings.get(c.Cap(), 1)
+}
+
 // Keys returns a slice of the keys in the cache.
-// The frequently used keys are first in the returned slice.
+// The frequently used keys are first in the returned slice. Each list is
+// from oldest to newest, the result as a whole is not in eviction order.
+// Keys 返回缓存中键的切片, 频繁使用的键在前, 各列表从最老到最新, 整体不是淘汰顺序
 func (c *TwoQueueCache) Keys() []interface{} {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
@@ -179,25 +487,48 @@ func (c *TwoQueueCache) Keys() []interface{} {
 	return append(k1, k2...)
 }
 
+// FirstKeys returns up to n keys in the order of Keys, without listing the
+// others.
+// FirstKeys 按 Keys 的顺序返回至多 n 个键, 不列出其余的键
+func (c *TwoQueueCache) FirstKeys(n int) []interface{} {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	keys := c.frequent.FirstKeys(n)
+	return append(keys, c.recent.FirstKeys(n-len(keys))...)
+}
+
 // Remove removes the provided key from the cache.
 func (c *TwoQueueCache) Remove(key interface{}) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
-	if c.frequent.Remove(key) {
-		return
-	}
-	if c.recent.Remove(key) {
-		return
+	c.remove(key)
+}
+
+// remove removes the provided key and reports whether it was present, the
+// lock must be held.
+// remove 移除提供的键并返回该键是否存在, 调用时需持有锁
+func (c *TwoQueueCache) remove(key interface{}) (present bool) {
+	defer c.logKey(key)
+	if val, expirationTime, ok := c.frequent.Peek(key); ok {
+		c.frequent.Remove(key)
+		c.evicted(key, val, expirationTime, ReasonRemoved)
+		return true
 	}
-	if c.recentEvict.Remove(key) {
-		return
+	if val, expirationTime, ok := c.recent.Peek(key); ok {
+		c.recent.Remove(key)
+		c.evicted(key, val, expirationTime, ReasonRemoved)
+		return true
 	}
+	// 被淘汰的键不算存在
+	c.recentEvict.Remove(key)
+	return false
 }
 
 // Purge is used to completely clear the cache.
 func (c *TwoQueueCache) Purge() {
 	c.lock.Lock()
 	defer c.lock.Unlock()
+	c.wal.purge()
 	c.recent.Purge()
 	c.frequent.Purge()
 	c.recentEvict.Purge()
@@ -216,18 +547,275 @@ func (c *TwoQueueCache) PurgeOverdue() {
 // Contains is used to check if the cache contains a key
 // without updating recency or frequency.
 func (c *TwoQueueCache) Contains(key interface{}) bool {
-	c.lock.RLock()
-	defer c.lock.RUnlock()
+	c.lock.Lock()
+	defer c.lock.Unlock()
 	return c.frequent.Contains(key) || c.recent.Contains(key)
 }
 
 // Peek is used to inspect the cache value of a key
 // without updating recency or frequency.
 func (c *TwoQueueCache) Peek(key interface{}) (value interface{}, expirationTime int64, ok bool) {
-	c.lock.RLock()
-	defer c.lock.RUnlock()
+	c.lock.Lock()
+	defer c.lock.Unlock()
 	if val, expirationTime, ok := c.frequent.Peek(key); ok {
 		return val, expirationTime, ok
 	}
 	return c.recent.Peek(key)
 }
+
+// PeekEntry returns a key's entry and metadata without updating the cache
+// state.
+// PeekEntry 返回一个键的条目及元数据, 不更新缓存的状态
+func (c *TwoQueueCache) PeekEntry(key interface{}) (e Entry, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.frequent.PeekEntry(key); ok {
+		return e, ok
+	}
+	return c.recent.PeekEntry(key)
+}
+
+// Expire sets the expiration time of a key without updating its cache state.
+// Expire 设置一个键的过期时间, 不更新缓存的状态
+func (c *TwoQueueCache) Expire(key interface{}, expirationTime int64) (ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	defer c.logKey(key)
+	return c.frequent.Expire(key, expirationTime) || c.recent.Expire(key, expirationTime)
+}
+
+// Persist removes the expiration time of a key.
+// Persist 移除一个键的过期时间, 使其永不过期
+func (c *TwoQueueCache) Persist(key interface{}) (ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	defer c.logKey(key)
+	return c.frequent.Persist(key) || c.recent.Persist(key)
+}
+
+// Touch extends the expiration time of a key by the lifetime it was given
+// when its expiration time was set.
+// Touch 按设置过期时间时的存活时长延长一个键的过期时间, 不更新缓存的状态
+func (c *TwoQueueCache) Touch(key interface{}) (ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	defer c.logKey(key)
+	return c.frequent.Touch(key) || c.recent.Touch(key)
+}
+
+// TTL returns the remaining time to live of a key, 0 if it never expires.
+// TTL 返回一个键的剩余存活时间, 永不过期时为0
+func (c *TwoQueueCache) TTL(key interface{}) (ttl time.Duration, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if ttl, ok = c.frequent.TTL(key); ok {
+		return ttl, ok
+	}
+	return c.recent.TTL(key)
+}
+
+// ExpireSliding makes a key expire after being idle for the given duration,
+// without updating the cache state.
+// ExpireSliding 设置一个键为滑动过期, 空闲 idle 时长后过期, 不更新缓存的状态
+func (c *TwoQueueCache) ExpireSliding(key interface{}, idle time.Duration) (ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	defer c.logKey(key)
+	return c.frequent.ExpireSliding(key, idle) || c.recent.ExpireSliding(key, idle)
+}
+
+// SetSlidingExpiration turns the sliding expiration mode on or off. In this
+// mode every Get extends the expiration time of an entry by the lifetime it
+// was given when its expiration time was set, Peek does not.
+// SetSlidingExpiration 开启或关闭滑动过期模式。开启后每次 Get 都会按设置过期时间时的存活时长
+// 延长条目的过期时间, Peek 不会延长
+func (c *TwoQueueCache) SetSlidingExpiration(sliding bool) {
+	c.settings.setSliding(sliding)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.frequent.SetSlidingExpiration(sliding)
+	c.recent.SetSlidingExpiration(sliding)
+}
+
+// SetJitter sets the jitter applied to the expiration times of added
+// entries, a zero Jitter turns it off.
+// SetJitter 设置新增条目过期时间的随机调整, Jitter 为零值时关闭
+func (c *TwoQueueCache) SetJitter(jitter Jitter) {
+	c.settings.setJitter(jitter)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.recent.SetJitter(jitter)
+	if jitter.Seed != 0 {
+		jitter.Seed++
+	}
+	c.frequent.SetJitter(jitter)
+}
+
+// Stats returns a snapshot of the counters of the cache.
+// Stats 返回缓存统计计数的快照
+func (c *TwoQueueCache) Stats() Stats {
+	return c.stats.snapshot()
+}
+
+// ResetStats sets the counters of the cache to zero.
+// ResetStats 将缓存的统计计数清零
+func (c *TwoQueueCache) ResetStats() {
+	c.stats.reset()
+}
+
+// SetHotKeys attaches a hot key tracker recording every Get and Add, its
+// top keys are reported by Stats. nil detaches it.
+// SetHotKeys 关联一个热点键追踪器, 记录每次 Get 和 Add, Stats 中会包含其统计的热点键。为 nil 时取消关联
+func (c *TwoQueueCache) SetHotKeys(t *HotKeys) {
+	c.stats.setHot(t)
+}
+
+// RecordLoad records a load of the value of a key, e.g. by a cache-aside
+// loader after a miss, with its duration and error. The key is counted by
+// the hot key tracker like an access.
+// RecordLoad 记录一次键的值的加载, 例如未命中后由调用方加载, 包含加载耗时和错误。
+// 该键与访问一样计入热点键追踪器
+func (c *TwoQueueCache) RecordLoad(key interface{}, loadTime time.Duration, err error) {
+	c.stats.load(key, loadTime, err)
+}
+
+// SaveTo writes a snapshot of the cache, values encoded with gob.
+// SaveTo 将缓存的快照写入 w, 值使用 gob 编码
+func (c *TwoQueueCache) SaveTo(w io.Writer) error {
+	return c.SaveToWithCodec(w, nil)
+}
+
+// SaveToWithCodec writes a snapshot of the cache with its entries,
+// expiration times and eviction order, values encoded with the codec.
+// SaveToWithCodec 将缓存的快照写入 w, 包含条目, 过期时间及淘汰顺序, 值使用给定的编码
+func (c *TwoQueueCache) SaveToWithCodec(w io.Writer, codec Codec) error {
+	var s snapshot
+	c.lock.Lock()
+	for _, key := range c.recent.Keys() {
+		if e, ok := c.recent.PeekEntry(key); ok {
+			s.records = append(s.records, snapshotRecord{list: listMain, entry: e})
+		}
+	}
+	for _, key := range c.frequent.Keys() {
+		if e, ok := c.frequent.PeekEntry(key); ok {
+			s.records = append(s.records, snapshotRecord{list: listSecond, entry: e})
+		}
+	}
+	for _, key := range c.recentEvict.Keys() {
+		if e, ok := c.recentEvict.PeekEntry(key); ok {
+			s.records = append(s.records, snapshotRecord{list: listGhost, entry: e})
+		}
+	}
+	c.lock.Unlock()
+
+	return writeSnapshot(w, "2q", codec, s)
+}
+
+// LoadFrom adds the entries of a snapshot written by SaveTo to the cache,
+// in their eviction order. Expired entries are skipped.
+// LoadFrom 按淘汰顺序将 SaveTo 写入的快照中的条目添加到缓存, 跳过已过期的条目
+func (c *TwoQueueCache) LoadFrom(r io.Reader) error {
+	return c.LoadFromWithCodec(r, nil)
+}
+
+// LoadFromWithCodec adds the entries of a snapshot written with the given
+// codec to the cache. A nil codec uses the registered codec named in the
+// snapshot. Entries already in the cache are evicted as by Add to make
+// room for the loaded ones.
+// LoadFromWithCodec 将使用给定编码写入的快照中的条目添加到缓存, codec 为 nil 时使用快照中记录的已注册编码。
+// 缓存中已有的条目会与 Add 时一样被淘汰, 为加载的条目腾出空间
+func (c *TwoQueueCache) LoadFromWithCodec(r io.Reader, codec Codec) error {
+	s, err := readSnapshot(r, "2q", codec)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UnixNano() / 1e6
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, rec := range s.records {
+		if rec.live(now) {
+			c.restore(rec)
+		}
+	}
+	return nil
+}
+
+// setWAL attaches a write-ahead log, nil detaches it.
+// setWAL 关联预写日志, 为 nil 时取消关联
+func (c *TwoQueueCache) setWAL(w *WAL) {
+	c.lock.Lock()
+	c.wal = w
+	c.lock.Unlock()
+}
+
+// replayWAL applies a record of a write-ahead log. Entries are added as
+// they were logged, their expiration times are not jittered again.
+// replayWAL 应用预写日志中的一条记录, 条目按记录原样添加, 不会再次随机调整过期时间
+func (c *TwoQueueCache) replayWAL(r walRecord) {
+	switch r.op {
+	case walAdd:
+		c.lock.Lock()
+		c.restore(r.snapshotRecord)
+		c.lock.Unlock()
+	case walRemove:
+		c.Remove(r.entry.Key)
+	case walPurge:
+		c.Purge()
+	}
+}
+
+// restore adds an entry of a snapshot or a write-ahead log to the list it
+// was saved from, replacing the key and making room like add. The lock
+// must be held.
+// restore 将快照或预写日志中的条目放回保存时所在的列表, 替换该键并与 add 一样腾出空间, 调用前需持有锁
+func (c *TwoQueueCache) restore(rec snapshotRecord) {
+	key := rec.entry.Key
+	c.recent.Remove(key)
+	c.frequent.Remove(key)
+	c.recentEvict.Remove(key)
+	switch rec.list {
+	case listMain:
+		c.ensureSpace(false)
+		c.recent.AddEntry(rec.entry)
+		c.stats.add(key)
+	case listSecond:
+		c.ensureSpace(true)
+		c.frequent.AddEntry(rec.entry)
+		c.stats.add(key)
+	case listGhost:
+		c.recentEvict.Add(key, nil, rec.entry.ExpirationTime)
+	}
+}
+
+// logKey records the entry of a key after a write, or its removal, to the
+// write-ahead log. The lock must be held.
+// logKey 写操作后将键的条目或其移除记录到预写日志, 调用时需持有锁
+func (c *TwoQueueCache) logKey(key interface{}) {
+	if c.wal == nil {
+		return
+	}
+	if e, ok := c.frequent.PeekEntry(key); ok {
+		c.wal.add(snapshotRecord{list: listSecond, entry: e})
+	} else if e, ok := c.recent.PeekEntry(key); ok {
+		c.wal.add(snapshotRecord{list: listMain, entry: e})
+	} else {
+		c.wal.remove(key)
+	}
+}
+
+// Store adds a value like Add, so that the cache can be a level of a
+// ChainCache.
+// Store 与 Add 一样添加一个值, 以便作为 ChainCache 的一级
+func (c *TwoQueueCache) Store(key, value interface{}, expirationTime int64) {
+	c.Add(key, value, expirationTime)
+}
+
+// Discard removes a key like Remove and reports whether it was present,
+// which Remove does not.
+// Discard 与 Remove 一样移除提供的键, 并返回该键是否存在(Remove 没有返回值)
+func (c *TwoQueueCache) Discard(key interface{}) (present bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.remove(key)
+}
@@ -3,6 +3,7 @@ package mcache
 import (
 	"math/rand"
 	"testing"
+	"time"
 )
 
 func Benchmark2Q_Rand(b *testing.B) {
@@ -93,6 +94,66 @@ func Test2Q_RandomOps(t *testing.T) {
 	}
 }
 
+// Test that every value leaving the cache is reported exactly once
+func Test2Q_Evict(t *testing.T) {
+	live := make(map[int64]bool)
+	onEvicted := func(k interface{}, v interface{}, expirationTime int64, reason EvictionReason) {
+		if !live[v.(int64)] {
+			t.Fatalf("value %v of key %v reported twice, reason: %v", v, k, reason)
+		}
+		delete(live, v.(int64))
+	}
+	l, err := New2QWithEvictReason(128, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var value int64
+	for i := 0; i < 200000; i++ {
+		key := rand.Int63() % 512
+		switch rand.Int63() % 3 {
+		case 0:
+			value++
+			live[value] = true
+			l.Add(key, value, 0)
+		case 1:
+			l.Get(key)
+		case 2:
+			l.Remove(key)
+		}
+		if len(live) != l.Len() {
+			t.Fatalf("bad live values: %v, len: %v", len(live), l.Len())
+		}
+	}
+
+	l.Purge()
+	if len(live) != 0 {
+		t.Fatalf("values not reported: %v", len(live))
+	}
+}
+
+// Test that New2QWithEvict is not called for replaced values
+func Test2Q_EvictWithoutReason(t *testing.T) {
+	evictCounter := 0
+	onEvicted := func(k interface{}, v interface{}, expirationTime int64) {
+		evictCounter++
+	}
+	l, err := New2QWithEvict(4, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1, 0)
+	l.Add(1, 2, 0)
+	if evictCounter != 0 {
+		t.Fatalf("bad evict count: %v", evictCounter)
+	}
+	l.Remove(1)
+	if evictCounter != 1 {
+		t.Fatalf("bad evict count: %v", evictCounter)
+	}
+}
+
 func Test2Q_Get_RecentToFrequent(t *testing.T) {
 	l, err := New2Q(128)
 	if err != nil {
@@ -304,3 +365,119 @@ func Test2Q_Peek(t *testing.T) {
 		t.Errorf("should not have updated recent-ness of 1")
 	}
 }
+
+// Test that entries keep their metadata when promoted to frequent
+func Test2Q_Entry(t *testing.T) {
+	l, err := New2Q(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1, 0)
+	before, ok := l.PeekEntry(1)
+	if !ok || before.AccessCount != 0 {
+		t.Fatalf("bad entry: %+v", before)
+	}
+
+	l.Add(1, 2, 0)
+	e, ok := l.PeekEntry(1)
+	if !ok || e.Value != 2 || e.AccessCount != 1 || e.CreationTime != before.CreationTime {
+		t.Fatalf("bad entry: %+v", e)
+	}
+	if l.recent.Contains(1) || !l.frequent.Contains(1) {
+		t.Fatalf("1 should have been promoted")
+	}
+	if e, _ = l.GetEntry(1); e.AccessCount != 2 {
+		t.Fatalf("bad entry: %+v", e)
+	}
+}
+
+// Test that sliding entries keep sliding when promoted to frequent
+func Test2Q_Sliding(t *testing.T) {
+	l, err := New2Q(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.AddSliding(1, 1, 100*time.Millisecond)
+	for i := 0; i < 3; i++ {
+		time.Sleep(50 * time.Millisecond)
+		if _, _, ok := l.Get(1); !ok {
+			t.Fatalf("1 should have been extended")
+		}
+	}
+	if !l.frequent.Contains(1) {
+		t.Fatalf("1 should have been promoted")
+	}
+
+	// 重新放入后不再滑动
+	l.Add(1, 2, 0)
+	if e, _ := l.PeekEntry(1); e.Sliding || e.ExpirationTime != 0 {
+		t.Fatalf("bad entry: %+v", e)
+	}
+}
+
+// Test that Compute and ComputeIfAbsent add like Add
+func Test2Q_Compute(t *testing.T) {
+	l, err := New2Q(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if v, ok := l.ComputeIfAbsent(1, 0, func() (interface{}, bool) { return 1, true }); !ok || v != 1 || !l.recent.Contains(1) {
+		t.Fatalf("1 should have been added to recent: %v", v)
+	}
+	if v, ok := l.Compute(1, 0, func(old interface{}, exists bool) (interface{}, bool) {
+		return old.(int) + 1, exists
+	}); !ok || v != 2 || !l.frequent.Contains(1) {
+		t.Fatalf("1 should have been promoted to frequent: %v", v)
+	}
+	if _, ok := l.Compute(1, 0, func(old interface{}, exists bool) (interface{}, bool) {
+		return nil, false
+	}); ok || l.Contains(1) {
+		t.Fatalf("1 should have been removed")
+	}
+}
+
+// Test that Add invalidates the previous version, also when it promotes
+func Test2Q_AddInvalidatesVersion(t *testing.T) {
+	l, err := New2Q(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1, 0)
+	e, _ := l.PeekEntry(1)
+	l.Add(1, 2, 0)
+	if !l.frequent.Contains(1) {
+		t.Fatalf("1 should have been promoted")
+	}
+	if _, ok := l.CompareAndSwap(1, e.Version, 3); ok {
+		t.Fatalf("should not swap with a stale version")
+	}
+	if l.CompareAndDelete(1, e.Version) {
+		t.Fatalf("should not delete with a stale version")
+	}
+	e2, _ := l.PeekEntry(1)
+	l.Add(1, 4, 0)
+	if _, ok := l.CompareAndSwap(1, e2.Version, 5); ok {
+		t.Fatalf("should not swap with a stale version")
+	}
+}
+
+// Test that a removed and re-added key does not reuse a previous version
+func Test2Q_ReAddInvalidatesVersion(t *testing.T) {
+	l, err := New2Q(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1, 0)
+	l.Add(1, 2, 0)
+	e, _ := l.PeekEntry(1)
+	l.Remove(1)
+	l.Add(1, 3, 0)
+	if _, ok := l.CompareAndSwap(1, e.Version, 4); ok {
+		t.Fatalf("should not swap with a stale version")
+	}
+}
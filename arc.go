@@ -3,7 +3,9 @@ package mcache
 import (
 	"github.com/songangweb/mcache/simplelfu"
 	"github.com/songangweb/mcache/simplelru"
+	"io"
 	"sync"
+	"time"
 )
 
 // ARCCache is a thread-safe fixed size Adaptive Replacement LfuCache (ARC).
@@ -34,13 +36,45 @@ type ARCCache struct {
 	t2 simplelfu.LFUCache // T2 is the LFU for frequently accessed items
 	b2 simplelfu.LFUCache // B2 is the LFU for evictions from t2
 
-	lock sync.RWMutex
+	// versions 是 T1, T2 共用的版本号计数器, 条目在两者之间移动时版本号不会重复
+	versions uint64
+
+	onEvict func(key interface{}, value interface{}, expirationTime int64, reason EvictionReason)
+	stats   *statsCounter
+	wal     *WAL
+
+	lock     sync.RWMutex
+	settings settingsRecorder
 }
 
 // NewARC creates an ARC of the given size
 func NewARC(size int) (*ARCCache, error) {
+	return NewARCWithEvictReason(size, nil)
+}
+
+// NewARCWithEvict creates an ARC of the given size with the given eviction
+// callback. The callback is called once for every value leaving the cache,
+// moves between the internal lists are not reported.
+// NewARCWithEvict 构造一个给定大小的ARC, 缓存中的值被淘汰时回调, 内部列表之间的移动不会回调
+func NewARCWithEvict(size int, onEvicted func(key interface{}, value interface{}, expirationTime int64)) (*ARCCache, error) {
+	return NewARCWithEvictReason(size, reasonCallback(onEvicted))
+}
+
+// NewARCWithEvictReason creates an ARC of the given size whose eviction
+// callback receives the eviction reason.
+// NewARCWithEvictReason 构造一个给定大小的ARC, 淘汰回调中包含淘汰原因
+func NewARCWithEvictReason(size int, onEvicted func(key interface{}, value interface{}, expirationTime int64, reason EvictionReason)) (*ARCCache, error) {
+	stats := &statsCounter{}
+	c := &ARCCache{
+		size:    size,
+		p:       0,
+		onEvict: stats.evicted(onEvicted),
+		stats:   stats,
+	}
+
 	// Create the sub LRUs
-	t1, err := simplelru.NewLRU(size, nil)
+	// B1, B2 只保存被淘汰的键, 不需要回调
+	t1, err := simplelru.NewLRUWithEvictReason(size, c.listEvicted)
 	if err != nil {
 		return nil, err
 	}
@@ -48,7 +82,7 @@ func NewARC(size int) (*ARCCache, error) {
 	if err != nil {
 		return nil, err
 	}
-	t2, err := simplelfu.NewLFU(size, nil)
+	t2, err := simplelfu.NewLFUWithEvictReason(size, lfuEvictCallback(c.listEvicted))
 	if err != nil {
 		return nil, err
 	}
@@ -58,14 +92,12 @@ func NewARC(size int) (*ARCCache, error) {
 	}
 
 	// Initialize the ARC
-	c := &ARCCache{
-		size: size,
-		p:    0,
-		t1:   t1,
-		b1:   b1,
-		t2:   t2,
-		b2:   b2,
-	}
+	t1.ShareVersions(&c.versions)
+	t2.ShareVersions(&c.versions)
+	c.t1 = t1
+	c.b1 = b1
+	c.t2 = t2
+	c.b2 = b2
 	return c, nil
 }
 
@@ -74,22 +106,227 @@ func NewARC(size int) (*ARCCache, error) {
 func (c *ARCCache) Get(key interface{}) (value interface{}, expirationTime int64, ok bool) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
+	e, ok := c.getEntry(key)
+	c.stats.get(key, ok)
+	return e.Value, e.ExpirationTime, ok
+}
 
+// GetEntry looks up a key's entry and metadata from the cache, updating
+// recency and frequency like Get.
+// GetEntry 从缓存中查找一个键的条目及元数据, 与 Get 一样更新缓存的状态
+func (c *ARCCache) GetEntry(key interface{}) (e Entry, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e, ok = c.getEntry(key)
+	c.stats.get(key, ok)
+	return e, ok
+}
+
+// getEntry looks up a key's entry, promoting it from T1 to T2.
+// getEntry 查找一个键的条目, 命中 T1 时移动到 T2
+func (c *ARCCache) getEntry(key interface{}) (e Entry, ok bool) {
 	// If the value is contained in T1 (recent), then
 	// promote it to T2 (frequent)
-	if val, expirationTime, ok := c.t1.Peek(key); ok {
+	if e, ok := c.t1.GetEntry(key); ok {
 		c.t1.Remove(key)
-		c.t2.Add(key, val, expirationTime)
-		return val, expirationTime, ok
+		c.t2.AddEntry(simplelfu.Entry(e))
+		return e, ok
 	}
 
 	// Check if the value is contained in T2 (frequent)
-	if val, expirationTime, ok := c.t2.Get(key); ok {
-		return val, expirationTime, ok
+	if le, ok := c.t2.GetEntry(key); ok {
+		return Entry(le), ok
 	}
 
 	// No hit
-	return nil, expirationTime, false
+	return e, false
+}
+
+// GetWithVersion looks up a key's value and version from the cache,
+// updating recency and frequency like Get.
+// GetWithVersion 从缓存中查找一个键的值及版本号, 与 Get 一样更新缓存的状态
+func (c *ARCCache) GetWithVersion(key interface{}) (value interface{}, expirationTime int64, version uint64, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e, ok := c.getEntry(key)
+	c.stats.get(key, ok)
+	return e.Value, e.ExpirationTime, e.Version, ok
+}
+
+// CompareAndSwap replaces the value of a key in place, keeping its
+// expiration time, if its version is expectedVersion. Unlike Add it does
+// not move the key between the internal lists. Returns the current version
+// and whether the value was replaced.
+// CompareAndSwap 当键的版本号等于 expectedVersion 时原地替换其值并保留过期时间,
+// 与 Add 不同, 不会在内部列表之间移动。返回当前版本号及是否替换成功
+func (c *ARCCache) CompareAndSwap(key interface{}, expectedVersion uint64, value interface{}) (version uint64, swapped bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	defer c.logKey(key)
+	return c.swap(key, expectedVersion, value, c.t1.Contains(key))
+}
+
+// swap replaces the value of a key in T1 or T2 in place if its version is
+// expectedVersion, the lock must be held.
+// swap 当键的版本号等于 expectedVersion 时原地替换其在 T1 或 T2 中的值, 调用前需持有锁
+func (c *ARCCache) swap(key interface{}, expectedVersion uint64, value interface{}, inT1 bool) (version uint64, swapped bool) {
+	if inT1 {
+		version, swapped = c.t1.CompareAndSwap(key, expectedVersion, value)
+	} else {
+		version, swapped = c.t2.CompareAndSwap(key, expectedVersion, value)
+	}
+	if swapped {
+		c.stats.add(key)
+	}
+	return version, swapped
+}
+
+// CompareAndDelete removes a key if its version is expectedVersion.
+// CompareAndDelete 当键的版本号等于 expectedVersion 时移除该键
+func (c *ARCCache) CompareAndDelete(key interface{}, expectedVersion uint64) (deleted bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	defer c.logKey(key)
+	if e, ok := c.t1.PeekEntry(key); ok {
+		if e.Version != expectedVersion {
+			return false
+		}
+		c.t1.Remove(key)
+		c.evicted(key, e.Value, e.ExpirationTime, ReasonRemoved)
+		return true
+	}
+	if e, ok := c.t2.PeekEntry(key); ok {
+		if e.Version != expectedVersion {
+			return false
+		}
+		c.t2.Remove(key)
+		c.evicted(key, e.Value, e.ExpirationTime, ReasonRemoved)
+		return true
+	}
+	return false
+}
+
+// Update replaces the value of a key in place by the result of fn, keeping
+// its expiration time, atomically under the cache lock. fn receives the
+// current value and whether the key exists, the key is removed when fn
+// returns keep false and new keys, which are added like Add, never expire.
+// Returns the value in the cache and whether the key is present. fn must
+// not use the cache.
+// Update 在缓存锁内原子地用 fn 的结果原地替换一个键的值并保留过期时间。fn 接收当前值及键是否存在,
+// 返回 keep 为false时移除该键, 新增的键与 Add 一样放入且永不过期。返回缓存中的值及键是否存在。fn 中不能访问缓存
+func (c *ARCCache) Update(key interface{}, fn func(old interface{}, exists bool) (value interface{}, keep bool)) (value interface{}, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	defer c.logKey(key)
+	e, inT1, exists := c.peekEntry(key)
+	value, keep := fn(e.Value, exists)
+	switch {
+	case !keep:
+		if exists {
+			c.removeEntry(e, inT1)
+		}
+		return nil, false
+	case !exists:
+		c.add(key, value, 0)
+	default:
+		c.swap(key, e.Version, value, inT1)
+	}
+	return value, true
+}
+
+// Compute replaces the value of a key by the result of fn with the given
+// expiration time like Add, atomically under the cache lock. fn receives
+// the current value and whether the key exists, the key is removed when fn
+// returns keep false. Returns the value in the cache and whether the key
+// is present. fn must not use the cache.
+// Compute 在缓存锁内原子地用 fn 的结果及给定的过期时间与 Add 一样替换一个键的值。fn 接收当前值及键是否存在,
+// 返回 keep 为false时移除该键。返回缓存中的值及键是否存在。fn 中不能访问缓存
+func (c *ARCCache) Compute(key interface{}, expirationTime int64, fn func(old interface{}, exists bool) (value interface{}, keep bool)) (value interface{}, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	defer c.logKey(key)
+	e, inT1, exists := c.peekEntry(key)
+	value, keep := fn(e.Value, exists)
+	if !keep {
+		if exists {
+			c.removeEntry(e, inT1)
+		}
+		return nil, false
+	}
+	c.add(key, value, expirationTime)
+	return value, true
+}
+
+// ComputeIfAbsent returns the value of a key like Get, or adds the result
+// of fn with the given expiration time like Add if the key is missing and
+// fn returns keep true, atomically under the cache lock. Returns the value
+// in the cache and whether the key is present. fn must not use the cache.
+// ComputeIfAbsent 在缓存锁内原子地执行: 与 Get 一样返回一个键的值, 键不存在时调用 fn,
+// 返回 keep 为true时以给定的过期时间与 Add 一样放入缓存。返回缓存中的值及键是否存在。fn 中不能访问缓存
+func (c *ARCCache) ComputeIfAbsent(key interface{}, expirationTime int64, fn func() (value interface{}, keep bool)) (value interface{}, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.getEntry(key); ok {
+		return e.Value, true
+	}
+	value, keep := fn()
+	if !keep {
+		return nil, false
+	}
+	c.add(key, value, expirationTime)
+	c.logKey(key)
+	return value, true
+}
+
+// Incr adds delta to the counter of a key and returns the new value. A
+// missing key is created with the given expiration time, an existing one
+// keeps its expiration time. Returns ErrNotInteger if the value is not an
+// integer and ErrOverflow if the counter does not fit in an int64, counters
+// are stored as int64.
+// Incr 将一个键的计数加上 delta 并返回新值。键不存在时以给定的过期时间创建, 已存在时保留过期时间。
+// 值不是整数时返回 ErrNotInteger, 计数超出 int64 范围时返回 ErrOverflow, 计数以 int64 保存
+func (c *ARCCache) Incr(key interface{}, delta int64, expirationTime int64) (value int64, err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	defer c.logKey(key)
+	e, inT1, ok := c.peekEntry(key)
+	if !ok {
+		c.add(key, delta, expirationTime)
+		return delta, nil
+	}
+	n, err := addDelta(e.Value, delta)
+	if err != nil {
+		return 0, err
+	}
+	c.swap(key, e.Version, n, inT1)
+	return n, nil
+}
+
+// Decr subtracts delta from the counter of a key, see Incr.
+// Decr 将一个键的计数减去 delta, 参见 Incr
+func (c *ARCCache) Decr(key interface{}, delta int64, expirationTime int64) (value int64, err error) {
+	return c.Incr(key, -delta, expirationTime)
+}
+
+// peekEntry returns the entry of a key and whether it is in T1.
+// peekEntry 返回一个键的条目及其是否在 T1 中
+func (c *ARCCache) peekEntry(key interface{}) (e Entry, inT1 bool, ok bool) {
+	if e, ok := c.t1.PeekEntry(key); ok {
+		return e, true, true
+	}
+	le, ok := c.t2.PeekEntry(key)
+	return Entry(le), false, ok
+}
+
+// removeEntry removes an entry from T1 or T2 and reports it.
+// removeEntry 从 T1 或 T2 中移除一个条目并回调
+func (c *ARCCache) removeEntry(e Entry, inT1 bool) {
+	if inT1 {
+		c.t1.Remove(e.Key)
+	} else {
+		c.t2.Remove(e.Key)
+	}
+	c.evicted(e.Key, e.Value, e.ExpirationTime, ReasonRemoved)
 }
 
 // Add adds a value to the cache.
@@ -97,12 +334,40 @@ func (c *ARCCache) Get(key interface{}) (value interface{}, expirationTime int64
 func (c *ARCCache) Add(key, value interface{}, expirationTime int64) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
+	defer c.logKey(key)
+	c.add(key, value, expirationTime)
+}
+
+// AddSliding adds a value which expires after being idle for the given
+// duration, every Get extends its expiration time.
+// AddSliding 向缓存添加一个滑动过期的值, 空闲 idle 时长后过期, 每次 Get 都会延长过期时间
+func (c *ARCCache) AddSliding(key, value interface{}, idle time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	defer c.logKey(key)
+	c.add(key, value, 0)
+	if !c.t1.ExpireSliding(key, idle) {
+		c.t2.ExpireSliding(key, idle)
+	}
+}
+
+// add adds a value to the cache, the lock must be held.
+// add 向缓存添加一个值, 调用前需持有锁
+func (c *ARCCache) add(key, value interface{}, expirationTime int64) {
+	c.stats.add(key)
 
 	// Check if the value is contained in T1 (recent), and potentially
 	// promote it to frequent T2
-	if c.t1.Contains(key) {
+	if old, ok := c.t1.PeekEntry(key); ok {
 		c.t1.Remove(key)
-		c.t2.Add(key, value, expirationTime)
+		e := old
+		e.Value, e.ExpirationTime = value, expirationTime
+		e.Lifetime, e.Sliding = 0, false
+		touchEntry(&e)
+		// 值已改变, 从共用的计数器分配新的版本号使之前的版本失效
+		e.Version = 0
+		c.t2.AddEntry(simplelfu.Entry(e))
+		c.evicted(key, old.Value, old.ExpirationTime, ReasonReplaced)
 		return
 	}
 
@@ -194,18 +459,37 @@ func (c *ARCCache) Add(key, value interface{}, expirationTime int64) {
 func (c *ARCCache) replace(b2ContainsKey bool) {
 	t1Len := c.t1.Len()
 	if t1Len > 0 && (t1Len > c.p || (t1Len == c.p && b2ContainsKey)) {
-		k, _, expirationTime, ok := c.t1.RemoveOldest()
+		k, v, expirationTime, ok := c.t1.RemoveOldest()
 		if ok {
 			c.b1.Add(k, nil, expirationTime)
+			c.evicted(k, v, expirationTime, ReasonCapacity)
 		}
 	} else {
-		k, _, expirationTime, ok := c.t2.RemoveOldest()
+		k, v, expirationTime, ok := c.t2.RemoveOldest()
 		if ok {
 			c.b2.Add(k, nil, expirationTime)
+			c.evicted(k, v, expirationTime, ReasonCapacity)
 		}
 	}
 }
 
+// listEvicted receives the evictions of T1 and T2. Entries removed by the
+// ARC itself are moved between lists or reported by the ARC.
+// listEvicted 接收 T1, T2 的淘汰回调, 主动移除的条目在列表之间移动或由ARC自行回调
+func (c *ARCCache) listEvicted(key interface{}, value interface{}, expirationTime int64, reason EvictionReason) {
+	if reason != ReasonRemoved {
+		c.evicted(key, value, expirationTime, reason)
+	}
+}
+
+// evicted calls the eviction callback if there is one.
+// evicted 调用淘汰回调
+func (c *ARCCache) evicted(key interface{}, value interface{}, expirationTime int64, reason EvictionReason) {
+	if c.onEvict != nil {
+		c.onEvict(key, value, expirationTime, reason)
+	}
+}
+
 // Len returns the number of cached entries
 // Len 获取缓存已存在的缓存条数
 func (c *ARCCache) Len() int {
@@ -214,8 +498,23 @@ func (c *ARCCache) Len() int {
 	return c.t1.Len() + c.t2.Len()
 }
 
-// Keys returns all the cached keys
-// Keys 返回缓存中键的切片，从最老到最新
+// Cap returns the capacity of the cache.
+// Cap 返回缓存的容量
+func (c *ARCCache) Cap() int {
+	return c.size
+}
+
+// Settings returns the configuration of the cache.
+// Settings 返回缓存的配置
+func (c *ARCCache) Settings() Settings {
+	return c.settings.get(c.Cap(), 1)
+}
+
+// Keys returns all the cached keys, those of T1 before those of T2, each
+// from oldest to newest. As ARC picks the list to evict from by its
+// adaptive target, the result is not in eviction order.
+// Keys 返回缓存中键的切片, T1 的键在 T2 的键之前, 各自从最老到最新。
+// ARC 按自适应的目标选择淘汰的列表, 结果不是淘汰顺序
 func (c *ARCCache) Keys() []interface{} {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
@@ -224,23 +523,44 @@ func (c *ARCCache) Keys() []interface{} {
 	return append(k1, k2...)
 }
 
+// FirstKeys returns up to n keys in the order of Keys, without listing the
+// others.
+// FirstKeys 按 Keys 的顺序返回至多 n 个键, 不列出其余的键
+func (c *ARCCache) FirstKeys(n int) []interface{} {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	keys := c.t1.FirstKeys(n)
+	return append(keys, c.t2.FirstKeys(n-len(keys))...)
+}
+
 // Remove is used to purge a key from the cache
 // Remove 从缓存中移除提供的键。
 func (c *ARCCache) Remove(key interface{}) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
-	if c.t1.Remove(key) {
-		return
-	}
-	if c.t2.Remove(key) {
-		return
+	c.remove(key)
+}
+
+// remove removes the provided key and reports whether it was present, the
+// lock must be held.
+// remove 移除提供的键并返回该键是否存在, 调用时需持有锁
+func (c *ARCCache) remove(key interface{}) (present bool) {
+	defer c.logKey(key)
+	if val, expirationTime, ok := c.t1.Peek(key); ok {
+		c.t1.Remove(key)
+		c.evicted(key, val, expirationTime, ReasonRemoved)
+		return true
 	}
-	if c.b1.Remove(key) {
-		return
+	if val, expirationTime, ok := c.t2.Peek(key); ok {
+		c.t2.Remove(key)
+		c.evicted(key, val, expirationTime, ReasonRemoved)
+		return true
 	}
-	if c.b2.Remove(key) {
-		return
+	// 被淘汰的键不算存在
+	if !c.b1.Remove(key) {
+		c.b2.Remove(key)
 	}
+	return false
 }
 
 // Purge is used to clear the cache
@@ -248,6 +568,7 @@ func (c *ARCCache) Remove(key interface{}) {
 func (c *ARCCache) Purge() {
 	c.lock.Lock()
 	defer c.lock.Unlock()
+	c.wal.purge()
 	c.t1.Purge()
 	c.t2.Purge()
 	c.b1.Purge()
@@ -258,8 +579,8 @@ func (c *ARCCache) Purge() {
 // without updating recency or frequency.
 // Contains 检查某个键是否在缓存中，但不更新缓存的状态
 func (c *ARCCache) Contains(key interface{}) bool {
-	c.lock.RLock()
-	defer c.lock.RUnlock()
+	c.lock.Lock()
+	defer c.lock.Unlock()
 	return c.t1.Contains(key) || c.t2.Contains(key)
 }
 
@@ -276,10 +597,283 @@ func (c *ARCCache) ResizeWeight(percentage int) {
 // without updating recency or frequency.
 // Peek 在不更新的情况下返回键值(如果没有找到则返回false),不更新缓存的状态
 func (c *ARCCache) Peek(key interface{}) (value interface{}, expirationTime int64, ok bool) {
-	c.lock.RLock()
-	defer c.lock.RUnlock()
+	c.lock.Lock()
+	defer c.lock.Unlock()
 	if val, expirationTime, ok := c.t1.Peek(key); ok {
 		return val, expirationTime, ok
 	}
 	return c.t2.Peek(key)
 }
+
+// PeekEntry returns a key's entry and metadata without updating recency or
+// frequency.
+// PeekEntry 返回一个键的条目及元数据, 不更新缓存的状态
+func (c *ARCCache) PeekEntry(key interface{}) (e Entry, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.t1.PeekEntry(key); ok {
+		return e, ok
+	}
+	le, ok := c.t2.PeekEntry(key)
+	return Entry(le), ok
+}
+
+// Expire sets the expiration time of a key without updating its recency or frequency.
+// Expire 设置一个键的过期时间, 不更新缓存的状态
+func (c *ARCCache) Expire(key interface{}, expirationTime int64) (ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	defer c.logKey(key)
+	return c.t1.Expire(key, expirationTime) || c.t2.Expire(key, expirationTime)
+}
+
+// Persist removes the expiration time of a key.
+// Persist 移除一个键的过期时间, 使其永不过期
+func (c *ARCCache) Persist(key interface{}) (ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	defer c.logKey(key)
+	return c.t1.Persist(key) || c.t2.Persist(key)
+}
+
+// Touch extends the expiration time of a key by the lifetime it was given
+// when its expiration time was set.
+// Touch 按设置过期时间时的存活时长延长一个键的过期时间, 不更新缓存的状态
+func (c *ARCCache) Touch(key interface{}) (ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	defer c.logKey(key)
+	return c.t1.Touch(key) || c.t2.Touch(key)
+}
+
+// TTL returns the remaining time to live of a key, 0 if it never expires.
+// TTL 返回一个键的剩余存活时间, 永不过期时为0
+func (c *ARCCache) TTL(key interface{}) (ttl time.Duration, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if ttl, ok = c.t1.TTL(key); ok {
+		return ttl, ok
+	}
+	return c.t2.TTL(key)
+}
+
+// ExpireSliding makes a key expire after being idle for the given duration,
+// without updating recency or frequency.
+// ExpireSliding 设置一个键为滑动过期, 空闲 idle 时长后过期, 不更新缓存的状态
+func (c *ARCCache) ExpireSliding(key interface{}, idle time.Duration) (ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	defer c.logKey(key)
+	return c.t1.ExpireSliding(key, idle) || c.t2.ExpireSliding(key, idle)
+}
+
+// SetSlidingExpiration turns the sliding expiration mode on or off. In this
+// mode every Get extends the expiration time of an entry by the lifetime it
+// was given when its expiration time was set, Peek does not.
+// SetSlidingExpiration 开启或关闭滑动过期模式。开启后每次 Get 都会按设置过期时间时的存活时长
+// 延长条目的过期时间, Peek 不会延长
+func (c *ARCCache) SetSlidingExpiration(sliding bool) {
+	c.settings.setSliding(sliding)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.t1.SetSlidingExpiration(sliding)
+	c.t2.SetSlidingExpiration(sliding)
+}
+
+// SetJitter sets the jitter applied to the expiration times of added
+// entries, a zero Jitter turns it off.
+// SetJitter 设置新增条目过期时间的随机调整, Jitter 为零值时关闭
+func (c *ARCCache) SetJitter(jitter Jitter) {
+	c.settings.setJitter(jitter)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.t1.SetJitter(jitter)
+	if jitter.Seed != 0 {
+		jitter.Seed++
+	}
+	c.t2.SetJitter(simplelfu.Jitter(jitter))
+}
+
+// Stats returns a snapshot of the counters of the cache.
+// Stats 返回缓存统计计数的快照
+func (c *ARCCache) Stats() Stats {
+	return c.stats.snapshot()
+}
+
+// ResetStats sets the counters of the cache to zero.
+// ResetStats 将缓存的统计计数清零
+func (c *ARCCache) ResetStats() {
+	c.stats.reset()
+}
+
+// SetHotKeys attaches a hot key tracker recording every Get and Add, its
+// top keys are reported by Stats. nil detaches it.
+// SetHotKeys 关联一个热点键追踪器, 记录每次 Get 和 Add, Stats 中会包含其统计的热点键。为 nil 时取消关联
+func (c *ARCCache) SetHotKeys(t *HotKeys) {
+	c.stats.setHot(t)
+}
+
+// RecordLoad records a load of the value of a key, e.g. by a cache-aside
+// loader after a miss, with its duration and error. The key is counted by
+// the hot key tracker like an access.
+// RecordLoad 记录一次键的值的加载, 例如未命中后由调用方加载, 包含加载耗时和错误。
+// 该键与访问一样计入热点键追踪器
+func (c *ARCCache) RecordLoad(key interface{}, loadTime time.Duration, err error) {
+	c.stats.load(key, loadTime, err)
+}
+
+// SaveTo writes a snapshot of the cache, values encoded with gob.
+// SaveTo 将缓存的快照写入 w, 值使用 gob 编码
+func (c *ARCCache) SaveTo(w io.Writer) error {
+	return c.SaveToWithCodec(w, nil)
+}
+
+// SaveToWithCodec writes a snapshot of the cache with its entries,
+// expiration times and eviction order, values encoded with the codec.
+// SaveToWithCodec 将缓存的快照写入 w, 包含条目, 过期时间及淘汰顺序, 值使用给定的编码
+func (c *ARCCache) SaveToWithCodec(w io.Writer, codec Codec) error {
+	var s snapshot
+	c.lock.Lock()
+	s.p = c.p
+	for _, key := range c.t1.Keys() {
+		if e, ok := c.t1.PeekEntry(key); ok {
+			s.records = append(s.records, snapshotRecord{list: listMain, entry: e})
+		}
+	}
+	for _, key := range c.t2.Keys() {
+		if e, ok := c.t2.PeekEntry(key); ok {
+			s.records = append(s.records, snapshotRecord{list: listSecond, entry: Entry(e)})
+		}
+	}
+	for _, key := range c.b1.Keys() {
+		if e, ok := c.b1.PeekEntry(key); ok {
+			s.records = append(s.records, snapshotRecord{list: listGhost, entry: e})
+		}
+	}
+	for _, key := range c.b2.Keys() {
+		if e, ok := c.b2.PeekEntry(key); ok {
+			s.records = append(s.records, snapshotRecord{list: listGhostSecond, entry: Entry(e)})
+		}
+	}
+	c.lock.Unlock()
+
+	return writeSnapshot(w, "arc", codec, s)
+}
+
+// LoadFrom adds the entries of a snapshot written by SaveTo to the cache,
+// in their eviction order. Expired entries are skipped.
+// LoadFrom 按淘汰顺序将 SaveTo 写入的快照中的条目添加到缓存, 跳过已过期的条目
+func (c *ARCCache) LoadFrom(r io.Reader) error {
+	return c.LoadFromWithCodec(r, nil)
+}
+
+// LoadFromWithCodec adds the entries of a snapshot written with the given
+// codec to the cache. A nil codec uses the registered codec named in the
+// snapshot. Entries already in the cache are evicted as by Add to make
+// room for the loaded ones.
+// LoadFromWithCodec 将使用给定编码写入的快照中的条目添加到缓存, codec 为 nil 时使用快照中记录的已注册编码。
+// 缓存中已有的条目会与 Add 时一样被淘汰, 为加载的条目腾出空间
+func (c *ARCCache) LoadFromWithCodec(r io.Reader, codec Codec) error {
+	s, err := readSnapshot(r, "arc", codec)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UnixNano() / 1e6
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.p = s.p
+	if c.p > c.size {
+		c.p = c.size
+	}
+	for _, rec := range s.records {
+		if rec.live(now) {
+			c.restore(rec)
+		}
+	}
+	return nil
+}
+
+// setWAL attaches a write-ahead log, nil detaches it.
+// setWAL 关联预写日志, 为 nil 时取消关联
+func (c *ARCCache) setWAL(w *WAL) {
+	c.lock.Lock()
+	c.wal = w
+	c.lock.Unlock()
+}
+
+// replayWAL applies a record of a write-ahead log. Entries are added as
+// they were logged, their expiration times are not jittered again.
+// replayWAL 应用预写日志中的一条记录, 条目按记录原样添加, 不会再次随机调整过期时间
+func (c *ARCCache) replayWAL(r walRecord) {
+	switch r.op {
+	case walAdd:
+		c.lock.Lock()
+		c.restore(r.snapshotRecord)
+		c.lock.Unlock()
+	case walRemove:
+		c.Remove(r.entry.Key)
+	case walPurge:
+		c.Purge()
+	}
+}
+
+// restore adds an entry of a snapshot or a write-ahead log to the list it
+// was saved from, replacing the key and making room like add. The lock
+// must be held.
+// restore 将快照或预写日志中的条目放回保存时所在的列表, 替换该键并与 add 一样腾出空间, 调用前需持有锁
+func (c *ARCCache) restore(rec snapshotRecord) {
+	key := rec.entry.Key
+	c.t1.Remove(key)
+	c.t2.Remove(key)
+	c.b1.Remove(key)
+	c.b2.Remove(key)
+	switch rec.list {
+	case listMain, listSecond:
+		if c.t1.Len()+c.t2.Len() >= c.size {
+			c.replace(false)
+		}
+		if rec.list == listMain {
+			c.t1.AddEntry(rec.entry)
+		} else {
+			c.t2.AddEntry(simplelfu.Entry(rec.entry))
+		}
+		c.stats.add(key)
+	case listGhost:
+		c.b1.Add(key, nil, rec.entry.ExpirationTime)
+	case listGhostSecond:
+		c.b2.Add(key, nil, rec.entry.ExpirationTime)
+	}
+}
+
+// logKey records the entry of a key after a write, or its removal, to the
+// write-ahead log. The lock must be held.
+// logKey 写操作后将键的条目或其移除记录到预写日志, 调用时需持有锁
+func (c *ARCCache) logKey(key interface{}) {
+	if c.wal == nil {
+		return
+	}
+	if e, ok := c.t1.PeekEntry(key); ok {
+		c.wal.add(snapshotRecord{list: listMain, entry: e})
+	} else if e, ok := c.t2.PeekEntry(key); ok {
+		c.wal.add(snapshotRecord{list: listSecond, entry: Entry(e)})
+	} else {
+		c.wal.remove(key)
+	}
+}
+
+// Store adds a value like Add, so that the cache can be a level of a
+// ChainCache.
+// Store 与 Add 一样添加一个值, 以便作为 ChainCache 的一级
+func (c *ARCCache) Store(key, value interface{}, expirationTime int64) {
+	c.Add(key, value, expirationTime)
+}
+
+// Discard removes a key like Remove and reports whether it was present,
+// which Remove does not.
+// Discard 与 Remove 一样移除提供的键, 并返回该键是否存在(Remove 没有返回值)
+func (c *ARCCache) Discard(key interface{}) (present bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.remove(key)
+}
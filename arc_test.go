@@ -102,6 +102,66 @@ func TestARC_RandomOps(t *testing.T) {
 	}
 }
 
+// Test that every value leaving the cache is reported exactly once
+func TestARC_Evict(t *testing.T) {
+	live := make(map[int64]bool)
+	onEvicted := func(k interface{}, v interface{}, expirationTime int64, reason EvictionReason) {
+		if !live[v.(int64)] {
+			t.Fatalf("value %v of key %v reported twice, reason: %v", v, k, reason)
+		}
+		delete(live, v.(int64))
+	}
+	l, err := NewARCWithEvictReason(128, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var value int64
+	for i := 0; i < 200000; i++ {
+		key := rand.Int63() % 512
+		switch rand.Int63() % 3 {
+		case 0:
+			value++
+			live[value] = true
+			l.Add(key, value, 0)
+		case 1:
+			l.Get(key)
+		case 2:
+			l.Remove(key)
+		}
+		if len(live) != l.Len() {
+			t.Fatalf("bad live values: %v, len: %v", len(live), l.Len())
+		}
+	}
+
+	l.Purge()
+	if len(live) != 0 {
+		t.Fatalf("values not reported: %v", len(live))
+	}
+}
+
+// Test that NewARCWithEvict is not called for replaced values
+func TestARC_EvictWithoutReason(t *testing.T) {
+	evictCounter := 0
+	onEvicted := func(k interface{}, v interface{}, expirationTime int64) {
+		evictCounter++
+	}
+	l, err := NewARCWithEvict(1, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1, 0)
+	l.Add(1, 2, 0)
+	if evictCounter != 0 {
+		t.Fatalf("bad evict count: %v", evictCounter)
+	}
+	l.Remove(1)
+	if evictCounter != 1 {
+		t.Fatalf("bad evict count: %v", evictCounter)
+	}
+}
+
 func TestARC_Get_RecentToFrequent(t *testing.T) {
 	l, err := NewARC(128)
 	if err != nil {
@@ -405,3 +465,179 @@ func TestARC_Peek(t *testing.T) {
 	}
 }
 
+// Test that entries keep their metadata when promoted to T2
+func TestARC_Entry(t *testing.T) {
+	l, err := NewARC(2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1, 0)
+	before, ok := l.PeekEntry(1)
+	if !ok || before.AccessCount != 0 {
+		t.Fatalf("bad entry: %+v", before)
+	}
+	time.Sleep(2 * time.Millisecond)
+
+	e, ok := l.GetEntry(1)
+	if !ok || e.Value != 1 || e.AccessCount != 1 || e.CreationTime != before.CreationTime {
+		t.Fatalf("bad entry: %+v", e)
+	}
+	if e.AccessTime <= e.CreationTime {
+		t.Fatalf("bad access time: %+v", e)
+	}
+	if l.t1.Contains(1) || !l.t2.Contains(1) {
+		t.Fatalf("1 should have been promoted")
+	}
+	if e, _ = l.PeekEntry(1); e.AccessCount != 1 || e.CreationTime != before.CreationTime {
+		t.Fatalf("bad entry: %+v", e)
+	}
+}
+
+// Test that Expire and TTL find keys in both T1 and T2
+func TestARC_Expire(t *testing.T) {
+	l, err := NewARC(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	now := time.Now().UnixNano() / 1e6
+	l.Add(1, 1, 0)
+	l.Add(2, 2, 0)
+	l.Get(2)
+	if !l.Expire(1, now+60000) || !l.Expire(2, now+60000) {
+		t.Fatalf("1 and 2 should be contained")
+	}
+	if ttl, ok := l.TTL(2); !ok || ttl <= 0 {
+		t.Fatalf("bad ttl: %v", ttl)
+	}
+	if !l.Persist(1) || !l.Touch(2) {
+		t.Fatalf("1 and 2 should be contained")
+	}
+	if ttl, ok := l.TTL(1); !ok || ttl != 0 {
+		t.Fatalf("bad ttl: %v", ttl)
+	}
+	l.Expire(2, now-1)
+	if l.Contains(2) || l.Touch(2) {
+		t.Fatalf("2 should have expired")
+	}
+}
+
+// Test that versions survive the promotion to T2
+func TestARC_CompareAndSwap(t *testing.T) {
+	l, err := NewARC(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1, 0)
+	_, _, v1, ok := l.GetWithVersion(1)
+	if !ok || !l.t2.Contains(1) {
+		t.Fatalf("1 should have been promoted")
+	}
+	v2, ok := l.CompareAndSwap(1, v1, 2)
+	if !ok || v2 <= v1 {
+		t.Fatalf("should swap: %v, %v", v2, ok)
+	}
+	if l.CompareAndDelete(1, v1) {
+		t.Fatalf("should not delete with a stale version")
+	}
+	if !l.CompareAndDelete(1, v2) || l.Contains(1) {
+		t.Fatalf("1 should have been deleted")
+	}
+}
+
+// Test that Add invalidates the previous version, also when it promotes
+func TestARC_AddInvalidatesVersion(t *testing.T) {
+	l, err := NewARC(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1, 0)
+	e, _ := l.PeekEntry(1)
+	l.Add(1, 2, 0)
+	if !l.t2.Contains(1) {
+		t.Fatalf("1 should have been promoted")
+	}
+	if _, ok := l.CompareAndSwap(1, e.Version, 3); ok {
+		t.Fatalf("should not swap with a stale version")
+	}
+	if l.CompareAndDelete(1, e.Version) {
+		t.Fatalf("should not delete with a stale version")
+	}
+	e2, _ := l.PeekEntry(1)
+	l.Add(1, 4, 0)
+	if _, ok := l.CompareAndSwap(1, e2.Version, 5); ok {
+		t.Fatalf("should not swap with a stale version")
+	}
+}
+
+// Test that a removed and re-added key does not reuse a previous version
+func TestARC_ReAddInvalidatesVersion(t *testing.T) {
+	l, err := NewARC(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1, 0)
+	l.Add(1, 2, 0)
+	e, _ := l.PeekEntry(1)
+	l.Remove(1)
+	l.Add(1, 3, 0)
+	if _, ok := l.CompareAndSwap(1, e.Version, 4); ok {
+		t.Fatalf("should not swap with a stale version")
+	}
+}
+
+// Test that Update works in place and reports removals
+func TestARC_Update(t *testing.T) {
+	var removed []interface{}
+	l, err := NewARCWithEvictReason(4, func(k interface{}, v interface{}, expirationTime int64, reason EvictionReason) {
+		if reason == ReasonRemoved {
+			removed = append(removed, k)
+		}
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	incr := func(old interface{}, exists bool) (interface{}, bool) {
+		if !exists {
+			return 1, true
+		}
+		return old.(int) + 1, true
+	}
+	l.Update(1, incr)
+	if v, ok := l.Update(1, incr); !ok || v != 2 || !l.t1.Contains(1) {
+		t.Fatalf("1 should have been updated in T1: %v", v)
+	}
+	if v, ok := l.Compute(1, 0, incr); !ok || v != 3 || !l.t2.Contains(1) {
+		t.Fatalf("1 should have been promoted to T2: %v", v)
+	}
+	if v, ok := l.ComputeIfAbsent(1, 0, func() (interface{}, bool) { return 0, true }); !ok || v != 3 {
+		t.Fatalf("bad value: %v", v)
+	}
+	l.Update(1, func(old interface{}, exists bool) (interface{}, bool) {
+		return nil, false
+	})
+	if l.Contains(1) || len(removed) != 1 {
+		t.Fatalf("1 should have been removed: %v", removed)
+	}
+}
+
+func TestARC_Incr(t *testing.T) {
+	l, err := NewARC(8)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Incr(1, 1, 0)
+	l.Get(1) // 提升到 t2
+	if v, err := l.Incr(1, 4, 0); err != nil || v != 5 {
+		t.Fatalf("bad: %v %v", v, err)
+	}
+	if v, _, ok := l.Peek(1); !ok || v != int64(5) {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+}
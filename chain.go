@@ -0,0 +1,175 @@
+package mcache
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// Cache is implemented by every cache of the package, TieredCache and
+// ChainCache included, so that they can be chained. Other caches, e.g. a
+// remote one, can be chained by implementing it. Store and Discard are Add
+// and Remove without the differences of their results between caches.
+// Cache 包内的所有缓存都实现了该接口(包括 TieredCache 和 ChainCache), 以便组合为多级缓存,
+// 其它缓存(例如远程缓存)实现该接口后也可以加入。Store 与 Discard 即 Add 与 Remove, 屏蔽各缓存返回值的差异
+type Cache interface {
+	Get(key interface{}) (value interface{}, expirationTime int64, ok bool)
+	Peek(key interface{}) (value interface{}, expirationTime int64, ok bool)
+	Contains(key interface{}) bool
+	Purge()
+
+	// Store 添加一个值, 替换已有的值
+	Store(key, value interface{}, expirationTime int64)
+	// Discard 移除提供的键, 返回该键是否存在
+	Discard(key interface{}) (present bool)
+}
+
+// ChainCache is a multi-level cache: Get looks up the levels in order and
+// backfills the levels above the one hit, Add writes through the write
+// levels, all of them by default, and Remove and Purge apply to every level.
+// ChainCache 多级缓存: Get 依次查找各级, 命中时回填其上的各级; Add 写入所有可写的级别(默认为全部);
+// Remove 和 Purge 作用于所有级别
+type ChainCache struct {
+	levels []Cache
+	writes []bool
+	keys   *KeyLocker
+	lock   sync.RWMutex
+}
+
+// Chain composes caches into a ChainCache, the first one being the top
+// level.
+// Chain 将多个缓存组合为多级缓存, 第一个为最上层
+func Chain(caches ...Cache) *ChainCache {
+	c := &ChainCache{
+		levels: caches,
+		writes: make([]bool, len(caches)),
+		keys:   NewKeyLocker(runtime.NumCPU()),
+	}
+	for i := range c.writes {
+		c.writes[i] = true
+	}
+	return c
+}
+
+// SetWriteLevels sets the levels Add writes to, by index. Backfills on a
+// hit still write to every level above it.
+// SetWriteLevels 按下标设置 Add 写入的级别。命中时的回填仍然写入其上的所有级别
+func (c *ChainCache) SetWriteLevels(levels ...int) error {
+	writes := make([]bool, len(c.levels))
+	for _, i := range levels {
+		if i < 0 || i >= len(c.levels) {
+			return fmt.Errorf("mcache: no level %d in a chain of %d", i, len(c.levels))
+		}
+		writes[i] = true
+	}
+	c.lock.Lock()
+	c.writes = writes
+	c.lock.Unlock()
+	return nil
+}
+
+// Add writes a value to the write levels, and removes the key from the
+// other ones.
+// Add 将值写入所有可写的级别, 并从其它级别中移除该键
+func (c *ChainCache) Add(key, value interface{}, expirationTime int64) {
+	c.lock.RLock()
+	writes := c.writes
+	c.lock.RUnlock()
+
+	c.keys.Lock(key)
+	defer c.keys.Unlock(key)
+	for i, level := range c.levels {
+		if writes[i] {
+			level.Store(key, value, expirationTime)
+		} else {
+			// 其它级别中的旧值不再有效
+			level.Discard(key)
+		}
+	}
+}
+
+// Get looks up a key's value in the levels in order, and writes it to the
+// levels above the one it was found in.
+// Get 依次在各级中查找键的值, 找到后写入其上的各级
+func (c *ChainCache) Get(key interface{}) (value interface{}, expirationTime int64, ok bool) {
+	if len(c.levels) == 0 {
+		return nil, 0, false
+	}
+	if value, expirationTime, ok = c.levels[0].Get(key); ok {
+		return value, expirationTime, ok
+	}
+
+	// 持有键的锁查找其余级别并回填, 避免覆盖并发写入的新值。每一级只查找一次
+	c.keys.Lock(key)
+	defer c.keys.Unlock(key)
+	for i := 1; i < len(c.levels); i++ {
+		if value, expirationTime, ok = c.levels[i].Get(key); ok {
+			for j := 0; j < i; j++ {
+				c.levels[j].Store(key, value, expirationTime)
+			}
+			return value, expirationTime, ok
+		}
+	}
+	return nil, 0, false
+}
+
+// Peek returns a key's value from the first level holding it, without
+// updating the levels.
+// Peek 返回第一个包含该键的级别中的值, 不更新各级的状态
+func (c *ChainCache) Peek(key interface{}) (value interface{}, expirationTime int64, ok bool) {
+	for _, level := range c.levels {
+		if value, expirationTime, ok = level.Peek(key); ok {
+			return value, expirationTime, ok
+		}
+	}
+	return nil, 0, false
+}
+
+// Contains checks if a key is in any level.
+// Contains 检查某个键是否在任意级别中
+func (c *ChainCache) Contains(key interface{}) bool {
+	for _, level := range c.levels {
+		if level.Contains(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Remove removes a key from every level.
+// Remove 从所有级别中移除提供的键
+func (c *ChainCache) Remove(key interface{}) (present bool) {
+	c.keys.Lock(key)
+	defer c.keys.Unlock(key)
+	for _, level := range c.levels {
+		present = level.Discard(key) || present
+	}
+	return present
+}
+
+// Purge clears every level.
+// Purge 清空所有级别
+func (c *ChainCache) Purge() {
+	for _, level := range c.levels {
+		level.Purge()
+	}
+}
+
+// Levels returns the number of levels.
+// Levels 返回级别的数量
+func (c *ChainCache) Levels() int {
+	return len(c.levels)
+}
+
+// Store writes a value like Add, so that a chain can be a level of another
+// chain.
+// Store 与 Add 一样写入一个值, 以便作为另一个多级缓存的一级
+func (c *ChainCache) Store(key, value interface{}, expirationTime int64) {
+	c.Add(key, value, expirationTime)
+}
+
+// Discard removes a key from every level like Remove.
+// Discard 与 Remove 一样从所有级别中移除提供的键
+func (c *ChainCache) Discard(key interface{}) (present bool) {
+	return c.Remove(key)
+}
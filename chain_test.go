@@ -0,0 +1,155 @@
+package mcache
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	_ Cache = &LruCache{}
+	_ Cache = &LfuCache{}
+	_ Cache = &GdsfCache{}
+	_ Cache = &HashLruCache{}
+	_ Cache = &HashLfuCache{}
+	_ Cache = &ARCCache{}
+	_ Cache = &TwoQueueCache{}
+	_ Cache = &TieredCache{}
+	_ Cache = &ChainCache{}
+)
+
+func TestChain(t *testing.T) {
+	l1, _ := NewLRU(2)
+	l2, _ := NewARC(8)
+	l3, _ := NewHashLFU(64, 4)
+	c := Chain(l1, l2, l3)
+
+	exp := time.Now().Add(time.Hour).UnixNano() / 1e6
+	c.Add(1, 1, exp)
+	if !l1.Contains(1) || !l2.Contains(1) || !l3.Contains(1) {
+		t.Fatalf("1 should be in every level")
+	}
+
+	// 只在最下层命中时回填上层, 保留过期时间
+	l3.Add(2, 2, exp)
+	if v, e, ok := c.Get(2); !ok || v != 2 || e != exp {
+		t.Fatalf("bad: %v %v %v", v, e, ok)
+	}
+	if _, e, ok := l1.Peek(2); !ok || e != exp || !l2.Contains(2) {
+		t.Fatalf("2 should be backfilled")
+	}
+	if _, _, ok := c.Get(3); ok {
+		t.Fatalf("3 should miss")
+	}
+
+	if !c.Remove(1) || c.Contains(1) || l3.Contains(1) {
+		t.Fatalf("1 should be removed")
+	}
+	if c.Remove(1) {
+		t.Fatalf("1 should not be present")
+	}
+	c.Purge()
+	if l1.Len() != 0 || l2.Len() != 0 || l3.Len() != 0 {
+		t.Fatalf("levels should be purged")
+	}
+}
+
+func TestChain_WriteLevels(t *testing.T) {
+	l1, _ := New2Q(4)
+	l2, _ := NewGDSF(8)
+	c := Chain(l1, l2)
+	if err := c.SetWriteLevels(2); err == nil {
+		t.Fatalf("bad level should fail")
+	}
+
+	c.Add(1, "old", 0)
+	if err := c.SetWriteLevels(1); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	// 只写入下层, 上层的旧值被移除
+	c.Add(1, "new", 0)
+	if l1.Contains(1) {
+		t.Fatalf("1 should be removed from the first level")
+	}
+	if v, _, ok := c.Peek(1); !ok || v != "new" {
+		t.Fatalf("bad: %v", v)
+	}
+	if v, _, _ := c.Get(1); v != "new" || !l1.Contains(1) {
+		t.Fatalf("bad: %v", v)
+	}
+}
+
+func TestChain_Nested(t *testing.T) {
+	l1, _ := NewLFU(1)
+	tiered, _ := NewTieredLRU(1, TieredConfig{Dir: tieredDir(t), Capacity: 1 << 20})
+	defer tiered.Close()
+	l3, _ := NewLRU(8)
+	c := Chain(l1, Chain(tiered, l3))
+
+	c.Add(1, 1, 0)
+	c.Add(2, 2, 0)
+	c.Add(3, 3, 0)
+	if tiered.DiskLen() != 2 || l3.Len() != 3 {
+		t.Fatalf("bad len: %v %v", tiered.DiskLen(), l3.Len())
+	}
+	l3.Purge()
+	if v, _, ok := c.Get(1); !ok || v != 1 || !l1.Contains(1) {
+		t.Fatalf("bad: %v", v)
+	}
+}
+
+// mapCache is a Cache outside the package's caches.
+type mapCache struct {
+	items map[interface{}]interface{}
+	gets  int
+}
+
+func (m *mapCache) Get(key interface{}) (interface{}, int64, bool) {
+	m.gets++
+	v, ok := m.items[key]
+	return v, 0, ok
+}
+
+func (m *mapCache) Peek(key interface{}) (interface{}, int64, bool) {
+	v, ok := m.items[key]
+	return v, 0, ok
+}
+
+func (m *mapCache) Contains(key interface{}) bool {
+	_, ok := m.items[key]
+	return ok
+}
+
+func (m *mapCache) Purge() {
+	m.items = make(map[interface{}]interface{})
+}
+
+func (m *mapCache) Store(key, value interface{}, expirationTime int64) {
+	m.items[key] = value
+}
+
+func (m *mapCache) Discard(key interface{}) bool {
+	_, ok := m.items[key]
+	delete(m.items, key)
+	return ok
+}
+
+func TestChain_External(t *testing.T) {
+	top := &mapCache{items: make(map[interface{}]interface{})}
+	l2, _ := NewLRU(8)
+	c := Chain(top, l2)
+
+	// 每一级只查找一次
+	l2.Add(1, 1, 0)
+	if v, _, ok := c.Get(1); !ok || v != 1 || top.gets != 1 || l2.Stats().Hits != 1 {
+		t.Fatalf("bad: %v %v %v", v, top.gets, l2.Stats().Hits)
+	}
+	if !top.Contains(1) {
+		t.Fatalf("1 should be backfilled")
+	}
+	if _, _, ok := c.Get(2); ok || top.gets != 2 || l2.Stats().Misses != 1 {
+		t.Fatalf("bad gets: %v %v", top.gets, l2.Stats().Misses)
+	}
+	if !c.Remove(1) || top.Contains(1) {
+		t.Fatalf("1 should be removed")
+	}
+}
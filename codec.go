@@ -0,0 +1,189 @@
+package mcache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Codec turns values into bytes and back, for the serialization features
+// of the package.
+// Codec 将值转换为字节以及从字节还原, 用于包内的序列化功能
+type Codec interface {
+	// Name 编码的名称, 写入序列化数据中
+	Name() string
+	// Marshal 将值编码为字节
+	Marshal(v interface{}) ([]byte, error)
+	// Unmarshal 从字节还原值
+	Unmarshal(data []byte) (interface{}, error)
+}
+
+// ErrNotBytes is returned by RawCodec for values that are not []byte or
+// string.
+// ErrNotBytes RawCodec 编码的值不是 []byte 或 string
+var ErrNotBytes = errors.New("mcache: value is not []byte or string")
+
+// GobCodec encodes values with encoding/gob. Values of types other than
+// the basic ones must be registered with gob.Register.
+// GobCodec 使用 encoding/gob 编码。基本类型以外的值需要先通过 gob.Register 注册
+type GobCodec struct{}
+
+// Name returns "gob".
+// Name 返回 "gob"
+func (GobCodec) Name() string {
+	return "gob"
+}
+
+// Marshal encodes a value.
+// Marshal 编码一个值
+func (GobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes a value.
+// Unmarshal 解码一个值
+func (GobCodec) Unmarshal(data []byte) (interface{}, error) {
+	var v interface{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// JSONCodec encodes values with encoding/json. Without New, values decode
+// the way json.Unmarshal decodes into an interface{}: objects as
+// map[string]interface{} and numbers as float64.
+// JSONCodec 使用 encoding/json 编码。未设置 New 时按 json.Unmarshal 解码到 interface{} 的方式还原:
+// 对象为 map[string]interface{}, 数字为 float64
+type JSONCodec struct {
+	// New returns a pointer to decode into, the value it points to is
+	// returned. Optional.
+	// New 返回用于解码的指针, 返回其指向的值。可选
+	New func() interface{}
+}
+
+// Name returns "json".
+// Name 返回 "json"
+func (JSONCodec) Name() string {
+	return "json"
+}
+
+// Marshal encodes a value.
+// Marshal 编码一个值
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes a value.
+// Unmarshal 解码一个值
+func (c JSONCodec) Unmarshal(data []byte) (interface{}, error) {
+	if c.New == nil {
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+	p := c.New()
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, err
+	}
+	return reflect.ValueOf(p).Elem().Interface(), nil
+}
+
+// RawCodec stores []byte and string values as they are, they decode as
+// []byte.
+// RawCodec 原样保存 []byte 和 string 类型的值, 解码为 []byte
+type RawCodec struct{}
+
+// Name returns "raw".
+// Name 返回 "raw"
+func (RawCodec) Name() string {
+	return "raw"
+}
+
+// Marshal returns the bytes of a []byte or string value.
+// Marshal 返回 []byte 或 string 类型值的字节
+func (RawCodec) Marshal(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+	return nil, ErrNotBytes
+}
+
+// Unmarshal returns a copy of the bytes.
+// Unmarshal 返回字节的副本
+func (RawCodec) Unmarshal(data []byte) (interface{}, error) {
+	return append([]byte{}, data...), nil
+}
+
+var (
+	codecsLock sync.RWMutex
+	codecs     = map[string]Codec{
+		"gob":  GobCodec{},
+		"json": JSONCodec{},
+		"raw":  RawCodec{},
+	}
+	// builtinCodecs 尚未被替换的内置编码的名称
+	builtinCodecs = map[string]bool{
+		"gob":  true,
+		"json": true,
+		"raw":  true,
+	}
+)
+
+// RegisterCodec makes a codec available by its name, for reading data
+// written with it. Registering a name twice panics, except to replace a
+// built-in codec once, such as a JSONCodec with New.
+// RegisterCodec 按名称注册编码, 用于读取使用该编码写入的数据。重复注册同一名称会 panic,
+// 内置编码可以被替换一次, 例如替换为设置了 New 的 JSONCodec
+func RegisterCodec(codec Codec) {
+	if codec == nil {
+		panic("mcache: RegisterCodec codec is nil")
+	}
+	name := codec.Name()
+
+	codecsLock.Lock()
+	defer codecsLock.Unlock()
+	if _, ok := codecs[name]; ok && !builtinCodecs[name] {
+		panic(fmt.Sprintf("mcache: RegisterCodec called twice for %s", name))
+	}
+	delete(builtinCodecs, name)
+	codecs[name] = codec
+}
+
+// LookupCodec returns the codec registered with the name.
+// LookupCodec 返回以该名称注册的编码
+func LookupCodec(name string) (Codec, bool) {
+	codecsLock.RLock()
+	defer codecsLock.RUnlock()
+	codec, ok := codecs[name]
+	return codec, ok
+}
+
+// codecFor returns the codec to read data written with the named one:
+// codec itself if set, else the registered codec.
+// codecFor 返回读取以该名称编码的数据所用的编码: 指定了 codec 时使用它, 否则使用已注册的编码
+func codecFor(name string, codec Codec) (Codec, error) {
+	if codec == nil {
+		var ok bool
+		if codec, ok = LookupCodec(name); !ok {
+			return nil, fmt.Errorf("mcache: unknown codec %s", name)
+		}
+	}
+	if codec.Name() != name {
+		return nil, fmt.Errorf("mcache: data encoded with %s, not %s", name, codec.Name())
+	}
+	return codec, nil
+}
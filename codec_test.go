@@ -0,0 +1,118 @@
+package mcache
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCodec(t *testing.T) {
+	cases := []struct {
+		codec Codec
+		in    interface{}
+		out   interface{}
+	}{
+		{GobCodec{}, snapshotValue{"a", 1}, snapshotValue{"a", 1}},
+		{GobCodec{}, 1, 1},
+		{JSONCodec{}, map[string]int{"a": 1}, map[string]interface{}{"a": float64(1)}},
+		{JSONCodec{New: func() interface{} { return new(snapshotValue) }}, snapshotValue{"a", 1}, snapshotValue{"a", 1}},
+		{RawCodec{}, []byte("a"), []byte("a")},
+		{RawCodec{}, "a", []byte("a")},
+	}
+	for _, c := range cases {
+		data, err := c.codec.Marshal(c.in)
+		if err != nil {
+			t.Fatalf("%s: err: %v", c.codec.Name(), err)
+		}
+		v, err := c.codec.Unmarshal(data)
+		if err != nil {
+			t.Fatalf("%s: err: %v", c.codec.Name(), err)
+		}
+		if !reflect.DeepEqual(v, c.out) {
+			t.Fatalf("%s: bad: %#v", c.codec.Name(), v)
+		}
+	}
+
+	if _, err := (RawCodec{}).Marshal(1); err != ErrNotBytes {
+		t.Fatalf("bad err: %v", err)
+	}
+}
+
+// upperCodec 测试用的自定义编码
+type upperCodec struct{}
+
+func (upperCodec) Name() string { return "upper" }
+
+func (upperCodec) Marshal(v interface{}) ([]byte, error) {
+	return []byte(strings.ToUpper(v.(string))), nil
+}
+
+func (upperCodec) Unmarshal(data []byte) (interface{}, error) {
+	return string(data), nil
+}
+
+func TestCodec_Registry(t *testing.T) {
+	for _, name := range []string{"gob", "json", "raw"} {
+		if c, ok := LookupCodec(name); !ok || c.Name() != name {
+			t.Fatalf("%s not registered", name)
+		}
+	}
+	if _, ok := LookupCodec("upper"); ok {
+		t.Fatalf("upper should not be registered")
+	}
+
+	l, _ := NewLRU(4)
+	l.Add(1, "a", 0)
+	var buf bytes.Buffer
+	if err := l.SaveToWithCodec(&buf, upperCodec{}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	data := buf.Bytes()
+
+	r, _ := NewLRU(4)
+	if err := r.LoadFrom(bytes.NewReader(data)); err == nil {
+		t.Fatalf("unknown codec should fail")
+	}
+	if err := r.LoadFromWithCodec(bytes.NewReader(data), GobCodec{}); err == nil {
+		t.Fatalf("codec mismatch should fail")
+	}
+
+	RegisterCodec(upperCodec{})
+	defer func() {
+		codecsLock.Lock()
+		delete(codecs, "upper")
+		codecsLock.Unlock()
+	}()
+	if err := r.LoadFrom(bytes.NewReader(data)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if v, _, ok := r.Get(1); !ok || v != "A" {
+		t.Fatalf("bad: %v", v)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("duplicate register should panic")
+			}
+		}()
+		RegisterCodec(upperCodec{})
+	}()
+	// 内置编码可以被替换一次
+	RegisterCodec(JSONCodec{New: func() interface{} { return new(snapshotValue) }})
+	defer func() {
+		codecsLock.Lock()
+		codecs["json"] = JSONCodec{}
+		builtinCodecs["json"] = true
+		codecsLock.Unlock()
+	}()
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("duplicate register should panic")
+			}
+		}()
+		RegisterCodec(JSONCodec{})
+	}()
+}
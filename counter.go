@@ -0,0 +1,140 @@
+package mcache
+
+import (
+	"errors"
+	"math"
+
+	"github.com/songangweb/mcache/simplegdsf"
+	"github.com/songangweb/mcache/simplelfu"
+	"github.com/songangweb/mcache/simplelru"
+)
+
+// ErrNotInteger is returned by Incr and Decr when the value of the key is
+// not an integer.
+// ErrNotInteger Incr, Decr 操作的键的值不是整数
+var ErrNotInteger = errors.New("value is not an integer")
+
+// ErrOverflow is returned by Incr and Decr when the counter does not fit in
+// an int64.
+// ErrOverflow Incr, Decr 操作的计数超出 int64 的范围
+var ErrOverflow = errors.New("counter overflows int64")
+
+// ErrCounterRejected is returned by Incr and Decr when the cache does not
+// store the counter, e.g. when its cost exceeds the capacity.
+// ErrCounterRejected Incr, Decr 操作的计数未被缓存保存, 例如其成本超过缓存总容量
+var ErrCounterRejected = errors.New("counter rejected by the cache")
+
+// toInt64 converts an integer value to int64.
+// toInt64 将整数类型的值转换为 int64
+func toInt64(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case uint:
+		if uint64(v) > math.MaxInt64 {
+			return 0, ErrOverflow
+		}
+		return int64(v), nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, ErrOverflow
+		}
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint8:
+		return int64(v), nil
+	}
+	return 0, ErrNotInteger
+}
+
+// addDelta adds delta to an integer value, returning ErrNotInteger or
+// ErrOverflow if the result is not an int64.
+// addDelta 将整数类型的值加上 delta, 结果不是 int64 时返回 ErrNotInteger 或 ErrOverflow
+func addDelta(value interface{}, delta int64) (int64, error) {
+	n, err := toInt64(value)
+	if err != nil {
+		return 0, err
+	}
+	if (delta > 0 && n > math.MaxInt64-delta) || (delta < 0 && n < math.MinInt64-delta) {
+		return 0, ErrOverflow
+	}
+	return n + delta, nil
+}
+
+// counterCache is the part of a single list cache used by incr, without
+// its lock.
+// counterCache 是 incr 使用的单一列表缓存的操作, 不包含锁
+type counterCache interface {
+	// peek 返回一个键的值及版本号, 不更新缓存的状态
+	peek(key interface{}) (value interface{}, version uint64, ok bool)
+	Add(key, value interface{}, expirationTime int64) (ok bool)
+	CompareAndSwap(key interface{}, expectedVersion uint64, value interface{}) (version uint64, swapped bool)
+}
+
+// lruCounter adapts an LRU to counterCache.
+// lruCounter 将 LRU 适配为 counterCache
+type lruCounter struct {
+	simplelru.LRUCache
+}
+
+func (l lruCounter) peek(key interface{}) (interface{}, uint64, bool) {
+	e, ok := l.PeekEntry(key)
+	return e.Value, e.Version, ok
+}
+
+// lfuCounter adapts an LFU to counterCache.
+// lfuCounter 将 LFU 适配为 counterCache
+type lfuCounter struct {
+	simplelfu.LFUCache
+}
+
+func (l lfuCounter) peek(key interface{}) (interface{}, uint64, bool) {
+	e, ok := l.PeekEntry(key)
+	return e.Value, e.Version, ok
+}
+
+// gdsfCounter adapts a GDSF to counterCache.
+// gdsfCounter 将 GDSF 适配为 counterCache
+type gdsfCounter struct {
+	simplegdsf.GDSFCache
+}
+
+func (l gdsfCounter) peek(key interface{}) (interface{}, uint64, bool) {
+	e, ok := l.PeekEntry(key)
+	return e.Value, e.Version, ok
+}
+
+// incr adds delta to the counter of a key, creating it with the given
+// expiration time if it is missing. The counter is stored as an int64 and
+// keeps its expiration time. Returns ErrCounterRejected if the cache does
+// not store it.
+// incr 将一个键的计数加上 delta, 不存在时以给定的过期时间创建。计数以 int64 保存, 并保留过期时间,
+// 缓存未保存计数时返回 ErrCounterRejected
+func incr(l counterCache, key interface{}, delta int64, expirationTime int64) (int64, error) {
+	value, version, ok := l.peek(key)
+	if !ok {
+		if !l.Add(key, delta, expirationTime) {
+			return 0, ErrCounterRejected
+		}
+		return delta, nil
+	}
+	n, err := addDelta(value, delta)
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := l.CompareAndSwap(key, version, n); !ok {
+		return 0, ErrCounterRejected
+	}
+	return n, nil
+}
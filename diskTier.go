@@ -0,0 +1,236 @@
+package mcache
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// diskTier is a log-structured store of bounded size on disk. Entries are
+// appended to segment files, and the oldest segment is deleted with its
+// entries when the capacity is reached.
+// diskTier 磁盘上有容量上限的日志结构存储。条目追加写入分段文件, 达到容量时删除最旧的分段及其中的条目
+type diskTier struct {
+	dir         string
+	capacity    int64
+	segmentSize int64
+	codec       Codec
+
+	segments []*diskSegment
+	index    map[interface{}]diskLocation
+	size     int64
+	nextID   int
+	lock     sync.Mutex
+}
+
+// diskSegment is a file of a diskTier.
+// diskSegment diskTier 的一个分段文件
+type diskSegment struct {
+	file *os.File
+	size int64
+	keys []interface{}
+}
+
+// diskLocation locates the record of an entry.
+// diskLocation 条目记录所在的位置
+type diskLocation struct {
+	segment        *diskSegment
+	offset         int64
+	length         int64
+	expirationTime int64
+}
+
+// diskSegmentPrefix 分段文件名的前缀
+const diskSegmentPrefix = "mcache-l2-"
+
+// newDiskTier creates a diskTier in dir, deleting the segments left by a
+// previous one.
+// newDiskTier 在 dir 中创建 diskTier, 并删除之前遗留的分段文件
+func newDiskTier(dir string, capacity, segmentSize int64, codec Codec) (*diskTier, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if strings.HasPrefix(f.Name(), diskSegmentPrefix) {
+			if err := os.Remove(filepath.Join(dir, f.Name())); err != nil {
+				return nil, err
+			}
+		}
+	}
+	d := &diskTier{
+		dir:         dir,
+		capacity:    capacity,
+		segmentSize: segmentSize,
+		codec:       codec,
+		index:       make(map[interface{}]diskLocation),
+	}
+	return d, nil
+}
+
+// put appends an entry, replacing the previous one. Entries larger than
+// the capacity, or whose value cannot be encoded, are dropped.
+// put 追加写入一个条目, 替换之前的条目。超过容量或值无法编码的条目会被丢弃
+func (d *diskTier) put(key, value interface{}, expirationTime int64) error {
+	e := Entry{Key: key, Value: value, ExpirationTime: expirationTime}
+	data, err := encodeWALRecord(walRecord{op: walAdd, snapshotRecord: snapshotRecord{entry: e}}, d.codec)
+	if err != nil {
+		return err
+	}
+	n := int64(len(data))
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	delete(d.index, key)
+	if n > d.capacity {
+		return fmt.Errorf("mcache: entry of %d bytes exceeds the disk capacity", n)
+	}
+	for d.size+n > d.capacity {
+		d.dropOldest()
+	}
+	seg := d.active()
+	if seg == nil || seg.size+n > d.segmentSize {
+		if seg, err = d.newSegment(); err != nil {
+			return err
+		}
+	}
+	if _, err := seg.file.WriteAt(data, seg.size); err != nil {
+		return err
+	}
+	d.index[key] = diskLocation{segment: seg, offset: seg.size, length: n, expirationTime: expirationTime}
+	seg.keys = append(seg.keys, key)
+	seg.size += n
+	d.size += n
+	return nil
+}
+
+// get returns an entry, removing it if take is set. Expired entries are
+// removed and not returned.
+// get 返回一个条目, take 为 true 时同时移除。已过期的条目会被移除且不返回
+func (d *diskTier) get(key interface{}, take bool) (value interface{}, expirationTime int64, ok bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	loc, ok := d.index[key]
+	if !ok {
+		return nil, 0, false
+	}
+	if loc.expirationTime != 0 && loc.expirationTime <= time.Now().UnixNano()/1e6 {
+		delete(d.index, key)
+		return nil, 0, false
+	}
+	data := make([]byte, loc.length)
+	if _, err := loc.segment.file.ReadAt(data, loc.offset); err != nil {
+		delete(d.index, key)
+		return nil, 0, false
+	}
+	r, _, err := readWALRecord(bufio.NewReader(bytes.NewReader(data)), d.codec)
+	if err != nil {
+		delete(d.index, key)
+		return nil, 0, false
+	}
+	if take {
+		delete(d.index, key)
+	}
+	return r.entry.Value, r.entry.ExpirationTime, true
+}
+
+// contains reports whether an unexpired entry is stored.
+// contains 判断是否存在未过期的条目
+func (d *diskTier) contains(key interface{}) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	loc, ok := d.index[key]
+	return ok && (loc.expirationTime == 0 || loc.expirationTime > time.Now().UnixNano()/1e6)
+}
+
+// remove removes an entry, its record stays until its segment is dropped.
+// remove 移除一个条目, 其记录保留到所在分段被删除
+func (d *diskTier) remove(key interface{}) (present bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	_, present = d.index[key]
+	delete(d.index, key)
+	return present
+}
+
+// len returns the number of entries, including expired ones not removed
+// yet.
+// len 返回条目数量, 包括尚未移除的过期条目
+func (d *diskTier) len() int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return len(d.index)
+}
+
+// bytes returns the size of the segments.
+// bytes 返回分段文件的总大小
+func (d *diskTier) bytes() int64 {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.size
+}
+
+// purge deletes all the entries and segments.
+// purge 删除所有条目及分段文件
+func (d *diskTier) purge() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	var err error
+	for len(d.segments) > 0 {
+		if derr := d.dropOldest(); err == nil {
+			err = derr
+		}
+	}
+	return err
+}
+
+// active returns the segment appended to.
+// active 返回当前追加写入的分段
+func (d *diskTier) active() *diskSegment {
+	if len(d.segments) == 0 {
+		return nil
+	}
+	return d.segments[len(d.segments)-1]
+}
+
+// newSegment creates the segment to append to.
+// newSegment 创建新的追加写入分段
+func (d *diskTier) newSegment() (*diskSegment, error) {
+	name := filepath.Join(d.dir, fmt.Sprintf("%s%06d.log", diskSegmentPrefix, d.nextID))
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	d.nextID++
+	seg := &diskSegment{file: f}
+	d.segments = append(d.segments, seg)
+	return seg, nil
+}
+
+// dropOldest deletes the oldest segment and the entries stored in it.
+// dropOldest 删除最旧的分段及其中的条目
+func (d *diskTier) dropOldest() error {
+	seg := d.segments[0]
+	d.segments = d.segments[1:]
+	for _, key := range seg.keys {
+		if loc, ok := d.index[key]; ok && loc.segment == seg {
+			delete(d.index, key)
+		}
+	}
+	d.size -= seg.size
+	name := seg.file.Name()
+	err := seg.file.Close()
+	if rerr := os.Remove(name); err == nil {
+		err = rerr
+	}
+	return err
+}
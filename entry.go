@@ -0,0 +1,27 @@
+package mcache
+
+import (
+	"time"
+
+	"github.com/songangweb/mcache/simplelru"
+)
+
+// Entry describes a cache entry and its metadata: creation time, last
+// access time, access count, cost and remaining TTL. simplelfu and
+// simplegdsf define the same fields.
+// Entry 缓存条目及其元数据(创建时间、最后访问时间、访问次数、成本、剩余存活时间),
+// 时间均为毫秒时间戳, 与 simplelfu, simplegdsf 中的字段一致
+type Entry = simplelru.Entry
+
+// Jitter randomizes the expiration times of added entries, so that entries
+// added together don't expire together. simplelfu and simplegdsf define the
+// same fields.
+// Jitter 随机调整新增条目的过期时间, 避免同时放入的条目同时过期, 与 simplelfu, simplegdsf 中的字段一致
+type Jitter = simplelru.Jitter
+
+// touchEntry records an access of an entry moved between internal lists.
+// touchEntry 记录一次访问, 用于在内部列表之间移动的条目
+func touchEntry(e *Entry) {
+	e.AccessTime = time.Now().UnixNano() / 1e6
+	e.AccessCount++
+}
@@ -0,0 +1,63 @@
+package mcache
+
+import (
+	"github.com/songangweb/mcache/simplegdsf"
+	"github.com/songangweb/mcache/simplelfu"
+	"github.com/songangweb/mcache/simplelru"
+)
+
+// EvictionReason describes why an entry left the cache. simplelfu and
+// simplegdsf define the same values.
+// EvictionReason 缓存条目被淘汰的原因, 与 simplelfu, simplegdsf 中的取值一致
+type EvictionReason = simplelru.EvictionReason
+
+const (
+	// ReasonCapacity 因容量不足被淘汰
+	ReasonCapacity = simplelru.ReasonCapacity
+	// ReasonExpired 因过期被删除
+	ReasonExpired = simplelru.ReasonExpired
+	// ReasonRemoved 被主动移除
+	ReasonRemoved = simplelru.ReasonRemoved
+	// ReasonReplaced 旧值被新值替换
+	ReasonReplaced = simplelru.ReasonReplaced
+	// ReasonPurged 因清空缓存被删除
+	ReasonPurged = simplelru.ReasonPurged
+	// ReasonResized 因调整缓存大小被淘汰
+	ReasonResized = simplelru.ReasonResized
+)
+
+// reasonCallback adapts an eviction callback without reason, which is not
+// called for replaced values.
+// reasonCallback 将不含淘汰原因的回调转换为含淘汰原因的回调, 值被替换时不回调
+func reasonCallback(onEvicted func(key interface{}, value interface{}, expirationTime int64)) func(key interface{}, value interface{}, expirationTime int64, reason EvictionReason) {
+	if onEvicted == nil {
+		return nil
+	}
+	return func(key interface{}, value interface{}, expirationTime int64, reason EvictionReason) {
+		if reason != ReasonReplaced {
+			onEvicted(key, value, expirationTime)
+		}
+	}
+}
+
+// lfuEvictCallback converts an eviction callback for simplelfu.
+// lfuEvictCallback 将淘汰回调转换为 simplelfu 的回调
+func lfuEvictCallback(onEvicted func(key interface{}, value interface{}, expirationTime int64, reason EvictionReason)) simplelfu.EvictReasonCallback {
+	if onEvicted == nil {
+		return nil
+	}
+	return func(key interface{}, value interface{}, expirationTime int64, reason simplelfu.EvictionReason) {
+		onEvicted(key, value, expirationTime, EvictionReason(reason))
+	}
+}
+
+// gdsfEvictCallback converts an eviction callback for simplegdsf.
+// gdsfEvictCallback 将淘汰回调转换为 simplegdsf 的回调
+func gdsfEvictCallback(onEvicted func(key interface{}, value interface{}, expirationTime int64, reason EvictionReason)) simplegdsf.EvictReasonCallback {
+	if onEvicted == nil {
+		return nil
+	}
+	return func(key interface{}, value interface{}, expirationTime int64, reason simplegdsf.EvictionReason) {
+		onEvicted(key, value, expirationTime, EvictionReason(reason))
+	}
+}
@@ -0,0 +1,143 @@
+package mcache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy decides what an EvictQueue does with a notification when
+// it is full.
+// OverflowPolicy 淘汰通知队列已满时的处理策略
+type OverflowPolicy int
+
+const (
+	// OverflowBlock 阻塞直到队列有空位, 会阻塞持有缓存锁的写操作
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest 丢弃新的通知
+	OverflowDropNewest
+	// OverflowDropOldest 丢弃队列中最旧的通知
+	OverflowDropOldest
+)
+
+// evictEvent is a queued eviction notification
+// evictEvent 队列中的淘汰通知
+type evictEvent struct {
+	key            interface{}
+	value          interface{}
+	expirationTime int64
+	reason         EvictionReason
+}
+
+// EvictQueue delivers eviction notifications to a callback from a dedicated
+// goroutine through a bounded queue. Pass its Push method as the eviction
+// callback of any cache: the callback then never runs under the cache lock,
+// so it may use the cache and a slow callback does not stall writers.
+// With OverflowBlock a full queue blocks the writer while it holds the
+// cache lock, a callback using the cache should pick a drop policy instead.
+// EvictQueue 通过有界队列和独立的协程投递淘汰通知。将 Push 方法作为任意缓存的淘汰回调,
+// 回调就不会在缓存锁内执行, 因此可以在回调中访问缓存, 耗时的回调也不会阻塞写操作。
+// OverflowBlock 策略在队列已满时会阻塞持有缓存锁的写操作, 回调中需要访问缓存时请使用丢弃策略
+type EvictQueue struct {
+	events  chan evictEvent
+	policy  OverflowPolicy
+	onEvict func(key interface{}, value interface{}, expirationTime int64, reason EvictionReason)
+
+	dropped uint64
+	closed  bool
+	done    chan struct{}
+	lock    sync.RWMutex
+}
+
+// NewEvictQueue creates an EvictQueue holding up to capacity notifications
+// and starts its goroutine.
+// NewEvictQueue 构造一个最多保存 capacity 条通知的队列, 并启动处理协程
+func NewEvictQueue(capacity int, policy OverflowPolicy, onEvicted func(key interface{}, value interface{}, expirationTime int64, reason EvictionReason)) (*EvictQueue, error) {
+	if capacity <= 0 {
+		return nil, errors.New("must provide a positive capacity")
+	}
+	if onEvicted == nil {
+		return nil, errors.New("must provide an eviction callback")
+	}
+	q := &EvictQueue{
+		events:  make(chan evictEvent, capacity),
+		policy:  policy,
+		onEvict: onEvicted,
+		done:    make(chan struct{}),
+	}
+	go q.run()
+	return q, nil
+}
+
+// Push queues an eviction notification according to the overflow policy.
+// Notifications pushed after Close are dropped.
+// Push 按溢出策略将淘汰通知放入队列, Close 之后的通知会被丢弃
+func (q *EvictQueue) Push(key interface{}, value interface{}, expirationTime int64, reason EvictionReason) {
+	ev := evictEvent{key, value, expirationTime, reason}
+
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	if q.closed {
+		atomic.AddUint64(&q.dropped, 1)
+		return
+	}
+
+	switch q.policy {
+	case OverflowDropNewest:
+		select {
+		case q.events <- ev:
+		default:
+			atomic.AddUint64(&q.dropped, 1)
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case q.events <- ev:
+				return
+			default:
+			}
+			// 队列已满, 丢弃最旧的通知后重试
+			select {
+			case <-q.events:
+				atomic.AddUint64(&q.dropped, 1)
+			default:
+			}
+		}
+	default:
+		q.events <- ev
+	}
+}
+
+// Dropped returns the number of notifications dropped so far.
+// Dropped 返回已丢弃的通知数量
+func (q *EvictQueue) Dropped() uint64 {
+	return atomic.LoadUint64(&q.dropped)
+}
+
+// Len returns the number of notifications waiting in the queue.
+// Len 返回队列中等待处理的通知数量
+func (q *EvictQueue) Len() int {
+	return len(q.events)
+}
+
+// Close stops accepting notifications and waits until the queued ones have
+// been delivered.
+// Close 停止接收通知, 并等待队列中的通知处理完毕
+func (q *EvictQueue) Close() {
+	q.lock.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.events)
+	}
+	q.lock.Unlock()
+	<-q.done
+}
+
+// run delivers the queued notifications until the queue is closed.
+// run 依次投递队列中的通知, 直到队列关闭
+func (q *EvictQueue) run() {
+	defer close(q.done)
+	for ev := range q.events {
+		q.onEvict(ev.key, ev.value, ev.expirationTime, ev.reason)
+	}
+}
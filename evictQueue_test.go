@@ -0,0 +1,90 @@
+package mcache
+
+import (
+	"sync"
+	"testing"
+)
+
+// test that a callback delivered through the queue can use the cache
+func TestEvictQueue(t *testing.T) {
+	var l *LruCache
+	var lock sync.Mutex
+	var keys []interface{}
+	q, err := NewEvictQueue(16, OverflowBlock, func(k interface{}, v interface{}, expirationTime int64, reason EvictionReason) {
+		// 回调在队列协程中执行, 访问缓存不会死锁
+		l.Contains(k)
+		lock.Lock()
+		keys = append(keys, k)
+		lock.Unlock()
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l, err = NewLruWithEvictReason(2, q.Push)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		l.Add(i, i, 0)
+	}
+	q.Close()
+
+	if len(keys) != 8 {
+		t.Fatalf("bad evicted: %v", keys)
+	}
+	for i, k := range keys {
+		if k != i {
+			t.Fatalf("bad order: %v", keys)
+		}
+	}
+
+	// Close 之后的通知被丢弃
+	l.Add(10, 10, 0)
+	if q.Dropped() != 1 {
+		t.Fatalf("bad dropped: %v", q.Dropped())
+	}
+}
+
+// test the overflow policies
+func TestEvictQueue_Overflow(t *testing.T) {
+	for _, policy := range []OverflowPolicy{OverflowDropNewest, OverflowDropOldest} {
+		block := make(chan struct{})
+		// 每次进入回调时通知, 容量足够所有通知, 回调不会因此阻塞
+		started := make(chan struct{}, 6)
+		var keys []interface{}
+		q, err := NewEvictQueue(2, policy, func(k interface{}, v interface{}, expirationTime int64, reason EvictionReason) {
+			started <- struct{}{}
+			<-block
+			keys = append(keys, k)
+		})
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		// 第一条通知被协程取出后阻塞在回调中, 之后队列最多保存2条
+		q.Push(0, 0, 0, ReasonCapacity)
+		<-started
+		for i := 1; i < 6; i++ {
+			q.Push(i, i, 0, ReasonCapacity)
+		}
+		if q.Dropped() != 3 {
+			t.Fatalf("bad dropped: %v", q.Dropped())
+		}
+		close(block)
+		q.Close()
+
+		want := []interface{}{0, 1, 2}
+		if policy == OverflowDropOldest {
+			want = []interface{}{0, 4, 5}
+		}
+		if len(keys) != len(want) {
+			t.Fatalf("bad keys: %v", keys)
+		}
+		for i := range want {
+			if keys[i] != want[i] {
+				t.Fatalf("bad keys: %v", keys)
+			}
+		}
+	}
+}
@@ -0,0 +1,621 @@
+package mcache
+
+import (
+	"github.com/songangweb/mcache/simplegdsf"
+	"io"
+	"sync"
+	"time"
+)
+
+// GdsfCache is a thread-safe fixed size GreedyDual-Size-Frequency cache.
+// Entries with a high recompute cost per unit of size are kept over
+// cheap-to-recompute large ones.
+// GdsfCache 实现一个给定大小的GDSF缓存, 优先保留单位大小重新计算成本高的条目
+type GdsfCache struct {
+	gdsf     simplegdsf.GDSFCache
+	lock     sync.RWMutex
+	stats    *statsCounter
+	wal      *WAL
+	settings settingsRecorder
+}
+
+// NewGDSF creates a GDSF of the given size.
+// NewGDSF 构造一个给定大小的GDSF
+func NewGDSF(size int) (*GdsfCache, error) {
+	return NewGdsfWithEvict(size, nil)
+}
+
+// NewGdsfWithEvict constructs a fixed size cache with the given eviction
+// callback.
+// NewGdsfWithEvict 用于在缓存条目被淘汰时的回调函数
+func NewGdsfWithEvict(size int, onEvicted func(key interface{}, value interface{}, expirationTime int64)) (*GdsfCache, error) {
+	return NewGdsfWithEvictReason(size, reasonCallback(onEvicted))
+}
+
+// NewGdsfWithEvictReason constructs a fixed size cache whose eviction
+// callback receives the eviction reason.
+// NewGdsfWithEvictReason 用于在缓存条目被淘汰时的回调函数, 回调中包含淘汰原因
+func NewGdsfWithEvictReason(size int, onEvicted func(key interface{}, value interface{}, expirationTime int64, reason EvictionReason)) (*GdsfCache, error) {
+	stats := &statsCounter{}
+	gdsf, err := simplegdsf.NewGDSFWithEvictReason(size, gdsfEvictCallback(stats.evicted(onEvicted)))
+	if err != nil {
+		return nil, err
+	}
+	gdsf.SetAddCallback(stats.add)
+	c := &GdsfCache{
+		gdsf:  gdsf,
+		stats: stats,
+	}
+	return c, nil
+}
+
+// Purge is used to completely clear the cache.
+// Purge 用于完全清除缓存
+func (c *GdsfCache) Purge() {
+	c.lock.Lock()
+	c.wal.purge()
+	c.gdsf.Purge()
+	c.lock.Unlock()
+}
+
+// PurgeOverdue is used to completely clear the overdue cache.
+// PurgeOverdue 用于清除过期缓存。
+func (c *GdsfCache) PurgeOverdue() {
+	c.lock.Lock()
+	c.gdsf.PurgeOverdue()
+	c.lock.Unlock()
+}
+
+// Add adds a value to the cache. Returns true if an eviction occurred.
+// Add 向缓存添加一个值。如果已经存在,则更新信息
+func (c *GdsfCache) Add(key, value interface{}, expirationTime int64) (evicted bool) {
+	c.lock.Lock()
+	evicted = c.gdsf.Add(key, value, expirationTime)
+	c.logKey(key)
+	c.lock.Unlock()
+	return evicted
+}
+
+// AddWithCost adds a value whose size is cost, with a recompute cost of 1,
+// evicting entries until it fits. Returns false if the cost exceeds the
+// capacity.
+// AddWithCost 向缓存添加一个大小为 cost 的值, 重新计算的成本为1, 淘汰数据直到可以放入
+func (c *GdsfCache) AddWithCost(key, value interface{}, cost int64, expirationTime int64) (ok bool) {
+	c.lock.Lock()
+	ok = c.gdsf.AddWithCost(key, value, cost, expirationTime)
+	c.logKey(key)
+	c.lock.Unlock()
+	return ok
+}
+
+// AddWithSizeCost adds a value with the given size and recompute cost,
+// evicting the lowest priority entries until it fits. Returns false if the
+// size exceeds the capacity.
+// AddWithSizeCost 向缓存添加一个指定大小和重新计算成本的值,淘汰优先级最低的数据直到可以放入
+func (c *GdsfCache) AddWithSizeCost(key, value interface{}, size int64, cost float64, expirationTime int64) (ok bool) {
+	c.lock.Lock()
+	ok = c.gdsf.AddWithSizeCost(key, value, size, cost, expirationTime)
+	c.logKey(key)
+	c.lock.Unlock()
+	return ok
+}
+
+// SetSizer sets the function used by Add to compute entry sizes, the
+// capacity is then expressed as total size instead of entry count. The
+// counters of Incr and Decr are int64 values sized by the sizer too.
+// SetSizer 设置 Add 计算条目大小的函数, 缓存容量即为总大小。Incr, Decr 的计数为 int64, 同样由 sizer 计算大小
+func (c *GdsfCache) SetSizer(sizer func(key interface{}, value interface{}) int64) {
+	c.settings.setSized(sizer != nil)
+	c.lock.Lock()
+	c.gdsf.SetSizer(simplegdsf.Sizer(sizer))
+	c.lock.Unlock()
+}
+
+// SetJitter sets the jitter applied to the expiration times of added
+// entries, a zero Jitter turns it off.
+// SetJitter 设置新增条目过期时间的随机调整, Jitter 为零值时关闭
+func (c *GdsfCache) SetJitter(jitter Jitter) {
+	c.settings.setJitter(jitter)
+	c.lock.Lock()
+	c.gdsf.SetJitter(simplegdsf.Jitter(jitter))
+	c.lock.Unlock()
+}
+
+// Get looks up a key's value from the cache.
+// Get 从缓存中查找一个键的值
+func (c *GdsfCache) Get(key interface{}) (value interface{}, expirationTime int64, ok bool) {
+	c.lock.Lock()
+	value, expirationTime, ok = c.gdsf.Get(key)
+	c.stats.get(key, ok)
+	c.lock.Unlock()
+	return value, expirationTime, ok
+}
+
+// GetWithVersion looks up a key's value and version from the cache,
+// updating the priority like Get.
+// GetWithVersion 从缓存中查找一个键的值及版本号, 与 Get 一样更新缓存的状态
+func (c *GdsfCache) GetWithVersion(key interface{}) (value interface{}, expirationTime int64, version uint64, ok bool) {
+	c.lock.Lock()
+	value, expirationTime, version, ok = c.gdsf.GetWithVersion(key)
+	c.stats.get(key, ok)
+	c.lock.Unlock()
+	return value, expirationTime, version, ok
+}
+
+// CompareAndSwap replaces the value of a key, keeping its expiration time,
+// if its version is expectedVersion. Returns the current version and
+// whether the value was replaced.
+// CompareAndSwap 当键的版本号等于 expectedVersion 时替换其值并保留过期时间, 返回当前版本号及是否替换成功
+func (c *GdsfCache) CompareAndSwap(key interface{}, expectedVersion uint64, value interface{}) (version uint64, swapped bool) {
+	c.lock.Lock()
+	version, swapped = c.gdsf.CompareAndSwap(key, expectedVersion, value)
+	c.logKey(key)
+	c.lock.Unlock()
+	return version, swapped
+}
+
+// CompareAndDelete removes a key if its version is expectedVersion.
+// CompareAndDelete 当键的版本号等于 expectedVersion 时移除该键
+func (c *GdsfCache) CompareAndDelete(key interface{}, expectedVersion uint64) (deleted bool) {
+	c.lock.Lock()
+	deleted = c.gdsf.CompareAndDelete(key, expectedVersion)
+	c.logKey(key)
+	c.lock.Unlock()
+	return deleted
+}
+
+// Update replaces the value of a key by the result of fn, keeping its
+// expiration time, atomically under the cache lock. fn receives the current
+// value and whether the key exists, the key is removed when fn returns keep
+// false and new keys never expire. Returns the value in the cache and
+// whether the key is present. fn must not use the cache.
+// Update 在缓存锁内原子地用 fn 的结果替换一个键的值并保留过期时间。fn 接收当前值及键是否存在,
+// 返回 keep 为false时移除该键, 新增的键永不过期。返回缓存中的值及键是否存在。fn 中不能访问缓存
+func (c *GdsfCache) Update(key interface{}, fn func(old interface{}, exists bool) (value interface{}, keep bool)) (value interface{}, ok bool) {
+	c.lock.Lock()
+	value, ok = c.gdsf.Update(key, fn)
+	c.logKey(key)
+	c.lock.Unlock()
+	return value, ok
+}
+
+// Compute replaces the value of a key by the result of fn with the given
+// expiration time, atomically under the cache lock. fn receives the current
+// value and whether the key exists, the key is removed when fn returns keep
+// false. Returns the value in the cache and whether the key is present.
+// fn must not use the cache.
+// Compute 在缓存锁内原子地用 fn 的结果及给定的过期时间替换一个键的值。fn 接收当前值及键是否存在,
+// 返回 keep 为false时移除该键。返回缓存中的值及键是否存在。fn 中不能访问缓存
+func (c *GdsfCache) Compute(key interface{}, expirationTime int64, fn func(old interface{}, exists bool) (value interface{}, keep bool)) (value interface{}, ok bool) {
+	c.lock.Lock()
+	value, ok = c.gdsf.Compute(key, expirationTime, fn)
+	c.logKey(key)
+	c.lock.Unlock()
+	return value, ok
+}
+
+// ComputeIfAbsent returns the value of a key like Get, or adds the result
+// of fn with the given expiration time if the key is missing and fn returns
+// keep true, atomically under the cache lock. Returns the value in the
+// cache and whether the key is present. fn must not use the cache.
+// ComputeIfAbsent 在缓存锁内原子地执行: 与 Get 一样返回一个键的值, 键不存在时调用 fn,
+// 返回 keep 为true时以给定的过期时间放入缓存。返回缓存中的值及键是否存在。fn 中不能访问缓存
+func (c *GdsfCache) ComputeIfAbsent(key interface{}, expirationTime int64, fn func() (value interface{}, keep bool)) (value interface{}, ok bool) {
+	c.lock.Lock()
+	// 键已存在时没有写入
+	exists := c.gdsf.Contains(key)
+	value, ok = c.gdsf.ComputeIfAbsent(key, expirationTime, fn)
+	if !exists {
+		c.logKey(key)
+	}
+	c.lock.Unlock()
+	return value, ok
+}
+
+// Incr adds delta to the counter of a key and returns the new value. A
+// missing key is created with the given expiration time, an existing one
+// keeps its expiration time. Returns ErrNotInteger if the value is not an
+// integer, ErrOverflow if the counter does not fit in an int64 and
+// ErrCounterRejected if the cache does not store it. Counters are stored as
+// int64, which a sizer must accept.
+// Incr 将一个键的计数加上 delta 并返回新值。键不存在时以给定的过期时间创建, 已存在时保留过期时间。
+// 值不是整数时返回 ErrNotInteger, 计数超出 int64 范围时返回 ErrOverflow, 缓存未保存计数时返回 ErrCounterRejected。
+// 计数以 int64 保存, sizer 需要能计算其成本
+func (c *GdsfCache) Incr(key interface{}, delta int64, expirationTime int64) (value int64, err error) {
+	c.lock.Lock()
+	value, err = incr(gdsfCounter{c.gdsf}, key, delta, expirationTime)
+	c.logKey(key)
+	c.lock.Unlock()
+	return value, err
+}
+
+// Decr subtracts delta from the counter of a key, see Incr.
+// Decr 将一个键的计数减去 delta, 参见 Incr
+func (c *GdsfCache) Decr(key interface{}, delta int64, expirationTime int64) (value int64, err error) {
+	return c.Incr(key, -delta, expirationTime)
+}
+
+// Contains checks if a key is in the cache, without updating the
+// recent-ness or deleting it for being stale.
+// Contains 检查某个键是否在缓存中，但不更新缓存的状态
+func (c *GdsfCache) Contains(key interface{}) bool {
+	c.lock.Lock()
+	containKey := c.gdsf.Contains(key)
+	c.lock.Unlock()
+	return containKey
+}
+
+// Peek returns the key value (or undefined if not found) without updating
+// the "recently used"-ness of the key.
+// Peek 在不更新的情况下返回键值(如果没有找到则返回false),不更新缓存的状态
+func (c *GdsfCache) Peek(key interface{}) (value interface{}, expirationTime int64, ok bool) {
+	c.lock.Lock()
+	value, expirationTime, ok = c.gdsf.Peek(key)
+	c.lock.Unlock()
+	return value, expirationTime, ok
+}
+
+// GetEntry looks up a key's entry and metadata from the cache, updating
+// the priority like Get.
+// GetEntry 从缓存中查找一个键的条目及元数据, 与 Get 一样更新缓存的状态
+func (c *GdsfCache) GetEntry(key interface{}) (Entry, bool) {
+	c.lock.Lock()
+	ge, ok := c.gdsf.GetEntry(key)
+	c.stats.get(key, ok)
+	c.lock.Unlock()
+	return Entry(ge), ok
+}
+
+// PeekEntry returns a key's entry and metadata without updating the
+// priority of the key.
+// PeekEntry 返回一个键的条目及元数据, 不更新缓存的状态
+func (c *GdsfCache) PeekEntry(key interface{}) (Entry, bool) {
+	c.lock.Lock()
+	ge, ok := c.gdsf.PeekEntry(key)
+	c.lock.Unlock()
+	return Entry(ge), ok
+}
+
+// Expire sets the expiration time of a key without updating its priority.
+// Expire 设置一个键的过期时间, 不更新缓存的状态
+func (c *GdsfCache) Expire(key interface{}, expirationTime int64) (ok bool) {
+	c.lock.Lock()
+	ok = c.gdsf.Expire(key, expirationTime)
+	c.logKey(key)
+	c.lock.Unlock()
+	return ok
+}
+
+// Persist removes the expiration time of a key.
+// Persist 移除一个键的过期时间, 使其永不过期
+func (c *GdsfCache) Persist(key interface{}) (ok bool) {
+	c.lock.Lock()
+	ok = c.gdsf.Persist(key)
+	c.logKey(key)
+	c.lock.Unlock()
+	return ok
+}
+
+// Touch extends the expiration time of a key by the lifetime it was given
+// when its expiration time was set.
+// Touch 按设置过期时间时的存活时长延长一个键的过期时间, 不更新缓存的状态
+func (c *GdsfCache) Touch(key interface{}) (ok bool) {
+	c.lock.Lock()
+	ok = c.gdsf.Touch(key)
+	c.logKey(key)
+	c.lock.Unlock()
+	return ok
+}
+
+// TTL returns the remaining time to live of a key, 0 if it never expires.
+// TTL 返回一个键的剩余存活时间, 永不过期时为0
+func (c *GdsfCache) TTL(key interface{}) (ttl time.Duration, ok bool) {
+	c.lock.Lock()
+	ttl, ok = c.gdsf.TTL(key)
+	c.lock.Unlock()
+	return ttl, ok
+}
+
+// AddSliding adds a value which expires after being idle for the given
+// duration, every Get extends its expiration time.
+// AddSliding 向缓存添加一个滑动过期的值, 空闲 idle 时长后过期, 每次 Get 都会延长过期时间
+func (c *GdsfCache) AddSliding(key, value interface{}, idle time.Duration) (ok bool) {
+	c.lock.Lock()
+	ok = c.gdsf.AddSliding(key, value, idle)
+	c.logKey(key)
+	c.lock.Unlock()
+	return ok
+}
+
+// ExpireSliding makes a key expire after being idle for the given duration,
+// without updating its priority.
+// ExpireSliding 设置一个键为滑动过期, 空闲 idle 时长后过期, 不更新缓存的状态
+func (c *GdsfCache) ExpireSliding(key interface{}, idle time.Duration) (ok bool) {
+	c.lock.Lock()
+	ok = c.gdsf.ExpireSliding(key, idle)
+	c.logKey(key)
+	c.lock.Unlock()
+	return ok
+}
+
+// SetSlidingExpiration turns the sliding expiration mode on or off. In this
+// mode every Get extends the expiration time of an entry by the lifetime it
+// was given when its expiration time was set, Peek does not.
+// SetSlidingExpiration 开启或关闭滑动过期模式。开启后每次 Get 都会按设置过期时间时的存活时长
+// 延长条目的过期时间, Peek 不会延长
+func (c *GdsfCache) SetSlidingExpiration(sliding bool) {
+	c.settings.setSliding(sliding)
+	c.lock.Lock()
+	c.gdsf.SetSlidingExpiration(sliding)
+	c.lock.Unlock()
+}
+
+// ContainsOrAdd checks if a key is in the cache without updating the
+// recent-ness or deleting it for being stale, and if not, adds the value.
+// Returns whether found and whether an eviction occurred.
+// ContainsOrAdd 判断是否已经存在于缓存中,如果已经存在则不创建及更新内容
+func (c *GdsfCache) ContainsOrAdd(key, value interface{}, expirationTime int64) (ok, evicted bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.gdsf.Contains(key) {
+		return true, false
+	}
+	evicted = c.gdsf.Add(key, value, expirationTime)
+	c.logKey(key)
+	return false, evicted
+}
+
+// PeekOrAdd checks if a key is in the cache without updating the
+// recent-ness or deleting it for being stale, and if not, adds the value.
+// Returns whether found and whether an eviction occurred.
+// PeekOrAdd 判断是否已经存在于缓存中,如果已经存在则不更新其键的使用状态
+func (c *GdsfCache) PeekOrAdd(key, value interface{}, expirationTime int64) (previous interface{}, ok, evicted bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	previous, expirationTime, ok = c.gdsf.Peek(key)
+	if ok {
+		return previous, true, false
+	}
+
+	evicted = c.gdsf.Add(key, value, expirationTime)
+	c.logKey(key)
+	return nil, false, evicted
+}
+
+// Remove removes the provided key from the cache.
+// Remove 从缓存中移除提供的键
+func (c *GdsfCache) Remove(key interface{}) (present bool) {
+	c.lock.Lock()
+	present = c.gdsf.Remove(key)
+	c.logKey(key)
+	c.lock.Unlock()
+	return
+}
+
+// Resize changes the cache size, returning the number of evicted entries.
+// Resize 调整缓存大小，返回淘汰的数量
+func (c *GdsfCache) Resize(size int) (evicted int) {
+	c.lock.Lock()
+	evicted = c.gdsf.Resize(size)
+	c.lock.Unlock()
+	return evicted
+}
+
+// ResizeWeight scales the access counts by percentage.
+// ResizeWeight 改变缓存中Weight大小。
+func (c *GdsfCache) ResizeWeight(percentage int) {
+	c.lock.Lock()
+	c.gdsf.ResizeWeight(percentage)
+	c.lock.Unlock()
+}
+
+// RemoveOldest removes the lowest priority item from the cache.
+// RemoveOldest 从缓存中移除优先级最低的项
+func (c *GdsfCache) RemoveOldest() (key interface{}, value interface{}, expirationTime int64, ok bool) {
+	c.lock.Lock()
+	key, value, expirationTime, ok = c.gdsf.RemoveOldest()
+	if ok {
+		c.logKey(key)
+	}
+	c.lock.Unlock()
+	return
+}
+
+// GetOldest returns the lowest priority entry
+// GetOldest 返回优先级最低的条目
+func (c *GdsfCache) GetOldest() (key interface{}, value interface{}, expirationTime int64, ok bool) {
+	c.lock.Lock()
+	key, value, expirationTime, ok = c.gdsf.GetOldest()
+	c.lock.Unlock()
+	return
+}
+
+// Keys returns a slice of the keys in the cache, from lowest to highest
+// priority.
+// Keys 返回缓存中键的切片，从优先级最低到最高
+func (c *GdsfCache) Keys() []interface{} {
+	c.lock.RLock()
+	keys := c.gdsf.Keys()
+	c.lock.RUnlock()
+	return keys
+}
+
+// FirstKeys returns up to n keys of the cache, from lowest to
+// highest priority, without
+// listing the others.
+// FirstKeys 返回缓存中至多 n 个键，从优先级最低到最高，不列出其余的键
+func (c *GdsfCache) FirstKeys(n int) []interface{} {
+	c.lock.RLock()
+	keys := c.gdsf.FirstKeys(n)
+	c.lock.RUnlock()
+	return keys
+}
+
+// Cost returns the total size of items in the cache.
+// Cost 获取缓存中所有条目的总大小
+func (c *GdsfCache) Cost() int64 {
+	c.lock.RLock()
+	cost := c.gdsf.Cost()
+	c.lock.RUnlock()
+	return cost
+}
+
+// Len returns the number of items in the cache.
+// Len 获取缓存已存在的缓存条数
+func (c *GdsfCache) Len() int {
+	c.lock.RLock()
+	length := c.gdsf.Len()
+	c.lock.RUnlock()
+	return length
+}
+
+// Cap returns the capacity of the cache, a total cost when a sizer is set.
+// Cap 返回缓存的容量, 设置了成本函数时为总成本
+func (c *GdsfCache) Cap() int {
+	c.lock.RLock()
+	capacity := c.gdsf.Cap()
+	c.lock.RUnlock()
+	return capacity
+}
+
+// Settings returns the configuration of the cache.
+// Settings 返回缓存的配置
+func (c *GdsfCache) Settings() Settings {
+	return c.settings.get(c.Cap(), 1)
+}
+
+// Stats returns a snapshot of the counters of the cache.
+// Stats 返回缓存统计计数的快照
+func (c *GdsfCache) Stats() Stats {
+	return c.stats.snapshot()
+}
+
+// ResetStats sets the counters of the cache to zero.
+// ResetStats 将缓存的统计计数清零
+func (c *GdsfCache) ResetStats() {
+	c.stats.reset()
+}
+
+// SetHotKeys attaches a hot key tracker recording every Get and Add, its
+// top keys are reported by Stats. nil detaches it.
+// SetHotKeys 关联一个热点键追踪器, 记录每次 Get 和 Add, Stats 中会包含其统计的热点键。为 nil 时取消关联
+func (c *GdsfCache) SetHotKeys(t *HotKeys) {
+	c.stats.setHot(t)
+}
+
+// RecordLoad records a load of the value of a key, e.g. by a cache-aside
+// loader after a miss, with its duration and error. The key is counted by
+// the hot key tracker like an access.
+// RecordLoad 记录一次键的值的加载, 例如未命中后由调用方加载, 包含加载耗时和错误。
+// 该键与访问一样计入热点键追踪器
+func (c *GdsfCache) RecordLoad(key interface{}, loadTime time.Duration, err error) {
+	c.stats.load(key, loadTime, err)
+}
+
+// SaveTo writes a snapshot of the cache, values encoded with gob.
+// SaveTo 将缓存的快照写入 w, 值使用 gob 编码
+func (c *GdsfCache) SaveTo(w io.Writer) error {
+	return c.SaveToWithCodec(w, nil)
+}
+
+// SaveToWithCodec writes a snapshot of the cache with its entries,
+// expiration times and eviction order, values encoded with the codec. The
+// aging clock is not saved: loaded entries are prioritized against the
+// clock of the loading cache from their frequency, size and cost.
+// SaveToWithCodec 将缓存的快照写入 w, 包含条目, 过期时间及淘汰顺序, 值使用给定的编码。
+// 快照不保存老化时钟: 加载的条目按访问次数, 大小和成本以加载缓存的时钟重新计算优先级
+func (c *GdsfCache) SaveToWithCodec(w io.Writer, codec Codec) error {
+	var s snapshot
+	c.lock.Lock()
+	for _, key := range c.gdsf.Keys() {
+		if e, ok := c.gdsf.PeekEntry(key); ok {
+			cost, _ := c.gdsf.RecomputeCost(key)
+			s.records = append(s.records, snapshotRecord{list: listMain, entry: Entry(e), cost: cost})
+		}
+	}
+	c.lock.Unlock()
+
+	return writeSnapshot(w, "gdsf", codec, s)
+}
+
+// LoadFrom adds the entries of a snapshot written by SaveTo to the cache,
+// in their eviction order. Expired entries are skipped.
+// LoadFrom 按淘汰顺序将 SaveTo 写入的快照中的条目添加到缓存, 跳过已过期的条目
+func (c *GdsfCache) LoadFrom(r io.Reader) error {
+	return c.LoadFromWithCodec(r, nil)
+}
+
+// LoadFromWithCodec adds the entries of a snapshot written with the given
+// codec to the cache. A nil codec uses the registered codec named in the
+// snapshot.
+// LoadFromWithCodec 将使用给定编码写入的快照中的条目添加到缓存, codec 为 nil 时使用快照中记录的已注册编码
+func (c *GdsfCache) LoadFromWithCodec(r io.Reader, codec Codec) error {
+	s, err := readSnapshot(r, "gdsf", codec)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UnixNano() / 1e6
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, rec := range s.records {
+		if rec.live(now) {
+			c.gdsf.AddEntry(simplegdsf.Entry(rec.entry), rec.cost)
+		}
+	}
+	return nil
+}
+
+// setWAL attaches a write-ahead log, nil detaches it.
+// setWAL 关联预写日志, 为 nil 时取消关联
+func (c *GdsfCache) setWAL(w *WAL) {
+	c.lock.Lock()
+	c.wal = w
+	c.lock.Unlock()
+}
+
+// replayWAL applies a record of a write-ahead log. Entries are added as
+// they were logged, their expiration times are not jittered again.
+// replayWAL 应用预写日志中的一条记录, 条目按记录原样添加, 不会再次随机调整过期时间
+func (c *GdsfCache) replayWAL(r walRecord) {
+	c.lock.Lock()
+	switch r.op {
+	case walAdd:
+		c.gdsf.AddEntry(simplegdsf.Entry(r.entry), r.cost)
+	case walRemove:
+		c.gdsf.Remove(r.entry.Key)
+	case walPurge:
+		c.gdsf.Purge()
+	}
+	c.lock.Unlock()
+}
+
+// logKey records the entry of a key after a write, or its removal, to the
+// write-ahead log. The lock must be held.
+// logKey 写操作后将键的条目或其移除记录到预写日志, 调用时需持有锁
+func (c *GdsfCache) logKey(key interface{}) {
+	if c.wal == nil {
+		return
+	}
+	if e, ok := c.gdsf.PeekEntry(key); ok {
+		cost, _ := c.gdsf.RecomputeCost(key)
+		c.wal.add(snapshotRecord{list: listMain, entry: Entry(e), cost: cost})
+	} else {
+		c.wal.remove(key)
+	}
+}
+
+// Store adds a value like Add, ignoring its result, so that the cache can
+// be a level of a ChainCache.
+// Store 与 Add 一样添加一个值并忽略其返回值, 以便作为 ChainCache 的一级
+func (c *GdsfCache) Store(key, value interface{}, expirationTime int64) {
+	c.Add(key, value, expirationTime)
+}
+
+// Discard removes a key like Remove and reports whether it was present.
+// Discard 与 Remove 一样移除提供的键, 返回该键是否存在
+func (c *GdsfCache) Discard(key interface{}) (present bool) {
+	return c.Remove(key)
+}
@@ -0,0 +1,71 @@
+package mcache
+
+import (
+	"testing"
+)
+
+func TestGDSF(t *testing.T) {
+	evictCounter := 0
+	onEvicted := func(k interface{}, v interface{}, expirationTime int64) {
+		if k != v {
+			t.Fatalf("Evict values not equal (%v!=%v)", k, v)
+		}
+		evictCounter++
+	}
+	l, err := NewGdsfWithEvict(128, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 256; i++ {
+		l.Add(i, i, 0)
+		l.Get(i)
+	}
+	if l.Len() != 128 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+	if evictCounter != 128 {
+		t.Fatalf("bad evict count: %v", evictCounter)
+	}
+
+	for i := 128; i < 192; i++ {
+		l.Remove(i)
+		_, _, ok := l.Get(i)
+		if ok {
+			t.Fatalf("should be deleted")
+		}
+	}
+
+	l.Purge()
+	if l.Len() != 0 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+	if _, _, ok := l.Get(200); ok {
+		t.Fatalf("should contain nothing")
+	}
+}
+
+// test that expensive small entries survive cheap large ones
+func TestGDSFAddWithSizeCost(t *testing.T) {
+	l, err := NewGDSF(100)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.AddWithSizeCost("report", "report", 50, 5, 0)
+	l.AddWithSizeCost("query", "query", 10, 500, 0)
+	l.AddWithSizeCost("page", "page", 40, 40, 0)
+
+	if !l.Contains("report") || l.Cost() != 100 {
+		t.Fatalf("bad cost: %v", l.Cost())
+	}
+	if contains, _ := l.ContainsOrAdd("page", "page", 0); !contains {
+		t.Errorf("page should be contained")
+	}
+	if previous, contains, _ := l.PeekOrAdd("image", "image", 0); contains || previous != nil {
+		t.Errorf("image should not have been contained")
+	}
+	if l.Contains("report") || !l.Contains("query") || !l.Contains("image") {
+		t.Errorf("report should have been evicted: %v", l.Keys())
+	}
+}
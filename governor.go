@@ -0,0 +1,225 @@
+package mcache
+
+import (
+	"errors"
+	"io/ioutil"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultGovernorInterval is the default interval between memory checks.
+	// DefaultGovernorInterval 默认的内存检查间隔
+	DefaultGovernorInterval = time.Second
+
+	// DefaultGovernorLowRatio is the default ratio of the limit below which
+	// the cache grows back.
+	// DefaultGovernorLowRatio 内存低于 Limit 的该比例时恢复缓存容量
+	DefaultGovernorLowRatio = 0.8
+
+	// DefaultGovernorStepRatio is the default ratio of the full size shed or
+	// restored on each check.
+	// DefaultGovernorStepRatio 每次检查收缩或恢复的容量占初始容量的比例
+	DefaultGovernorStepRatio = 0.1
+)
+
+// MemoryReader returns the current memory usage in bytes.
+// MemoryReader 返回当前内存使用量(字节)
+type MemoryReader func() (uint64, error)
+
+// Resizer is implemented by the caches a Governor can resize.
+// Resizer 可以被 Governor 调整容量的缓存
+type Resizer interface {
+	Resize(size int) (evicted int)
+}
+
+// GovernorConfig holds the parameters of a Governor.
+// GovernorConfig Governor 的配置参数
+type GovernorConfig struct {
+	// Limit 触发收缩的内存阈值(字节), 必须大于0
+	Limit uint64
+	// LowRatio 内存低于 Limit*LowRatio 时逐步恢复容量
+	LowRatio float64
+	// StepRatio 每次收缩或恢复的容量占初始容量的比例
+	StepRatio float64
+	// MinSize 收缩后的最小容量
+	MinSize int
+	// Interval 内存检查间隔
+	Interval time.Duration
+	// Reader 读取内存使用量, 默认为 HeapMemoryReader
+	Reader MemoryReader
+}
+
+// Governor periodically reads the memory usage and shrinks the cache when
+// it exceeds the limit, growing it back up to its initial size once the
+// pressure drops.
+// Governor 定期读取内存使用量, 超过阈值时收缩缓存容量, 压力下降后再逐步恢复到初始容量
+type Governor struct {
+	cache   Resizer
+	maxSize int
+	size    int
+	config  GovernorConfig
+
+	stop chan struct{}
+	done chan struct{}
+	lock sync.Mutex
+}
+
+// NewGovernor creates a Governor for a cache whose full size is size.
+// NewGovernor 为容量为 size 的缓存创建一个 Governor
+func NewGovernor(cache Resizer, size int, config GovernorConfig) (*Governor, error) {
+	if size <= 0 {
+		return nil, errors.New("must provide a positive size")
+	}
+	if config.Limit == 0 {
+		return nil, errors.New("must provide a positive limit")
+	}
+	if config.LowRatio <= 0 || config.LowRatio > 1 {
+		config.LowRatio = DefaultGovernorLowRatio
+	}
+	if config.StepRatio <= 0 || config.StepRatio > 1 {
+		config.StepRatio = DefaultGovernorStepRatio
+	}
+	if config.MinSize <= 0 {
+		config.MinSize = 1
+	}
+	if config.MinSize > size {
+		config.MinSize = size
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultGovernorInterval
+	}
+	if config.Reader == nil {
+		config.Reader = HeapMemoryReader
+	}
+	g := &Governor{
+		cache:   cache,
+		maxSize: size,
+		size:    size,
+		config:  config,
+	}
+	return g, nil
+}
+
+// Start starts checking the memory usage in a background goroutine.
+// Start 启动后台协程定期检查内存使用量
+func (g *Governor) Start() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.stop != nil {
+		return
+	}
+	g.stop = make(chan struct{})
+	g.done = make(chan struct{})
+	go g.run(g.stop, g.done)
+}
+
+// Stop stops the background goroutine, the cache keeps its current size.
+// Stop 停止后台协程, 缓存保持当前容量
+func (g *Governor) Stop() {
+	g.lock.Lock()
+	stop, done := g.stop, g.done
+	g.stop, g.done = nil, nil
+	g.lock.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// Size returns the size currently applied to the cache.
+// Size 返回当前设置给缓存的容量
+func (g *Governor) Size() int {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.size
+}
+
+// run checks the memory usage every interval until stop is closed.
+// run 每隔 Interval 检查一次内存使用量, 直到 stop 被关闭
+func (g *Governor) run(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(g.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			// 读取失败时跳过本次检查
+			_ = g.check()
+		}
+	}
+}
+
+// check reads the memory usage once and resizes the cache if needed.
+// check 读取一次内存使用量, 必要时调整缓存容量
+func (g *Governor) check() error {
+	usage, err := g.config.Reader()
+	if err != nil {
+		return err
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	step := int(float64(g.maxSize) * g.config.StepRatio)
+	if step < 1 {
+		step = 1
+	}
+	size := g.size
+	switch {
+	case usage > g.config.Limit:
+		// 内存压力过高, 收缩容量
+		size -= step
+		if size < g.config.MinSize {
+			size = g.config.MinSize
+		}
+	case float64(usage) < float64(g.config.Limit)*g.config.LowRatio:
+		// 内存压力下降, 恢复容量
+		size += step
+		if size > g.maxSize {
+			size = g.maxSize
+		}
+	}
+	if size != g.size {
+		g.size = size
+		g.cache.Resize(size)
+	}
+	return nil
+}
+
+// heapObjectsMetric is the runtime metric read by HeapMemoryReader.
+// heapObjectsMetric HeapMemoryReader 读取的运行时指标
+const heapObjectsMetric = "/memory/classes/heap/objects:bytes"
+
+// HeapMemoryReader returns the bytes of allocated heap objects. Freed
+// entries are only reflected after the next garbage collection. It reads
+// runtime/metrics, which unlike runtime.ReadMemStats does not stop the world.
+// HeapMemoryReader 返回堆上已分配对象的字节数, 被淘汰的数据在下次GC后才会体现。
+// 通过 runtime/metrics 读取, 不像 runtime.ReadMemStats 那样暂停所有协程
+func HeapMemoryReader() (uint64, error) {
+	sample := []metrics.Sample{{Name: heapObjectsMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0, errors.New("unsupported metric " + heapObjectsMetric)
+	}
+	return sample[0].Value.Uint64(), nil
+}
+
+// CgroupMemoryReader returns a MemoryReader that reads a cgroup memory usage
+// file, such as /sys/fs/cgroup/memory.current (v2) or
+// /sys/fs/cgroup/memory/memory.usage_in_bytes (v1).
+// CgroupMemoryReader 返回读取 cgroup 内存使用量文件的 MemoryReader
+func CgroupMemoryReader(path string) MemoryReader {
+	return func() (uint64, error) {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return 0, err
+		}
+		return strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+	}
+}
@@ -0,0 +1,107 @@
+package mcache
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// test that the governor shrinks under pressure and grows back afterwards
+func TestGovernor(t *testing.T) {
+	l, err := NewLRU(100)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		l.Add(i, i, 0)
+	}
+
+	var usage uint64
+	g, err := NewGovernor(l, 100, GovernorConfig{
+		Limit:     1000,
+		StepRatio: 0.25,
+		MinSize:   30,
+		Reader: func() (uint64, error) {
+			return usage, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	usage = 2000
+	g.check()
+	if g.Size() != 75 || l.Len() != 75 {
+		t.Fatalf("bad size: %v, len: %v", g.Size(), l.Len())
+	}
+	g.check()
+	g.check()
+	if g.Size() != 30 || l.Len() != 30 {
+		t.Fatalf("size should stop at MinSize: %v, len: %v", g.Size(), l.Len())
+	}
+
+	// 介于 LowRatio 与 Limit 之间时保持不变
+	usage = 900
+	g.check()
+	if g.Size() != 30 {
+		t.Fatalf("bad size: %v", g.Size())
+	}
+
+	usage = 100
+	for i := 0; i < 10; i++ {
+		g.check()
+	}
+	if g.Size() != 100 {
+		t.Fatalf("size should grow back to 100: %v", g.Size())
+	}
+	for i := 0; i < 100; i++ {
+		l.Add(i, i, 0)
+	}
+	if l.Len() != 100 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+}
+
+// test that Start and Stop run the background checks
+func TestGovernor_StartStop(t *testing.T) {
+	l, err := NewLRU(10)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	g, err := NewGovernor(l, 10, GovernorConfig{
+		Limit:    1,
+		Interval: time.Millisecond,
+		Reader: func() (uint64, error) {
+			return 2, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	g.Start()
+	time.Sleep(50 * time.Millisecond)
+	g.Stop()
+	if g.Size() != 1 {
+		t.Fatalf("bad size: %v", g.Size())
+	}
+}
+
+func TestCgroupMemoryReader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.current")
+	if err := ioutil.WriteFile(path, []byte("123456\n"), 0644); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	usage, err := CgroupMemoryReader(path)()
+	if err != nil || usage != 123456 {
+		t.Fatalf("bad usage: %v, err: %v", usage, err)
+	}
+}
+
+func TestHeapMemoryReader(t *testing.T) {
+	usage, err := HeapMemoryReader()
+	if err != nil || usage == 0 {
+		t.Fatalf("bad usage: %v, err: %v", usage, err)
+	}
+}
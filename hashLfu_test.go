@@ -276,6 +276,82 @@ func TestHashLFUResize(t *testing.T) {
 	if !l.Contains(3) || !l.Contains(4) {
 		t.Errorf("lruCache should have contained 2 elements")
 	}
+
+	// 分片容量向上取整, 并记录新的容量
+	h, err := NewHashLFU(8, 4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	h.Resize(10)
+	if h.Cap() != 12 || h.size != 10 {
+		t.Errorf("bad cap: %v, size: %v", h.Cap(), h.size)
+	}
+}
+
+// test that the eviction callback receives the reason
+func TestHashLFUEvictReason(t *testing.T) {
+	reasons := make(map[interface{}]EvictionReason)
+	onEvicted := func(k interface{}, v interface{}, expirationTime int64, reason EvictionReason) {
+		reasons[k] = reason
+	}
+	l, err := NewHashLfuWithEvictReason(2, 1, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1, 0)
+	l.Add(1, 1, 0)
+	if reasons[1] != ReasonReplaced {
+		t.Errorf("bad reason: %v", reasons[1])
+	}
+	l.Remove(1)
+	if reasons[1] != ReasonRemoved {
+		t.Errorf("bad reason: %v", reasons[1])
+	}
+	l.Add(2, 2, 0)
+	l.Purge()
+	if reasons[2] != ReasonPurged || reasons[2].String() != "purged" {
+		t.Errorf("bad reason: %v", reasons[2])
+	}
+}
+
+// test that AddWithCost bounds every slice by total cost
+func TestHashLFUKeys(t *testing.T) {
+	l, _ := NewHashLFU(64, 4)
+	for i := 0; i < 16; i++ {
+		l.Add(i, i, 0)
+	}
+	// 第一个分片为空时其它分片的键也要返回
+	for _, k := range l.ShardKeys()[0] {
+		l.Remove(k)
+	}
+	keys := l.Keys()
+	if len(keys) != l.Len() || len(keys) == 0 {
+		t.Fatalf("bad keys: %v", keys)
+	}
+	shards := l.ShardKeys()
+	for i, n := range l.ShardLens() {
+		if len(shards[i]) != n {
+			t.Fatalf("bad shard keys: %v", shards)
+		}
+	}
+}
+
+func TestHashLFUAddWithCost(t *testing.T) {
+	l, err := NewHashLFU(20, 2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		l.AddWithCost(i, i, 5, 0)
+	}
+	if l.Cost() > 20 || l.Len() > 4 {
+		t.Fatalf("bad len: %v, cost: %v", l.Len(), l.Cost())
+	}
+	if l.AddWithCost(10, 10, 11, 0) {
+		t.Errorf("10 should not have been added")
+	}
 }
 
 // HashLFU 性能压测
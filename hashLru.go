@@ -3,9 +3,10 @@ package mcache
 import (
 	"crypto/md5"
 	"github.com/songangweb/mcache/simplelru"
-	"math"
+	"io"
 	"runtime"
 	"sync"
+	"time"
 )
 
 // HashLruCache is a thread-safe fixed size LRU cache.
@@ -14,11 +15,24 @@ type HashLruCache struct {
 	list     []*HashLruCacheOne
 	sliceNum int
 	size     int
+	// resizeLock 使 Resize 依次执行, 各分片的容量保持一致
+	resizeLock sync.Mutex
+	wal        *WAL
+	settings   settingsRecorder
 }
 
 type HashLruCacheOne struct {
-	lru  simplelru.LRUCache
-	lock sync.RWMutex
+	lru   simplelru.LRUCache
+	lock  sync.RWMutex
+	stats *statsCounter
+}
+
+// acquire locks the shard for writing, recording the time spent waiting.
+// acquire 获取分片的写锁, 并记录等待时间
+func (o *HashLruCacheOne) acquire() {
+	start := time.Now()
+	o.lock.Lock()
+	o.stats.waited(time.Since(start))
 }
 
 // NewHashLRU creates an LRU of the given size.
@@ -31,6 +45,13 @@ func NewHashLRU(size, sliceNum int) (*HashLruCache, error) {
 // callback.
 // NewHashLruWithEvict 用于在缓存条目被淘汰时的回调函数
 func NewHashLruWithEvict(size, sliceNum int, onEvicted func(key interface{}, value interface{}, expirationTime int64)) (*HashLruCache, error) {
+	return NewHashLruWithEvictReason(size, sliceNum, reasonCallback(onEvicted))
+}
+
+// NewHashLruWithEvictReason constructs a fixed size cache whose eviction
+// callback receives the eviction reason.
+// NewHashLruWithEvictReason 用于在缓存条目被淘汰时的回调函数, 回调中包含淘汰原因
+func NewHashLruWithEvictReason(size, sliceNum int, onEvicted func(key interface{}, value interface{}, expirationTime int64, reason EvictionReason)) (*HashLruCache, error) {
 	if 0 == sliceNum {
 		// 设置为当前cpu数量
 		sliceNum = runtime.NumCPU()
@@ -40,15 +61,18 @@ func NewHashLruWithEvict(size, sliceNum int, onEvicted func(key interface{}, val
 	}
 
 	// 计算出每个分片的数据长度
-	lruLen := int(math.Ceil(float64(size/sliceNum)))
+	lruLen := (size + sliceNum - 1) / sliceNum
 	var h HashLruCache
 	h.size = size
 	h.sliceNum = sliceNum
 	h.list = make([]*HashLruCacheOne, sliceNum)
 	for i := 0; i < sliceNum; i++ {
-		l, _ := simplelru.NewLRU(lruLen, onEvicted)
+		stats := &statsCounter{shard: i}
+		l, _ := simplelru.NewLRUWithEvictReason(lruLen, simplelru.EvictReasonCallback(stats.evicted(onEvicted)))
+		l.SetAddCallback(stats.add)
 		h.list[i] = &HashLruCacheOne{
-			lru: l,
+			lru:   l,
+			stats: stats,
 		}
 	}
 
@@ -60,6 +84,10 @@ func NewHashLruWithEvict(size, sliceNum int, onEvicted func(key interface{}, val
 func (h *HashLruCache) Purge() {
 	for i := 0; i < h.sliceNum; i++ {
 		h.list[i].lock.Lock()
+		if i == 0 {
+			// 在分片的锁内读取 wal
+			h.wal.purge()
+		}
 		h.list[i].lru.Purge()
 		h.list[i].lock.Unlock()
 	}
@@ -80,32 +108,197 @@ func (h *HashLruCache) PurgeOverdue() {
 func (h *HashLruCache) Add(key interface{}, value interface{}, expirationTime int64) (evicted bool) {
 	sliceKey := h.modulus(&key)
 
-	h.list[sliceKey].lock.Lock()
+	h.list[sliceKey].acquire()
 	evicted = h.list[sliceKey].lru.Add(key, value, expirationTime)
+	h.logKey(sliceKey, key)
 	h.list[sliceKey].lock.Unlock()
 	return evicted
 }
 
+// AddWithCost adds a value with the given cost to the cache, evicting
+// entries until it fits. Returns false if the cost exceeds the capacity
+// of its slice.
+// AddWithCost 向缓存添加一个指定成本的值,淘汰数据直到可以放入。
+// 成本超过所在分片的容量时不会放入,返回false
+func (h *HashLruCache) AddWithCost(key interface{}, value interface{}, cost int64, expirationTime int64) (ok bool) {
+	sliceKey := h.modulus(&key)
+
+	h.list[sliceKey].acquire()
+	ok = h.list[sliceKey].lru.AddWithCost(key, value, cost, expirationTime)
+	h.logKey(sliceKey, key)
+	h.list[sliceKey].lock.Unlock()
+	return ok
+}
+
+// SetSizer sets the function used by Add to compute entry costs, the
+// capacity is then expressed as total cost instead of entry count. The
+// counters of Incr and Decr are int64 values costed by the sizer too.
+// SetSizer 设置 Add 计算条目成本的函数, 缓存容量即为总成本。Incr, Decr 的计数为 int64, 同样由 sizer 计算成本
+func (h *HashLruCache) SetSizer(sizer func(key interface{}, value interface{}) int64) {
+	h.settings.setSized(sizer != nil)
+	for i := 0; i < h.sliceNum; i++ {
+		h.list[i].lock.Lock()
+		h.list[i].lru.SetSizer(simplelru.Sizer(sizer))
+		h.list[i].lock.Unlock()
+	}
+}
+
+// SetJitter sets the jitter applied to the expiration times of added
+// entries for all slices, a zero Jitter turns it off. Every slice uses its
+// own random source derived from the seed.
+// SetJitter 为所有分片设置新增条目过期时间的随机调整, Jitter 为零值时关闭, 每个分片使用由种子派生的随机源
+func (h *HashLruCache) SetJitter(jitter Jitter) {
+	h.settings.setJitter(jitter)
+	for i := 0; i < h.sliceNum; i++ {
+		j := jitter
+		if j.Seed != 0 {
+			j.Seed += int64(i)
+		}
+		h.list[i].lock.Lock()
+		h.list[i].lru.SetJitter(j)
+		h.list[i].lock.Unlock()
+	}
+}
+
 // Get looks up a key's value from the cache.
 // Get 从缓存中查找一个键的值。
 func (h *HashLruCache) Get(key interface{}) (value interface{}, expirationTime int64, ok bool) {
 	sliceKey := h.modulus(&key)
 
-	h.list[sliceKey].lock.Lock()
+	h.list[sliceKey].acquire()
 	value, expirationTime, ok = h.list[sliceKey].lru.Get(key)
+	h.list[sliceKey].stats.get(key, ok)
 	h.list[sliceKey].lock.Unlock()
 	return value, expirationTime, ok
 }
 
+// GetWithVersion looks up a key's value and version from the cache,
+// updating the recent-ness like Get.
+// GetWithVersion 从缓存中查找一个键的值及版本号, 与 Get 一样更新缓存的状态
+func (h *HashLruCache) GetWithVersion(key interface{}) (value interface{}, expirationTime int64, version uint64, ok bool) {
+	sliceKey := h.modulus(&key)
+
+	h.list[sliceKey].acquire()
+	value, expirationTime, version, ok = h.list[sliceKey].lru.GetWithVersion(key)
+	h.list[sliceKey].stats.get(key, ok)
+	h.list[sliceKey].lock.Unlock()
+	return value, expirationTime, version, ok
+}
+
+// CompareAndSwap replaces the value of a key, keeping its expiration time,
+// if its version is expectedVersion. Returns the current version and
+// whether the value was replaced.
+// CompareAndSwap 当键的版本号等于 expectedVersion 时替换其值并保留过期时间, 返回当前版本号及是否替换成功
+func (h *HashLruCache) CompareAndSwap(key interface{}, expectedVersion uint64, value interface{}) (version uint64, swapped bool) {
+	sliceKey := h.modulus(&key)
+
+	h.list[sliceKey].acquire()
+	version, swapped = h.list[sliceKey].lru.CompareAndSwap(key, expectedVersion, value)
+	h.logKey(sliceKey, key)
+	h.list[sliceKey].lock.Unlock()
+	return version, swapped
+}
+
+// CompareAndDelete removes a key if its version is expectedVersion.
+// CompareAndDelete 当键的版本号等于 expectedVersion 时移除该键
+func (h *HashLruCache) CompareAndDelete(key interface{}, expectedVersion uint64) (deleted bool) {
+	sliceKey := h.modulus(&key)
+
+	h.list[sliceKey].acquire()
+	deleted = h.list[sliceKey].lru.CompareAndDelete(key, expectedVersion)
+	h.logKey(sliceKey, key)
+	h.list[sliceKey].lock.Unlock()
+	return deleted
+}
+
+// Update replaces the value of a key by the result of fn, keeping its
+// expiration time, atomically under the slice lock. fn receives the current
+// value and whether the key exists, the key is removed when fn returns keep
+// false and new keys never expire. Returns the value in the cache and
+// whether the key is present. fn must not use the cache.
+// Update 在分片锁内原子地用 fn 的结果替换一个键的值并保留过期时间。fn 接收当前值及键是否存在,
+// 返回 keep 为false时移除该键, 新增的键永不过期。返回缓存中的值及键是否存在。fn 中不能访问缓存
+func (h *HashLruCache) Update(key interface{}, fn func(old interface{}, exists bool) (value interface{}, keep bool)) (value interface{}, ok bool) {
+	sliceKey := h.modulus(&key)
+
+	h.list[sliceKey].acquire()
+	value, ok = h.list[sliceKey].lru.Update(key, fn)
+	h.logKey(sliceKey, key)
+	h.list[sliceKey].lock.Unlock()
+	return value, ok
+}
+
+// Compute replaces the value of a key by the result of fn with the given
+// expiration time, atomically under the slice lock. fn receives the current
+// value and whether the key exists, the key is removed when fn returns keep
+// false. Returns the value in the cache and whether the key is present.
+// fn must not use the cache.
+// Compute 在分片锁内原子地用 fn 的结果及给定的过期时间替换一个键的值。fn 接收当前值及键是否存在,
+// 返回 keep 为false时移除该键。返回缓存中的值及键是否存在。fn 中不能访问缓存
+func (h *HashLruCache) Compute(key interface{}, expirationTime int64, fn func(old interface{}, exists bool) (value interface{}, keep bool)) (value interface{}, ok bool) {
+	sliceKey := h.modulus(&key)
+
+	h.list[sliceKey].acquire()
+	value, ok = h.list[sliceKey].lru.Compute(key, expirationTime, fn)
+	h.logKey(sliceKey, key)
+	h.list[sliceKey].lock.Unlock()
+	return value, ok
+}
+
+// ComputeIfAbsent returns the value of a key like Get, or adds the result
+// of fn with the given expiration time if the key is missing and fn returns
+// keep true, atomically under the slice lock. Returns the value in the
+// cache and whether the key is present. fn must not use the cache.
+// ComputeIfAbsent 在分片锁内原子地执行: 与 Get 一样返回一个键的值, 键不存在时调用 fn,
+// 返回 keep 为true时以给定的过期时间放入缓存。返回缓存中的值及键是否存在。fn 中不能访问缓存
+func (h *HashLruCache) ComputeIfAbsent(key interface{}, expirationTime int64, fn func() (value interface{}, keep bool)) (value interface{}, ok bool) {
+	sliceKey := h.modulus(&key)
+
+	h.list[sliceKey].acquire()
+	// 键已存在时没有写入
+	exists := h.list[sliceKey].lru.Contains(key)
+	value, ok = h.list[sliceKey].lru.ComputeIfAbsent(key, expirationTime, fn)
+	if !exists {
+		h.logKey(sliceKey, key)
+	}
+	h.list[sliceKey].lock.Unlock()
+	return value, ok
+}
+
+// Incr adds delta to the counter of a key and returns the new value. A
+// missing key is created with the given expiration time, an existing one
+// keeps its expiration time. Returns ErrNotInteger if the value is not an
+// integer, ErrOverflow if the counter does not fit in an int64 and
+// ErrCounterRejected if the cache does not store it. Counters are stored as
+// int64, which a sizer must accept.
+// Incr 将一个键的计数加上 delta 并返回新值。键不存在时以给定的过期时间创建, 已存在时保留过期时间。
+// 值不是整数时返回 ErrNotInteger, 计数超出 int64 范围时返回 ErrOverflow, 缓存未保存计数时返回 ErrCounterRejected。
+// 计数以 int64 保存, sizer 需要能计算其成本
+func (h *HashLruCache) Incr(key interface{}, delta int64, expirationTime int64) (value int64, err error) {
+	sliceKey := h.modulus(&key)
+
+	h.list[sliceKey].acquire()
+	value, err = incr(lruCounter{h.list[sliceKey].lru}, key, delta, expirationTime)
+	h.logKey(sliceKey, key)
+	h.list[sliceKey].lock.Unlock()
+	return value, err
+}
+
+// Decr subtracts delta from the counter of a key, see Incr.
+// Decr 将一个键的计数减去 delta, 参见 Incr
+func (h *HashLruCache) Decr(key interface{}, delta int64, expirationTime int64) (value int64, err error) {
+	return h.Incr(key, -delta, expirationTime)
+}
+
 // Contains checks if a key is in the cache, without updating the
 // recent-ness or deleting it for being stale.
 // Contains 检查某个键是否在缓存中，但不更新缓存的状态
 func (h *HashLruCache) Contains(key interface{}) bool {
 	sliceKey := h.modulus(&key)
 
-	h.list[sliceKey].lock.RLock()
+	h.list[sliceKey].acquire()
 	containKey := h.list[sliceKey].lru.Contains(key)
-	h.list[sliceKey].lock.RUnlock()
+	h.list[sliceKey].lock.Unlock()
 	return containKey
 }
 
@@ -115,12 +308,125 @@ func (h *HashLruCache) Contains(key interface{}) bool {
 func (h *HashLruCache) Peek(key interface{}) (value interface{}, expirationTime int64, ok bool) {
 	sliceKey := h.modulus(&key)
 
-	h.list[sliceKey].lock.RLock()
+	h.list[sliceKey].acquire()
 	value, expirationTime, ok = h.list[sliceKey].lru.Peek(key)
-	h.list[sliceKey].lock.RUnlock()
+	h.list[sliceKey].lock.Unlock()
 	return value, expirationTime, ok
 }
 
+// GetEntry looks up a key's entry and metadata from the cache, updating
+// the recent-ness like Get.
+// GetEntry 从缓存中查找一个键的条目及元数据, 与 Get 一样更新缓存的状态
+func (h *HashLruCache) GetEntry(key interface{}) (e Entry, ok bool) {
+	sliceKey := h.modulus(&key)
+
+	h.list[sliceKey].acquire()
+	e, ok = h.list[sliceKey].lru.GetEntry(key)
+	h.list[sliceKey].stats.get(key, ok)
+	h.list[sliceKey].lock.Unlock()
+	return e, ok
+}
+
+// PeekEntry returns a key's entry and metadata without updating the
+// recent-ness of the key.
+// PeekEntry 返回一个键的条目及元数据, 不更新缓存的状态
+func (h *HashLruCache) PeekEntry(key interface{}) (e Entry, ok bool) {
+	sliceKey := h.modulus(&key)
+
+	h.list[sliceKey].acquire()
+	e, ok = h.list[sliceKey].lru.PeekEntry(key)
+	h.list[sliceKey].lock.Unlock()
+	return e, ok
+}
+
+// Expire sets the expiration time of a key without updating its recent-ness.
+// Expire 设置一个键的过期时间, 不更新缓存的状态
+func (h *HashLruCache) Expire(key interface{}, expirationTime int64) (ok bool) {
+	sliceKey := h.modulus(&key)
+
+	h.list[sliceKey].acquire()
+	ok = h.list[sliceKey].lru.Expire(key, expirationTime)
+	h.logKey(sliceKey, key)
+	h.list[sliceKey].lock.Unlock()
+	return ok
+}
+
+// Persist removes the expiration time of a key.
+// Persist 移除一个键的过期时间, 使其永不过期
+func (h *HashLruCache) Persist(key interface{}) (ok bool) {
+	sliceKey := h.modulus(&key)
+
+	h.list[sliceKey].acquire()
+	ok = h.list[sliceKey].lru.Persist(key)
+	h.logKey(sliceKey, key)
+	h.list[sliceKey].lock.Unlock()
+	return ok
+}
+
+// Touch extends the expiration time of a key by the lifetime it was given
+// when its expiration time was set.
+// Touch 按设置过期时间时的存活时长延长一个键的过期时间, 不更新缓存的状态
+func (h *HashLruCache) Touch(key interface{}) (ok bool) {
+	sliceKey := h.modulus(&key)
+
+	h.list[sliceKey].acquire()
+	ok = h.list[sliceKey].lru.Touch(key)
+	h.logKey(sliceKey, key)
+	h.list[sliceKey].lock.Unlock()
+	return ok
+}
+
+// TTL returns the remaining time to live of a key, 0 if it never expires.
+// TTL 返回一个键的剩余存活时间, 永不过期时为0
+func (h *HashLruCache) TTL(key interface{}) (ttl time.Duration, ok bool) {
+	sliceKey := h.modulus(&key)
+
+	h.list[sliceKey].acquire()
+	ttl, ok = h.list[sliceKey].lru.TTL(key)
+	h.list[sliceKey].lock.Unlock()
+	return ttl, ok
+}
+
+// AddSliding adds a value which expires after being idle for the given
+// duration, every Get extends its expiration time.
+// AddSliding 向缓存添加一个滑动过期的值, 空闲 idle 时长后过期, 每次 Get 都会延长过期时间
+func (h *HashLruCache) AddSliding(key, value interface{}, idle time.Duration) (ok bool) {
+	sliceKey := h.modulus(&key)
+
+	h.list[sliceKey].acquire()
+	ok = h.list[sliceKey].lru.AddSliding(key, value, idle)
+	h.logKey(sliceKey, key)
+	h.list[sliceKey].lock.Unlock()
+	return ok
+}
+
+// ExpireSliding makes a key expire after being idle for the given duration,
+// without updating its recent-ness.
+// ExpireSliding 设置一个键为滑动过期, 空闲 idle 时长后过期, 不更新缓存的状态
+func (h *HashLruCache) ExpireSliding(key interface{}, idle time.Duration) (ok bool) {
+	sliceKey := h.modulus(&key)
+
+	h.list[sliceKey].acquire()
+	ok = h.list[sliceKey].lru.ExpireSliding(key, idle)
+	h.logKey(sliceKey, key)
+	h.list[sliceKey].lock.Unlock()
+	return ok
+}
+
+// SetSlidingExpiration turns the sliding expiration mode on or off for all
+// slices. In this mode every Get extends the expiration time of an entry by
+// the lifetime it was given when its expiration time was set, Peek does not.
+// SetSlidingExpiration 为所有分片开启或关闭滑动过期模式。开启后每次 Get 都会按设置过期时间时的存活时长
+// 延长条目的过期时间, Peek 不会延长
+func (h *HashLruCache) SetSlidingExpiration(sliding bool) {
+	h.settings.setSliding(sliding)
+	for i := 0; i < h.sliceNum; i++ {
+		h.list[i].lock.Lock()
+		h.list[i].lru.SetSlidingExpiration(sliding)
+		h.list[i].lock.Unlock()
+	}
+}
+
 // ContainsOrAdd checks if a key is in the cache without updating the
 // recent-ness or deleting it for being stale, and if not, adds the value.
 // Returns whether found and whether an eviction occurred.
@@ -130,13 +436,14 @@ func (h *HashLruCache) Peek(key interface{}) (value interface{}, expirationTime
 func (h *HashLruCache) ContainsOrAdd(key interface{}, value interface{}, expirationTime int64) (ok, evicted bool) {
 	sliceKey := h.modulus(&key)
 
-	h.list[sliceKey].lock.Lock()
+	h.list[sliceKey].acquire()
 	defer h.list[sliceKey].lock.Unlock()
 
 	if h.list[sliceKey].lru.Contains(key) {
 		return true, false
 	}
 	evicted = h.list[sliceKey].lru.Add(key, value, expirationTime)
+	h.logKey(sliceKey, key)
 	return false, evicted
 }
 
@@ -149,7 +456,7 @@ func (h *HashLruCache) ContainsOrAdd(key interface{}, value interface{}, expirat
 func (h *HashLruCache) PeekOrAdd(key interface{}, value interface{}, expirationTime int64) (previous interface{}, ok, evicted bool) {
 	sliceKey := h.modulus(&key)
 
-	h.list[sliceKey].lock.Lock()
+	h.list[sliceKey].acquire()
 	defer h.list[sliceKey].lock.Unlock()
 
 	previous, expirationTime, ok = h.list[sliceKey].lru.Peek(key)
@@ -158,6 +465,7 @@ func (h *HashLruCache) PeekOrAdd(key interface{}, value interface{}, expirationT
 	}
 
 	evicted = h.list[sliceKey].lru.Add(key, value, expirationTime)
+	h.logKey(sliceKey, key)
 	return nil, false, evicted
 }
 
@@ -166,32 +474,39 @@ func (h *HashLruCache) PeekOrAdd(key interface{}, value interface{}, expirationT
 func (h *HashLruCache) Remove(key interface{}) (present bool) {
 	sliceKey := h.modulus(&key)
 
-	h.list[sliceKey].lock.Lock()
+	h.list[sliceKey].acquire()
 	present = h.list[sliceKey].lru.Remove(key)
+	h.logKey(sliceKey, key)
 	h.list[sliceKey].lock.Unlock()
 	return
 }
 
-// Resize changes the cache size.
-// Resize 调整缓存大小，返回调整前的数量
+// Resize changes the cache size, returning the number of evicted entries.
+// Resize 调整缓存大小，返回淘汰的数量
 func (h *HashLruCache) Resize(size int) (evicted int) {
 	if size < h.sliceNum {
 		size = h.sliceNum
 	}
 
 	// 计算出每个分片的数据长度
-	lruLen := int(math.Ceil(float64(size/h.sliceNum)))
+	lruLen := (size + h.sliceNum - 1) / h.sliceNum
 
+	h.resizeLock.Lock()
+	defer h.resizeLock.Unlock()
+	h.size = size
 	for i := 0; i < h.sliceNum; i++ {
 		h.list[i].lock.Lock()
-		evicted = h.list[i].lru.Resize(lruLen)
+		evicted += h.list[i].lru.Resize(lruLen)
 		h.list[i].lock.Unlock()
 	}
 	return evicted
 }
 
-// Keys returns a slice of the keys in the cache, from oldest to newest.
-// Keys 返回缓存的切片，从最老的到最新的。
+// Keys returns a slice of the keys in the cache, taking the keys of the
+// shards in turn. Each shard's keys are from oldest to newest, but the
+// result as a whole is not in eviction order; use ShardKeys for that.
+// Keys 返回缓存的切片, 依次轮流取各分片的键。每个分片的键从最老的到最新的, 但整体不按淘汰顺序排列,
+// 需要按淘汰顺序时使用 ShardKeys
 func (h *HashLruCache) Keys() []interface{} {
 
 	var keys []interface{}
@@ -215,7 +530,7 @@ func (h *HashLruCache) Keys() []interface{} {
 		allKeys[s] = oneKeys
 	}
 
-	for i := 0; i < h.list[0].lru.Len(); i++ {
+	for i := 0; i < oneKeysMaxLen; i++ {
 		for c := 0; c < len(allKeys); c++ {
 			if len(allKeys[c]) > i {
 				keys = append(keys, allKeys[c][i])
@@ -239,7 +554,248 @@ func (h *HashLruCache) Len() int {
 	return length
 }
 
+// Cap returns the capacity of the cache, a total cost when a sizer is set.
+// Cap 返回缓存的容量, 设置了成本函数时为总成本
+func (h *HashLruCache) Cap() int {
+	capacity := 0
+	for i := 0; i < h.sliceNum; i++ {
+		h.list[i].lock.RLock()
+		capacity = capacity + h.list[i].lru.Cap()
+		h.list[i].lock.RUnlock()
+	}
+	return capacity
+}
+
+// Settings returns the configuration of the cache.
+// Settings 返回缓存的配置
+func (h *HashLruCache) Settings() Settings {
+	return h.settings.get(h.Cap(), h.sliceNum)
+}
+
+// ShardKeys returns the keys of each shard, from oldest to newest.
+// ShardKeys 返回每个分片中的键, 从最老的到最新的
+func (h *HashLruCache) ShardKeys() [][]interface{} {
+	keys := make([][]interface{}, h.sliceNum)
+	for i := 0; i < h.sliceNum; i++ {
+		h.list[i].lock.RLock()
+		keys[i] = h.list[i].lru.Keys()
+		h.list[i].lock.RUnlock()
+	}
+	return keys
+}
+
+// ShardFirstKeys returns up to n keys of each shard, from oldest to newest,
+// without listing the others.
+// ShardFirstKeys 返回每个分片中至多 n 个键, 从最老的到最新的, 不列出其余的键
+func (h *HashLruCache) ShardFirstKeys(n int) [][]interface{} {
+	keys := make([][]interface{}, h.sliceNum)
+	for i := 0; i < h.sliceNum; i++ {
+		h.list[i].lock.RLock()
+		keys[i] = h.list[i].lru.FirstKeys(n)
+		h.list[i].lock.RUnlock()
+	}
+	return keys
+}
+
+// ShardLens returns the number of items in each shard.
+// ShardLens 返回每个分片中的缓存条数
+func (h *HashLruCache) ShardLens() []int {
+	lens := make([]int, h.sliceNum)
+	for i := 0; i < h.sliceNum; i++ {
+		h.list[i].lock.RLock()
+		lens[i] = h.list[i].lru.Len()
+		h.list[i].lock.RUnlock()
+	}
+	return lens
+}
+
+// ShardStats returns the statistics of each shard.
+// ShardStats 返回每个分片的统计数据
+func (h *HashLruCache) ShardStats() []ShardStats {
+	shards := make([]ShardStats, h.sliceNum)
+	for i := 0; i < h.sliceNum; i++ {
+		h.list[i].lock.RLock()
+		length := h.list[i].lru.Len()
+		h.list[i].lock.RUnlock()
+		shards[i] = shardStats(length, h.list[i].stats)
+	}
+	return shards
+}
+
+// Skew reports how keys and traffic are spread over the shards.
+// Skew 返回键和访问在各分片上的分布情况
+func (h *HashLruCache) Skew() SkewReport {
+	return skewReport(h.ShardStats())
+}
+
+// Cost returns the total cost of items in the cache.
+// Cost 获取缓存中所有条目的总成本
+func (h *HashLruCache) Cost() int64 {
+	var cost int64
+
+	for i := 0; i < h.sliceNum; i++ {
+		h.list[i].lock.RLock()
+		cost = cost + h.list[i].lru.Cost()
+		h.list[i].lock.RUnlock()
+	}
+	return cost
+}
+
+// Stats returns a snapshot of the counters of the cache.
+// Stats 返回缓存统计计数的快照
+func (h *HashLruCache) Stats() Stats {
+	var s Stats
+	for i := 0; i < h.sliceNum; i++ {
+		s.add(h.list[i].stats.snapshot())
+	}
+	return s
+}
+
+// ResetStats sets the counters of the cache to zero.
+// ResetStats 将缓存的统计计数清零
+func (h *HashLruCache) ResetStats() {
+	for i := 0; i < h.sliceNum; i++ {
+		h.list[i].stats.reset()
+	}
+}
+
+// SetHotKeys attaches a hot key tracker recording every Get and Add, its
+// top keys are reported by Stats. nil detaches it.
+// SetHotKeys 关联一个热点键追踪器, 记录每次 Get 和 Add, Stats 中会包含其统计的热点键。为 nil 时取消关联
+func (h *HashLruCache) SetHotKeys(t *HotKeys) {
+	for i := 0; i < h.sliceNum; i++ {
+		h.list[i].stats.setHot(t)
+	}
+}
+
+// RecordLoad records a load of the value of a key, e.g. by a cache-aside
+// loader after a miss, with its duration and error. The key is counted by
+// the hot key tracker like an access.
+// RecordLoad 记录一次键的值的加载, 例如未命中后由调用方加载, 包含加载耗时和错误。
+// 该键与访问一样计入热点键追踪器
+func (h *HashLruCache) RecordLoad(key interface{}, loadTime time.Duration, err error) {
+	sliceKey := h.modulus(&key)
+
+	h.list[sliceKey].stats.load(key, loadTime, err)
+}
+
+// SaveTo writes a snapshot of the cache, values encoded with gob.
+// SaveTo 将缓存的快照写入 w, 值使用 gob 编码
+func (h *HashLruCache) SaveTo(w io.Writer) error {
+	return h.SaveToWithCodec(w, nil)
+}
+
+// SaveToWithCodec writes a snapshot of the cache with its entries,
+// expiration times and eviction order, values encoded with the codec.
+// SaveToWithCodec 将缓存的快照写入 w, 包含条目, 过期时间及淘汰顺序, 值使用给定的编码
+func (h *HashLruCache) SaveToWithCodec(w io.Writer, codec Codec) error {
+	var s snapshot
+	for i := 0; i < h.sliceNum; i++ {
+		h.list[i].lock.Lock()
+		for _, key := range h.list[i].lru.Keys() {
+			if e, ok := h.list[i].lru.PeekEntry(key); ok {
+				s.records = append(s.records, snapshotRecord{list: listMain, entry: e})
+			}
+		}
+		h.list[i].lock.Unlock()
+	}
+
+	return writeSnapshot(w, "hashlru", codec, s)
+}
+
+// LoadFrom adds the entries of a snapshot written by SaveTo to the cache,
+// in their eviction order. Expired entries are skipped.
+// LoadFrom 按淘汰顺序将 SaveTo 写入的快照中的条目添加到缓存, 跳过已过期的条目
+func (h *HashLruCache) LoadFrom(r io.Reader) error {
+	return h.LoadFromWithCodec(r, nil)
+}
+
+// LoadFromWithCodec adds the entries of a snapshot written with the given
+// codec to the cache. A nil codec uses the registered codec named in the
+// snapshot.
+// LoadFromWithCodec 将使用给定编码写入的快照中的条目添加到缓存, codec 为 nil 时使用快照中记录的已注册编码
+func (h *HashLruCache) LoadFromWithCodec(r io.Reader, codec Codec) error {
+	s, err := readSnapshot(r, "hashlru", codec)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UnixNano() / 1e6
+
+	for _, rec := range s.records {
+		if !rec.live(now) {
+			continue
+		}
+		key := rec.entry.Key
+		sliceKey := h.modulus(&key)
+
+		h.list[sliceKey].lock.Lock()
+		h.list[sliceKey].lru.AddEntry(rec.entry)
+		h.list[sliceKey].lock.Unlock()
+	}
+	return nil
+}
+
 func (h *HashLruCache) modulus (key *interface{}) int {
 	str := InterfaceToString(*key)
 	return int(md5.Sum([]byte(str))[0]) % h.sliceNum
 }
+
+// setWAL attaches a write-ahead log, nil detaches it. It is read under the
+// lock of any slice, so all of them are held.
+// setWAL 关联预写日志, 为 nil 时取消关联。读取时只持有所在分片的锁, 因此这里持有所有分片的锁
+func (h *HashLruCache) setWAL(w *WAL) {
+	for i := 0; i < h.sliceNum; i++ {
+		h.list[i].lock.Lock()
+	}
+	h.wal = w
+	for i := 0; i < h.sliceNum; i++ {
+		h.list[i].lock.Unlock()
+	}
+}
+
+// replayWAL applies a record of a write-ahead log. Entries are added as
+// they were logged, their expiration times are not jittered again.
+// replayWAL 应用预写日志中的一条记录, 条目按记录原样添加, 不会再次随机调整过期时间
+func (h *HashLruCache) replayWAL(r walRecord) {
+	if r.op == walPurge {
+		h.Purge()
+		return
+	}
+	key := r.entry.Key
+	sliceKey := h.modulus(&key)
+
+	h.list[sliceKey].lock.Lock()
+	if r.op == walAdd {
+		h.list[sliceKey].lru.AddEntry(r.entry)
+	} else {
+		h.list[sliceKey].lru.Remove(key)
+	}
+	h.list[sliceKey].lock.Unlock()
+}
+
+// logKey records the entry of a key after a write, or its removal, to the
+// write-ahead log. HashThe lock of its slice must be held.
+// logKey 写操作后将键的条目或其移除记录到预写日志, 调用时需持有所在分片的锁
+func (h *HashLruCache) logKey(sliceKey int, key interface{}) {
+	if h.wal == nil {
+		return
+	}
+	if e, ok := h.list[sliceKey].lru.PeekEntry(key); ok {
+		h.wal.add(snapshotRecord{list: listMain, entry: e})
+	} else {
+		h.wal.remove(key)
+	}
+}
+
+// Store adds a value like Add, ignoring its result, so that the cache can
+// be a level of a ChainCache.
+// Store 与 Add 一样添加一个值并忽略其返回值, 以便作为 ChainCache 的一级
+func (h *HashLruCache) Store(key, value interface{}, expirationTime int64) {
+	h.Add(key, value, expirationTime)
+}
+
+// Discard removes a key like Remove and reports whether it was present.
+// Discard 与 Remove 一样移除提供的键, 返回该键是否存在
+func (h *HashLruCache) Discard(key interface{}) (present bool) {
+	return h.Remove(key)
+}
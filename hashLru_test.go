@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"sync"
 	"testing"
+	"time"
 )
 
 func BenchmarkHashLRU_Rand(b *testing.B) {
@@ -275,6 +276,154 @@ func TestHashLRUResize(t *testing.T) {
 	if !l.Contains(3) || !l.Contains(4) {
 		t.Errorf("lruCache should have contained 2 elements")
 	}
+
+	// 分片容量向上取整, 并记录新的容量
+	h, err := NewHashLRU(8, 4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	h.Resize(10)
+	if h.Cap() != 12 || h.size != 10 {
+		t.Errorf("bad cap: %v, size: %v", h.Cap(), h.size)
+	}
+}
+
+// test that AddWithCost bounds every slice by total cost
+func TestHashLRUKeys(t *testing.T) {
+	l, _ := NewHashLRU(64, 4)
+	for i := 0; i < 16; i++ {
+		l.Add(i, i, 0)
+	}
+	// 第一个分片为空时其它分片的键也要返回
+	for _, k := range l.ShardKeys()[0] {
+		l.Remove(k)
+	}
+	keys := l.Keys()
+	if len(keys) != l.Len() || len(keys) == 0 {
+		t.Fatalf("bad keys: %v", keys)
+	}
+	shards := l.ShardKeys()
+	for i, n := range l.ShardLens() {
+		if len(shards[i]) != n {
+			t.Fatalf("bad shard keys: %v", shards)
+		}
+	}
+}
+
+func TestHashLRUAddWithCost(t *testing.T) {
+	l, err := NewHashLRU(20, 2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		l.AddWithCost(i, i, 5, 0)
+	}
+	if l.Cost() > 20 || l.Len() > 4 {
+		t.Fatalf("bad len: %v, cost: %v", l.Len(), l.Cost())
+	}
+	if l.AddWithCost(10, 10, 11, 0) {
+		t.Errorf("10 should not have been added")
+	}
+}
+
+// test that the sliding expiration mode extends entries on Get
+func TestHashLRUSliding(t *testing.T) {
+	l, err := NewHashLRU(4, 2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.SetSlidingExpiration(true)
+
+	l.Add(1, 1, time.Now().UnixNano()/1e6+100)
+	l.AddSliding(2, 2, 100*time.Millisecond)
+	for i := 0; i < 3; i++ {
+		time.Sleep(50 * time.Millisecond)
+		if _, _, ok := l.Get(1); !ok {
+			t.Fatalf("1 should have been extended")
+		}
+		l.Peek(2)
+	}
+	if l.Contains(2) {
+		t.Fatalf("2 should have expired")
+	}
+}
+
+// test that the jitter applies to every slice
+func TestHashLRUJitter(t *testing.T) {
+	l, err := NewHashLRU(100, 4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.SetJitter(Jitter{Max: time.Second, Seed: 1})
+
+	expirationTime := time.Now().UnixNano()/1e6 + 100000
+	seen := make(map[int64]bool)
+	for i := 0; i < 100; i++ {
+		l.Add(i, i, expirationTime)
+		_, e, _ := l.Peek(i)
+		if e < expirationTime-1100 || e > expirationTime+1000 {
+			t.Fatalf("expiration out of range: %v", e-expirationTime)
+		}
+		seen[e] = true
+	}
+	if len(seen) < 50 {
+		t.Fatalf("expiration times should be spread: %v", len(seen))
+	}
+}
+
+// test that concurrent updates are atomic
+func TestHashLRUUpdate(t *testing.T) {
+	l, err := NewHashLRU(10, 2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 100; n++ {
+				l.Update(1, func(old interface{}, exists bool) (interface{}, bool) {
+					if !exists {
+						return 1, true
+					}
+					return old.(int) + 1, true
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if v, _, _ := l.Peek(1); v != 800 {
+		t.Fatalf("lost updates: %v", v)
+	}
+}
+
+func TestHashLRUIncr(t *testing.T) {
+	l, err := NewHashLRU(64, 4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				l.Incr(j%4, 1, 0)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := 0; i < 4; i++ {
+		if v, _, ok := l.Get(i); !ok || v != int64(200) {
+			t.Fatalf("bad: %v %v", v, ok)
+		}
+	}
 }
 
 // HashLRU 性能压测
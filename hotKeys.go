@@ -0,0 +1,267 @@
+package mcache
+
+import (
+	"container/heap"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// hotKeysBuckets is the number of sub-windows of the sliding window.
+// hotKeysBuckets 滑动窗口划分的子窗口数量
+const hotKeysBuckets = 6
+
+// hotKeysFactor is the number of counters kept per reported key, more
+// counters make the counts more accurate.
+// hotKeysFactor 每个上报的键对应保留的计数器数量, 计数器越多计数越准确
+const hotKeysFactor = 4
+
+// HotKey is a key and its access count.
+// HotKey 键及其访问次数
+type HotKey struct {
+	Key   interface{}
+	Count uint64
+}
+
+// hotKeysShards is the number of trackers a HotKeys keeps, the shards of a
+// hashed cache record to different trackers so they don't share a lock.
+// hotKeysShards HotKeys 保留的追踪器数量, 哈希缓存的各分片记录到不同的追踪器, 不共用一把锁
+const hotKeysShards = 16
+
+// HotKeys tracks the most accessed keys over a sliding window with the
+// Space-Saving algorithm: memory is bounded, the counts of the reported
+// keys may be overestimated by at most the count of the evicted counters.
+// Attach it to a cache with SetHotKeys to record every Get, write and
+// RecordLoad, the top keys are then also reported by Stats. The shards of a
+// hashed cache record to separate trackers, merged by Top.
+// HotKeys 使用 Space-Saving 算法统计滑动窗口内访问最多的键: 内存占用有上限, 上报的计数最多被高估被替换计数器的次数。
+// 通过 SetHotKeys 关联到缓存后记录每次 Get, 写入和 RecordLoad, Stats 中也会包含热点键。
+// 哈希缓存的各分片记录到各自的追踪器, Top 时合并
+type HotKeys struct {
+	seq    uint64 // 首个字段, 保证原子操作的64位对齐
+	k      int
+	span   time.Duration
+	now    func() time.Time
+	shards []*hotKeysShard
+}
+
+// hotKeysShard is the tracker of a shard, with its own sub-windows.
+// hotKeysShard 一个分片的追踪器, 包含各自的子窗口
+type hotKeysShard struct {
+	buckets []*spaceSaving
+	cur     int
+	start   time.Time
+	lock    sync.Mutex
+}
+
+// NewHotKeys creates a tracker reporting the top k keys over the given
+// window, split into sub-windows that expire one at a time. A window <= 0
+// counts since the creation or the last Reset.
+// NewHotKeys 构造一个统计给定窗口内前 k 个热点键的追踪器, 窗口划分为逐个过期的子窗口。
+// window <= 0 时统计创建或上次 Reset 以来的访问
+func NewHotKeys(k int, window time.Duration) (*HotKeys, error) {
+	if k <= 0 {
+		return nil, errors.New("must provide a positive k")
+	}
+	t := &HotKeys{
+		k:   k,
+		now: time.Now,
+	}
+	n := 1
+	if window > 0 {
+		n = hotKeysBuckets
+		t.span = window / hotKeysBuckets
+		if t.span <= 0 {
+			t.span = 1
+		}
+	}
+	start := t.now()
+	t.shards = make([]*hotKeysShard, hotKeysShards)
+	for i := range t.shards {
+		sh := &hotKeysShard{
+			buckets: make([]*spaceSaving, n),
+			start:   start,
+		}
+		for j := range sh.buckets {
+			sh.buckets[j] = newSpaceSaving(k * hotKeysFactor)
+		}
+		t.shards[i] = sh
+	}
+	return t, nil
+}
+
+// Record counts an access to the key.
+// Record 记录一次键的访问
+func (t *HotKeys) Record(key interface{}) {
+	t.record(0, key)
+}
+
+// record counts an access to the key in the tracker of the given shard.
+// record 在给定分片的追踪器中记录一次键的访问
+func (t *HotKeys) record(shard int, key interface{}) {
+	seq := atomic.AddUint64(&t.seq, 1)
+	sh := t.shards[shard%len(t.shards)]
+	sh.lock.Lock()
+	t.rotate(sh)
+	sh.buckets[sh.cur].record(key, seq)
+	sh.lock.Unlock()
+}
+
+// Top returns up to k keys with the highest access counts in the window,
+// most accessed first. Keys with the same count are ordered by the time
+// they were first counted in the window, earliest first.
+// Top 返回窗口内访问次数最多的至多 k 个键, 按访问次数从多到少排序。
+// 访问次数相同的键按其在窗口内首次被计数的先后排序
+func (t *HotKeys) Top() []HotKey {
+	counts := make(map[interface{}]uint64)
+	seqs := make(map[interface{}]uint64)
+	for _, sh := range t.shards {
+		sh.lock.Lock()
+		t.rotate(sh)
+		for _, b := range sh.buckets {
+			for _, c := range b.heap {
+				counts[c.key] += c.count
+				if seq, ok := seqs[c.key]; !ok || c.seq < seq {
+					seqs[c.key] = c.seq
+				}
+			}
+		}
+		sh.lock.Unlock()
+	}
+
+	top := make([]HotKey, 0, len(counts))
+	for key, count := range counts {
+		top = append(top, HotKey{key, count})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return seqs[top[i].Key] < seqs[top[j].Key]
+	})
+	if len(top) > t.k {
+		top = top[:t.k]
+	}
+	return top
+}
+
+// Reset clears all counts.
+// Reset 清除所有计数
+func (t *HotKeys) Reset() {
+	start := t.now()
+	for _, sh := range t.shards {
+		sh.lock.Lock()
+		for _, b := range sh.buckets {
+			b.reset()
+		}
+		sh.start = start
+		sh.lock.Unlock()
+	}
+}
+
+// rotate moves the tracker of a shard to the sub-window of the current
+// time, clearing the ones that left the window. The lock of the shard must
+// be held.
+// rotate 将分片的追踪器切换到当前时间所在的子窗口, 清除移出窗口的子窗口。调用前需持有分片的锁
+func (t *HotKeys) rotate(sh *hotKeysShard) {
+	if t.span <= 0 {
+		return
+	}
+	n := int64(t.now().Sub(sh.start) / t.span)
+	if n <= 0 {
+		return
+	}
+	sh.start = sh.start.Add(time.Duration(n) * t.span)
+	if n > int64(len(sh.buckets)) {
+		n = int64(len(sh.buckets))
+	}
+	for ; n > 0; n-- {
+		sh.cur = (sh.cur + 1) % len(sh.buckets)
+		sh.buckets[sh.cur].reset()
+	}
+}
+
+// ssCounter is a Space-Saving counter.
+// ssCounter Space-Saving 计数器
+type ssCounter struct {
+	key   interface{}
+	count uint64
+	// seq 键开始使用该计数器时的序号
+	seq   uint64
+	index int
+}
+
+// spaceSaving keeps up to capacity counters in a min-heap, a new key
+// takes over the smallest counter when it is full.
+// spaceSaving 在最小堆中保存至多 capacity 个计数器, 已满时新的键接替计数最小的计数器
+type spaceSaving struct {
+	capacity int
+	items    map[interface{}]*ssCounter
+	heap     ssHeap
+}
+
+func newSpaceSaving(capacity int) *spaceSaving {
+	return &spaceSaving{
+		capacity: capacity,
+		items:    make(map[interface{}]*ssCounter),
+	}
+}
+
+// record counts an access to the key, seq orders the keys taking a
+// counter.
+// record 记录一次键的访问, seq 为键开始使用计数器时的序号
+func (s *spaceSaving) record(key interface{}, seq uint64) {
+	if c, ok := s.items[key]; ok {
+		c.count++
+		heap.Fix(&s.heap, c.index)
+		return
+	}
+	if len(s.heap) < s.capacity {
+		c := &ssCounter{key: key, count: 1, seq: seq}
+		heap.Push(&s.heap, c)
+		s.items[key] = c
+		return
+	}
+	c := s.heap[0]
+	delete(s.items, c.key)
+	c.key = key
+	c.count++
+	c.seq = seq
+	s.items[key] = c
+	heap.Fix(&s.heap, 0)
+}
+
+// reset clears all counters.
+// reset 清除所有计数器
+func (s *spaceSaving) reset() {
+	s.items = make(map[interface{}]*ssCounter)
+	s.heap = s.heap[:0]
+}
+
+// ssHeap is a min-heap of counters implementing heap.Interface.
+// ssHeap 计数器的最小堆, 实现 heap.Interface
+type ssHeap []*ssCounter
+
+func (h ssHeap) Len() int           { return len(h) }
+func (h ssHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+func (h ssHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *ssHeap) Push(x interface{}) {
+	c := x.(*ssCounter)
+	c.index = len(*h)
+	*h = append(*h, c)
+}
+
+func (h *ssHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return c
+}
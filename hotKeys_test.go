@@ -0,0 +1,122 @@
+package mcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHotKeys(t *testing.T) {
+	h, err := NewHotKeys(2, 0)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		h.Record("a")
+		if i%2 == 0 {
+			h.Record("b")
+		}
+		// 大量只访问一次的键不会挤掉热点键
+		h.Record(i)
+	}
+
+	top := h.Top()
+	if len(top) != 2 || top[0].Key != "a" || top[1].Key != "b" {
+		t.Fatalf("bad: %v", top)
+	}
+	if top[0].Count < 100 || top[1].Count < 50 {
+		t.Fatalf("bad: %v", top)
+	}
+
+	h.Reset()
+	if top := h.Top(); len(top) != 0 {
+		t.Fatalf("bad: %v", top)
+	}
+}
+
+func TestHotKeys_Tie(t *testing.T) {
+	h, _ := NewHotKeys(3, 0)
+	for _, key := range []string{"c", "a", "b", "a", "b", "c"} {
+		h.Record(key)
+	}
+	// 计数相同时按首次计数的先后排序
+	for i := 0; i < 10; i++ {
+		top := h.Top()
+		if len(top) != 3 || top[0].Key != "c" || top[1].Key != "a" || top[2].Key != "b" {
+			t.Fatalf("bad: %v", top)
+		}
+	}
+}
+
+func TestHotKeys_Window(t *testing.T) {
+	h, err := NewHotKeys(1, 6*time.Second)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	now := time.Now()
+	h.now = func() time.Time { return now }
+	for _, sh := range h.shards {
+		sh.start = now
+	}
+
+	h.Record("a")
+	h.Record("a")
+	now = now.Add(3 * time.Second)
+	h.Record("b")
+	if top := h.Top(); top[0].Key != "a" || top[0].Count != 2 {
+		t.Fatalf("bad: %v", top)
+	}
+
+	// "a" 所在的子窗口移出窗口
+	now = now.Add(4 * time.Second)
+	if top := h.Top(); top[0].Key != "b" || top[0].Count != 1 {
+		t.Fatalf("bad: %v", top)
+	}
+
+	now = now.Add(time.Hour)
+	if top := h.Top(); len(top) != 0 {
+		t.Fatalf("bad: %v", top)
+	}
+}
+
+func TestHotKeys_Shards(t *testing.T) {
+	h, _ := NewHotKeys(2, 0)
+	// 各分片的计数在 Top 中合并
+	h.record(0, "a")
+	h.record(1, "b")
+	h.record(2, "a")
+	h.record(hotKeysShards+1, "b")
+	h.record(3, "b")
+	top := h.Top()
+	if len(top) != 2 || top[0] != (HotKey{"b", 3}) || top[1] != (HotKey{"a", 2}) {
+		t.Fatalf("bad: %v", top)
+	}
+}
+
+func TestHotKeys_Cache(t *testing.T) {
+	h, _ := NewHotKeys(1, time.Minute)
+	l, _ := NewHashLRU(64, 4)
+	l.SetHotKeys(h)
+
+	for i := 0; i < 10; i++ {
+		l.Add(i, i, 0)
+		l.Get(3)
+	}
+
+	s := l.Stats()
+	if len(s.HotKeys) != 1 || s.HotKeys[0].Key != 3 || s.HotKeys[0].Count != 11 {
+		t.Fatalf("bad: %v", s.HotKeys)
+	}
+
+	a, _ := NewARC(4)
+	if a.Stats().HotKeys != nil {
+		t.Fatalf("should not track")
+	}
+	a.SetHotKeys(h)
+	a.Get("x")
+	a.SetHotKeys(nil)
+	a.Get("y")
+	if top := h.Top(); top[0].Key != 3 {
+		t.Fatalf("bad: %v", top)
+	}
+}
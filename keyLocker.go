@@ -0,0 +1,152 @@
+package mcache
+
+import (
+	"crypto/md5"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// KeyLocker provides per-key mutual exclusion, separate from data access,
+// e.g. to fill a cache from an expensive computation only once per key.
+// Keys are sharded the same way as HashLruCache, and the lock of a key is
+// dropped as soon as nobody holds or waits for it. It is usable with any
+// cache type.
+// KeyLocker 按键互斥, 与数据访问分离, 例如从耗时的计算填充缓存时每个键只计算一次。
+// 键的分片方式与 HashLruCache 相同, 没有持有或等待者时键的锁会被立即清除, 可以配合任意缓存使用
+type KeyLocker struct {
+	list     []*keyLockerOne
+	sliceNum int
+}
+
+type keyLockerOne struct {
+	locks map[interface{}]*keyLock
+	lock  sync.Mutex
+}
+
+// keyLock is the lock of a key, held while ch is full. refs counts the
+// holder and the waiters, and is guarded by the shard lock.
+// keyLock 键的锁, ch 有值时表示已被持有。refs 为持有者和等待者的数量, 由分片锁保护
+type keyLock struct {
+	ch   chan struct{}
+	refs int
+}
+
+// NewKeyLocker creates a KeyLocker with the given number of shards, 0 means
+// the number of CPUs.
+// NewKeyLocker 构造一个给定分片数的 KeyLocker, 为 0 时使用 cpu 数量
+func NewKeyLocker(sliceNum int) *KeyLocker {
+	if 0 == sliceNum {
+		// 设置为当前cpu数量
+		sliceNum = runtime.NumCPU()
+	}
+	k := &KeyLocker{
+		list:     make([]*keyLockerOne, sliceNum),
+		sliceNum: sliceNum,
+	}
+	for i := 0; i < sliceNum; i++ {
+		k.list[i] = &keyLockerOne{
+			locks: make(map[interface{}]*keyLock),
+		}
+	}
+	return k
+}
+
+// Lock locks the key, blocking until it is available.
+// Lock 锁定键, 阻塞直到可用
+func (k *KeyLocker) Lock(key interface{}) {
+	sliceKey := k.modulus(&key)
+
+	l := k.acquire(sliceKey, key)
+	l.ch <- struct{}{}
+}
+
+// TryLock tries to lock the key within the timeout, a timeout <= 0 does not
+// wait. Returns true if the key was locked.
+// TryLock 在超时时间内尝试锁定键, timeout <= 0 时不等待。锁定成功时返回 true
+func (k *KeyLocker) TryLock(key interface{}, timeout time.Duration) (ok bool) {
+	sliceKey := k.modulus(&key)
+
+	l := k.acquire(sliceKey, key)
+	if timeout <= 0 {
+		select {
+		case l.ch <- struct{}{}:
+			return true
+		default:
+		}
+	} else {
+		t := time.NewTimer(timeout)
+		select {
+		case l.ch <- struct{}{}:
+			t.Stop()
+			return true
+		case <-t.C:
+		}
+	}
+
+	k.list[sliceKey].lock.Lock()
+	k.release(sliceKey, key, l)
+	k.list[sliceKey].lock.Unlock()
+	return false
+}
+
+// Unlock unlocks the key. It panics if the key is not locked.
+// Unlock 解锁键, 键未被锁定时 panic
+func (k *KeyLocker) Unlock(key interface{}) {
+	sliceKey := k.modulus(&key)
+
+	k.list[sliceKey].lock.Lock()
+	defer k.list[sliceKey].lock.Unlock()
+	l, ok := k.list[sliceKey].locks[key]
+	if !ok {
+		panic("mcache: unlock of unlocked key")
+	}
+	select {
+	case <-l.ch:
+	default:
+		panic("mcache: unlock of unlocked key")
+	}
+	k.release(sliceKey, key, l)
+}
+
+// Len returns the number of keys that are locked or waited for.
+// Len 返回被锁定或有等待者的键数量
+func (k *KeyLocker) Len() int {
+	length := 0
+	for i := 0; i < k.sliceNum; i++ {
+		k.list[i].lock.Lock()
+		length = length + len(k.list[i].locks)
+		k.list[i].lock.Unlock()
+	}
+	return length
+}
+
+// acquire returns the lock of the key, creating it if needed, and counts
+// the caller as a waiter.
+// acquire 返回键的锁, 不存在时创建, 并将调用方计为等待者
+func (k *KeyLocker) acquire(sliceKey int, key interface{}) *keyLock {
+	k.list[sliceKey].lock.Lock()
+	l, ok := k.list[sliceKey].locks[key]
+	if !ok {
+		l = &keyLock{ch: make(chan struct{}, 1)}
+		k.list[sliceKey].locks[key] = l
+	}
+	l.refs++
+	k.list[sliceKey].lock.Unlock()
+	return l
+}
+
+// release drops the caller from the lock of the key, removing the lock
+// once it is unused. Must be called with the shard lock held.
+// release 从键的锁中移除调用方, 不再使用时删除该锁。需要持有分片锁
+func (k *KeyLocker) release(sliceKey int, key interface{}, l *keyLock) {
+	l.refs--
+	if l.refs == 0 {
+		delete(k.list[sliceKey].locks, key)
+	}
+}
+
+func (k *KeyLocker) modulus(key *interface{}) int {
+	str := InterfaceToString(*key)
+	return int(md5.Sum([]byte(str))[0]) % k.sliceNum
+}
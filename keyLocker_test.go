@@ -0,0 +1,82 @@
+package mcache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeyLocker(t *testing.T) {
+	k := NewKeyLocker(4)
+
+	k.Lock("a")
+	if k.TryLock("a", 0) {
+		t.Fatalf("should be locked")
+	}
+	if k.TryLock("a", 10*time.Millisecond) {
+		t.Fatalf("should time out")
+	}
+	if !k.TryLock("b", 0) {
+		t.Fatalf("other keys should not be locked")
+	}
+	if k.Len() != 2 {
+		t.Fatalf("bad len: %v", k.Len())
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		k.Unlock("a")
+	}()
+	if !k.TryLock("a", time.Second) {
+		t.Fatalf("should lock after unlock")
+	}
+
+	// 不再使用的锁被清除
+	k.Unlock("a")
+	k.Unlock("b")
+	if k.Len() != 0 {
+		t.Fatalf("bad len: %v", k.Len())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("should panic")
+		}
+	}()
+	k.Unlock("a")
+}
+
+func TestKeyLocker_CacheAside(t *testing.T) {
+	l, err := NewHashLRU(64, 4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	k := NewKeyLocker(0)
+
+	var loads int
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, ok := l.Get("key"); ok {
+				return
+			}
+			k.Lock("key")
+			defer k.Unlock("key")
+			if _, _, ok := l.Get("key"); ok {
+				return
+			}
+			loads++
+			l.Add("key", "value", 0)
+		}()
+	}
+	wg.Wait()
+
+	if loads != 1 {
+		t.Fatalf("bad loads: %v", loads)
+	}
+	if k.Len() != 0 {
+		t.Fatalf("bad len: %v", k.Len())
+	}
+}
@@ -2,14 +2,19 @@ package mcache
 
 import (
 	"github.com/songangweb/mcache/simplelfu"
+	"io"
 	"sync"
+	"time"
 )
 
 // LfuCache is a thread-safe fixed size LRU cache.
 // LfuCache 实现一个给定大小的LFU缓存
 type LfuCache struct {
-	lfu  simplelfu.LFUCache
-	lock sync.RWMutex
+	lfu      simplelfu.LFUCache
+	lock     sync.RWMutex
+	stats    *statsCounter
+	wal      *WAL
+	settings settingsRecorder
 }
 
 // NewLFU creates an LRU of the given size.
@@ -22,9 +27,19 @@ func NewLFU(size int) (*LfuCache, error) {
 // callback.
 // NewLruWithEvict 用于在缓存条目被淘汰时的回调函数
 func NewLfuWithEvict(size int, onEvicted func(key interface{}, value interface{}, expirationTime int64)) (*LfuCache, error) {
-	lfu, _ := simplelfu.NewLFU(size, simplelfu.EvictCallback(onEvicted))
+	return NewLfuWithEvictReason(size, reasonCallback(onEvicted))
+}
+
+// NewLfuWithEvictReason constructs a fixed size cache whose eviction
+// callback receives the eviction reason.
+// NewLfuWithEvictReason 用于在缓存条目被淘汰时的回调函数, 回调中包含淘汰原因
+func NewLfuWithEvictReason(size int, onEvicted func(key interface{}, value interface{}, expirationTime int64, reason EvictionReason)) (*LfuCache, error) {
+	stats := &statsCounter{}
+	lfu, _ := simplelfu.NewLFUWithEvictReason(size, lfuEvictCallback(stats.evicted(onEvicted)))
+	lfu.SetAddCallback(stats.add)
 	c := &LfuCache{
-		lfu: lfu,
+		lfu:   lfu,
+		stats: stats,
 	}
 	return c, nil
 }
@@ -33,6 +48,7 @@ func NewLfuWithEvict(size int, onEvicted func(key interface{}, value interface{}
 // Purge 用于完全清除缓存
 func (c *LfuCache) Purge() {
 	c.lock.Lock()
+	c.wal.purge()
 	c.lfu.Purge()
 	c.lock.Unlock()
 }
@@ -50,26 +66,164 @@ func (c *LfuCache) PurgeOverdue() {
 func (c *LfuCache) Add(key, value interface{}, expirationTime int64) (evicted bool) {
 	c.lock.Lock()
 	evicted = c.lfu.Add(key, value, expirationTime)
+	c.logKey(key)
 	c.lock.Unlock()
 	return evicted
 }
 
+// AddWithCost adds a value with the given cost to the cache, evicting
+// entries until it fits. Returns false if the cost exceeds the capacity.
+// AddWithCost 向缓存添加一个指定成本的值,淘汰数据直到可以放入
+func (c *LfuCache) AddWithCost(key, value interface{}, cost int64, expirationTime int64) (ok bool) {
+	c.lock.Lock()
+	ok = c.lfu.AddWithCost(key, value, cost, expirationTime)
+	c.logKey(key)
+	c.lock.Unlock()
+	return ok
+}
+
+// SetSizer sets the function used by Add to compute entry costs, the
+// capacity is then expressed as total cost instead of entry count. The
+// counters of Incr and Decr are int64 values costed by the sizer too.
+// SetSizer 设置 Add 计算条目成本的函数, 缓存容量即为总成本。Incr, Decr 的计数为 int64, 同样由 sizer 计算成本
+func (c *LfuCache) SetSizer(sizer func(key interface{}, value interface{}) int64) {
+	c.settings.setSized(sizer != nil)
+	c.lock.Lock()
+	c.lfu.SetSizer(simplelfu.Sizer(sizer))
+	c.lock.Unlock()
+}
+
+// SetJitter sets the jitter applied to the expiration times of added
+// entries, a zero Jitter turns it off.
+// SetJitter 设置新增条目过期时间的随机调整, Jitter 为零值时关闭
+func (c *LfuCache) SetJitter(jitter Jitter) {
+	c.settings.setJitter(jitter)
+	c.lock.Lock()
+	c.lfu.SetJitter(simplelfu.Jitter(jitter))
+	c.lock.Unlock()
+}
+
 // Get looks up a key's value from the cache.
 // Get 从缓存中查找一个键的值
 func (c *LfuCache) Get(key interface{}) (value interface{}, expirationTime int64, ok bool) {
 	c.lock.Lock()
 	value, expirationTime, ok = c.lfu.Get(key)
+	c.stats.get(key, ok)
 	c.lock.Unlock()
 	return value, expirationTime, ok
 }
 
+// GetWithVersion looks up a key's value and version from the cache,
+// updating the weight like Get.
+// GetWithVersion 从缓存中查找一个键的值及版本号, 与 Get 一样更新缓存的状态
+func (c *LfuCache) GetWithVersion(key interface{}) (value interface{}, expirationTime int64, version uint64, ok bool) {
+	c.lock.Lock()
+	value, expirationTime, version, ok = c.lfu.GetWithVersion(key)
+	c.stats.get(key, ok)
+	c.lock.Unlock()
+	return value, expirationTime, version, ok
+}
+
+// CompareAndSwap replaces the value of a key, keeping its expiration time,
+// if its version is expectedVersion. Returns the current version and
+// whether the value was replaced.
+// CompareAndSwap 当键的版本号等于 expectedVersion 时替换其值并保留过期时间, 返回当前版本号及是否替换成功
+func (c *LfuCache) CompareAndSwap(key interface{}, expectedVersion uint64, value interface{}) (version uint64, swapped bool) {
+	c.lock.Lock()
+	version, swapped = c.lfu.CompareAndSwap(key, expectedVersion, value)
+	c.logKey(key)
+	c.lock.Unlock()
+	return version, swapped
+}
+
+// CompareAndDelete removes a key if its version is expectedVersion.
+// CompareAndDelete 当键的版本号等于 expectedVersion 时移除该键
+func (c *LfuCache) CompareAndDelete(key interface{}, expectedVersion uint64) (deleted bool) {
+	c.lock.Lock()
+	deleted = c.lfu.CompareAndDelete(key, expectedVersion)
+	c.logKey(key)
+	c.lock.Unlock()
+	return deleted
+}
+
+// Update replaces the value of a key by the result of fn, keeping its
+// expiration time, atomically under the cache lock. fn receives the current
+// value and whether the key exists, the key is removed when fn returns keep
+// false and new keys never expire. Returns the value in the cache and
+// whether the key is present. fn must not use the cache.
+// Update 在缓存锁内原子地用 fn 的结果替换一个键的值并保留过期时间。fn 接收当前值及键是否存在,
+// 返回 keep 为false时移除该键, 新增的键永不过期。返回缓存中的值及键是否存在。fn 中不能访问缓存
+func (c *LfuCache) Update(key interface{}, fn func(old interface{}, exists bool) (value interface{}, keep bool)) (value interface{}, ok bool) {
+	c.lock.Lock()
+	value, ok = c.lfu.Update(key, fn)
+	c.logKey(key)
+	c.lock.Unlock()
+	return value, ok
+}
+
+// Compute replaces the value of a key by the result of fn with the given
+// expiration time, atomically under the cache lock. fn receives the current
+// value and whether the key exists, the key is removed when fn returns keep
+// false. Returns the value in the cache and whether the key is present.
+// fn must not use the cache.
+// Compute 在缓存锁内原子地用 fn 的结果及给定的过期时间替换一个键的值。fn 接收当前值及键是否存在,
+// 返回 keep 为false时移除该键。返回缓存中的值及键是否存在。fn 中不能访问缓存
+func (c *LfuCache) Compute(key interface{}, expirationTime int64, fn func(old interface{}, exists bool) (value interface{}, keep bool)) (value interface{}, ok bool) {
+	c.lock.Lock()
+	value, ok = c.lfu.Compute(key, expirationTime, fn)
+	c.logKey(key)
+	c.lock.Unlock()
+	return value, ok
+}
+
+// ComputeIfAbsent returns the value of a key like Get, or adds the result
+// of fn with the given expiration time if the key is missing and fn returns
+// keep true, atomically under the cache lock. Returns the value in the
+// cache and whether the key is present. fn must not use the cache.
+// ComputeIfAbsent 在缓存锁内原子地执行: 与 Get 一样返回一个键的值, 键不存在时调用 fn,
+// 返回 keep 为true时以给定的过期时间放入缓存。返回缓存中的值及键是否存在。fn 中不能访问缓存
+func (c *LfuCache) ComputeIfAbsent(key interface{}, expirationTime int64, fn func() (value interface{}, keep bool)) (value interface{}, ok bool) {
+	c.lock.Lock()
+	// 键已存在时没有写入
+	exists := c.lfu.Contains(key)
+	value, ok = c.lfu.ComputeIfAbsent(key, expirationTime, fn)
+	if !exists {
+		c.logKey(key)
+	}
+	c.lock.Unlock()
+	return value, ok
+}
+
+// Incr adds delta to the counter of a key and returns the new value. A
+// missing key is created with the given expiration time, an existing one
+// keeps its expiration time. Returns ErrNotInteger if the value is not an
+// integer, ErrOverflow if the counter does not fit in an int64 and
+// ErrCounterRejected if the cache does not store it. Counters are stored as
+// int64, which a sizer must accept.
+// Incr 将一个键的计数加上 delta 并返回新值。键不存在时以给定的过期时间创建, 已存在时保留过期时间。
+// 值不是整数时返回 ErrNotInteger, 计数超出 int64 范围时返回 ErrOverflow, 缓存未保存计数时返回 ErrCounterRejected。
+// 计数以 int64 保存, sizer 需要能计算其成本
+func (c *LfuCache) Incr(key interface{}, delta int64, expirationTime int64) (value int64, err error) {
+	c.lock.Lock()
+	value, err = incr(lfuCounter{c.lfu}, key, delta, expirationTime)
+	c.logKey(key)
+	c.lock.Unlock()
+	return value, err
+}
+
+// Decr subtracts delta from the counter of a key, see Incr.
+// Decr 将一个键的计数减去 delta, 参见 Incr
+func (c *LfuCache) Decr(key interface{}, delta int64, expirationTime int64) (value int64, err error) {
+	return c.Incr(key, -delta, expirationTime)
+}
+
 // Contains checks if a key is in the cache, without updating the
 // recent-ness or deleting it for being stale.
 // Contains 检查某个键是否在缓存中，但不更新缓存的状态
 func (c *LfuCache) Contains(key interface{}) bool {
-	c.lock.RLock()
+	c.lock.Lock()
 	containKey := c.lfu.Contains(key)
-	c.lock.RUnlock()
+	c.lock.Unlock()
 	return containKey
 }
 
@@ -77,12 +231,107 @@ func (c *LfuCache) Contains(key interface{}) bool {
 // the "recently used"-ness of the key.
 // Peek 在不更新的情况下返回键值(如果没有找到则返回false),不更新缓存的状态
 func (c *LfuCache) Peek(key interface{}) (value interface{}, expirationTime int64, ok bool) {
-	c.lock.RLock()
+	c.lock.Lock()
 	value, expirationTime, ok = c.lfu.Peek(key)
-	c.lock.RUnlock()
+	c.lock.Unlock()
 	return value, expirationTime, ok
 }
 
+// GetEntry looks up a key's entry and metadata from the cache, updating
+// the weight like Get.
+// GetEntry 从缓存中查找一个键的条目及元数据, 与 Get 一样更新缓存的状态
+func (c *LfuCache) GetEntry(key interface{}) (Entry, bool) {
+	c.lock.Lock()
+	le, ok := c.lfu.GetEntry(key)
+	c.stats.get(key, ok)
+	c.lock.Unlock()
+	return Entry(le), ok
+}
+
+// PeekEntry returns a key's entry and metadata without updating the
+// weight of the key.
+// PeekEntry 返回一个键的条目及元数据, 不更新缓存的状态
+func (c *LfuCache) PeekEntry(key interface{}) (Entry, bool) {
+	c.lock.Lock()
+	le, ok := c.lfu.PeekEntry(key)
+	c.lock.Unlock()
+	return Entry(le), ok
+}
+
+// Expire sets the expiration time of a key without updating its weight.
+// Expire 设置一个键的过期时间, 不更新缓存的状态
+func (c *LfuCache) Expire(key interface{}, expirationTime int64) (ok bool) {
+	c.lock.Lock()
+	ok = c.lfu.Expire(key, expirationTime)
+	c.logKey(key)
+	c.lock.Unlock()
+	return ok
+}
+
+// Persist removes the expiration time of a key.
+// Persist 移除一个键的过期时间, 使其永不过期
+func (c *LfuCache) Persist(key interface{}) (ok bool) {
+	c.lock.Lock()
+	ok = c.lfu.Persist(key)
+	c.logKey(key)
+	c.lock.Unlock()
+	return ok
+}
+
+// Touch extends the expiration time of a key by the lifetime it was given
+// when its expiration time was set.
+// Touch 按设置过期时间时的存活时长延长一个键的过期时间, 不更新缓存的状态
+func (c *LfuCache) Touch(key interface{}) (ok bool) {
+	c.lock.Lock()
+	ok = c.lfu.Touch(key)
+	c.logKey(key)
+	c.lock.Unlock()
+	return ok
+}
+
+// TTL returns the remaining time to live of a key, 0 if it never expires.
+// TTL 返回一个键的剩余存活时间, 永不过期时为0
+func (c *LfuCache) TTL(key interface{}) (ttl time.Duration, ok bool) {
+	c.lock.Lock()
+	ttl, ok = c.lfu.TTL(key)
+	c.lock.Unlock()
+	return ttl, ok
+}
+
+// AddSliding adds a value which expires after being idle for the given
+// duration, every Get extends its expiration time.
+// AddSliding 向缓存添加一个滑动过期的值, 空闲 idle 时长后过期, 每次 Get 都会延长过期时间
+func (c *LfuCache) AddSliding(key, value interface{}, idle time.Duration) (ok bool) {
+	c.lock.Lock()
+	ok = c.lfu.AddSliding(key, value, idle)
+	c.logKey(key)
+	c.lock.Unlock()
+	return ok
+}
+
+// ExpireSliding makes a key expire after being idle for the given duration,
+// without updating its weight.
+// ExpireSliding 设置一个键为滑动过期, 空闲 idle 时长后过期, 不更新缓存的状态
+func (c *LfuCache) ExpireSliding(key interface{}, idle time.Duration) (ok bool) {
+	c.lock.Lock()
+	ok = c.lfu.ExpireSliding(key, idle)
+	c.logKey(key)
+	c.lock.Unlock()
+	return ok
+}
+
+// SetSlidingExpiration turns the sliding expiration mode on or off. In this
+// mode every Get extends the expiration time of an entry by the lifetime it
+// was given when its expiration time was set, Peek does not.
+// SetSlidingExpiration 开启或关闭滑动过期模式。开启后每次 Get 都会按设置过期时间时的存活时长
+// 延长条目的过期时间, Peek 不会延长
+func (c *LfuCache) SetSlidingExpiration(sliding bool) {
+	c.settings.setSliding(sliding)
+	c.lock.Lock()
+	c.lfu.SetSlidingExpiration(sliding)
+	c.lock.Unlock()
+}
+
 // ContainsOrAdd checks if a key is in the cache without updating the
 // recent-ness or deleting it for being stale, and if not, adds the value.
 // Returns whether found and whether an eviction occurred.
@@ -95,6 +344,7 @@ func (c *LfuCache) ContainsOrAdd(key, value interface{}, expirationTime int64) (
 		return true, false
 	}
 	evicted = c.lfu.Add(key, value, expirationTime)
+	c.logKey(key)
 	return false, evicted
 }
 
@@ -112,6 +362,7 @@ func (c *LfuCache) PeekOrAdd(key, value interface{}, expirationTime int64) (prev
 	}
 
 	evicted = c.lfu.Add(key, value, expirationTime)
+	c.logKey(key)
 	return nil, false, evicted
 }
 
@@ -120,12 +371,13 @@ func (c *LfuCache) PeekOrAdd(key, value interface{}, expirationTime int64) (prev
 func (c *LfuCache) Remove(key interface{}) (present bool) {
 	c.lock.Lock()
 	present = c.lfu.Remove(key)
+	c.logKey(key)
 	c.lock.Unlock()
 	return
 }
 
-// Resize changes the cache size.
-// Resize 调整缓存大小，返回调整前的数量
+// Resize changes the cache size, returning the number of evicted entries.
+// Resize 调整缓存大小，返回淘汰的数量
 func (c *LfuCache) Resize(size int) (evicted int) {
 	c.lock.Lock()
 	evicted = c.lfu.Resize(size)
@@ -146,6 +398,9 @@ func (c *LfuCache) ResizeWeight(percentage int){
 func (c *LfuCache) RemoveOldest() (key interface{}, value interface{}, expirationTime int64, ok bool) {
 	c.lock.Lock()
 	key, value, expirationTime, ok = c.lfu.RemoveOldest()
+	if ok {
+		c.logKey(key)
+	}
 	c.lock.Unlock()
 	return
 }
@@ -168,6 +423,25 @@ func (c *LfuCache) Keys() []interface{} {
 	return keys
 }
 
+// FirstKeys returns up to n keys of the cache, from oldest to newest, without
+// listing the others.
+// FirstKeys 返回缓存中至多 n 个键，从最老的到最新的，不列出其余的键
+func (c *LfuCache) FirstKeys(n int) []interface{} {
+	c.lock.RLock()
+	keys := c.lfu.FirstKeys(n)
+	c.lock.RUnlock()
+	return keys
+}
+
+// Cost returns the total cost of items in the cache.
+// Cost 获取缓存中所有条目的总成本
+func (c *LfuCache) Cost() int64 {
+	c.lock.RLock()
+	cost := c.lfu.Cost()
+	c.lock.RUnlock()
+	return cost
+}
+
 // Len returns the number of items in the cache.
 // Len 获取缓存已存在的缓存条数
 func (c *LfuCache) Len() int {
@@ -176,3 +450,147 @@ func (c *LfuCache) Len() int {
 	c.lock.RUnlock()
 	return length
 }
+
+// Cap returns the capacity of the cache, a total cost when a sizer is set.
+// Cap 返回缓存的容量, 设置了成本函数时为总成本
+func (c *LfuCache) Cap() int {
+	c.lock.RLock()
+	capacity := c.lfu.Cap()
+	c.lock.RUnlock()
+	return capacity
+}
+
+// Settings returns the configuration of the cache.
+// Settings 返回缓存的配置
+func (c *LfuCache) Settings() Settings {
+	return c.settings.get(c.Cap(), 1)
+}
+
+// Stats returns a snapshot of the counters of the cache.
+// Stats 返回缓存统计计数的快照
+func (c *LfuCache) Stats() Stats {
+	return c.stats.snapshot()
+}
+
+// ResetStats sets the counters of the cache to zero.
+// ResetStats 将缓存的统计计数清零
+func (c *LfuCache) ResetStats() {
+	c.stats.reset()
+}
+
+// SetHotKeys attaches a hot key tracker recording every Get and Add, its
+// top keys are reported by Stats. nil detaches it.
+// SetHotKeys 关联一个热点键追踪器, 记录每次 Get 和 Add, Stats 中会包含其统计的热点键。为 nil 时取消关联
+func (c *LfuCache) SetHotKeys(t *HotKeys) {
+	c.stats.setHot(t)
+}
+
+// RecordLoad records a load of the value of a key, e.g. by a cache-aside
+// loader after a miss, with its duration and error. The key is counted by
+// the hot key tracker like an access.
+// RecordLoad 记录一次键的值的加载, 例如未命中后由调用方加载, 包含加载耗时和错误。
+// 该键与访问一样计入热点键追踪器
+func (c *LfuCache) RecordLoad(key interface{}, loadTime time.Duration, err error) {
+	c.stats.load(key, loadTime, err)
+}
+
+// SaveTo writes a snapshot of the cache, values encoded with gob.
+// SaveTo 将缓存的快照写入 w, 值使用 gob 编码
+func (c *LfuCache) SaveTo(w io.Writer) error {
+	return c.SaveToWithCodec(w, nil)
+}
+
+// SaveToWithCodec writes a snapshot of the cache with its entries,
+// expiration times and eviction order, values encoded with the codec.
+// SaveToWithCodec 将缓存的快照写入 w, 包含条目, 过期时间及淘汰顺序, 值使用给定的编码
+func (c *LfuCache) SaveToWithCodec(w io.Writer, codec Codec) error {
+	var s snapshot
+	c.lock.Lock()
+	for _, key := range c.lfu.Keys() {
+		if e, ok := c.lfu.PeekEntry(key); ok {
+			s.records = append(s.records, snapshotRecord{list: listMain, entry: Entry(e)})
+		}
+	}
+	c.lock.Unlock()
+
+	return writeSnapshot(w, "lfu", codec, s)
+}
+
+// LoadFrom adds the entries of a snapshot written by SaveTo to the cache,
+// in their eviction order. Expired entries are skipped.
+// LoadFrom 按淘汰顺序将 SaveTo 写入的快照中的条目添加到缓存, 跳过已过期的条目
+func (c *LfuCache) LoadFrom(r io.Reader) error {
+	return c.LoadFromWithCodec(r, nil)
+}
+
+// LoadFromWithCodec adds the entries of a snapshot written with the given
+// codec to the cache. A nil codec uses the registered codec named in the
+// snapshot.
+// LoadFromWithCodec 将使用给定编码写入的快照中的条目添加到缓存, codec 为 nil 时使用快照中记录的已注册编码
+func (c *LfuCache) LoadFromWithCodec(r io.Reader, codec Codec) error {
+	s, err := readSnapshot(r, "lfu", codec)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UnixNano() / 1e6
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, rec := range s.records {
+		if rec.live(now) {
+			c.lfu.AddEntry(simplelfu.Entry(rec.entry))
+		}
+	}
+	return nil
+}
+
+// setWAL attaches a write-ahead log, nil detaches it.
+// setWAL 关联预写日志, 为 nil 时取消关联
+func (c *LfuCache) setWAL(w *WAL) {
+	c.lock.Lock()
+	c.wal = w
+	c.lock.Unlock()
+}
+
+// replayWAL applies a record of a write-ahead log. Entries are added as
+// they were logged, their expiration times are not jittered again.
+// replayWAL 应用预写日志中的一条记录, 条目按记录原样添加, 不会再次随机调整过期时间
+func (c *LfuCache) replayWAL(r walRecord) {
+	c.lock.Lock()
+	switch r.op {
+	case walAdd:
+		c.lfu.AddEntry(simplelfu.Entry(r.entry))
+	case walRemove:
+		c.lfu.Remove(r.entry.Key)
+	case walPurge:
+		c.lfu.Purge()
+	}
+	c.lock.Unlock()
+}
+
+// logKey records the entry of a key after a write, or its removal, to the
+// write-ahead log. The lock must be held.
+// logKey 写操作后将键的条目或其移除记录到预写日志, 调用时需持有锁
+func (c *LfuCache) logKey(key interface{}) {
+	if c.wal == nil {
+		return
+	}
+	if e, ok := c.lfu.PeekEntry(key); ok {
+		c.wal.add(snapshotRecord{list: listMain, entry: Entry(e)})
+	} else {
+		c.wal.remove(key)
+	}
+}
+
+// Store adds a value like Add, ignoring its result, so that the cache can
+// be a level of a ChainCache.
+// Store 与 Add 一样添加一个值并忽略其返回值, 以便作为 ChainCache 的一级
+func (c *LfuCache) Store(key, value interface{}, expirationTime int64) {
+	c.Add(key, value, expirationTime)
+}
+
+// Discard removes a key like Remove and reports whether it was present.
+// Discard 与 Remove 一样移除提供的键, 返回该键是否存在
+func (c *LfuCache) Discard(key interface{}) (present bool) {
+	return c.Remove(key)
+}
@@ -298,6 +298,29 @@ func TestLFUResize(t *testing.T) {
 
 
 
+// test that AddWithCost and the sizer bound the cache by total cost
+func TestLFUAddWithCost(t *testing.T) {
+	l, err := NewLFU(10)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.SetSizer(func(k interface{}, v interface{}) int64 {
+		return int64(len(v.([]byte)))
+	})
+
+	l.Add(1, make([]byte, 6), 0)
+	l.Add(2, make([]byte, 6), 0)
+	if l.Len() != 1 || l.Cost() != 6 {
+		t.Fatalf("bad len: %v, cost: %v", l.Len(), l.Cost())
+	}
+	if l.AddWithCost(3, 3, 20, 0) {
+		t.Errorf("3 should not have been added")
+	}
+	if !l.AddWithCost(4, 4, 4, 0) || l.Cost() != 10 {
+		t.Errorf("bad cost: %v", l.Cost())
+	}
+}
+
 // LFU 性能压测
 func TestLFU_Performance(t *testing.T) {
 	runtime.GOMAXPROCS(runtime.NumCPU())
@@ -1,11 +1,13 @@
 package mcache
 
 import (
+	"math"
 	"math/rand"
 	"runtime"
 	"strconv"
 	"sync"
 	"testing"
+	"time"
 )
 
 func BenchmarkLRU_Rand(b *testing.B) {
@@ -298,6 +300,233 @@ func TestLRUResize(t *testing.T) {
 
 
 
+// test that the eviction callback receives the reason
+func TestLRUEvictReason(t *testing.T) {
+	reasons := make(map[interface{}]EvictionReason)
+	onEvicted := func(k interface{}, v interface{}, expirationTime int64, reason EvictionReason) {
+		reasons[k] = reason
+	}
+	l, err := NewLruWithEvictReason(1, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1, 0)
+	l.Add(2, 2, 0)
+	if reasons[1] != ReasonCapacity {
+		t.Errorf("bad reason: %v", reasons[1])
+	}
+	l.Resize(0)
+	if reasons[2] != ReasonResized {
+		t.Errorf("bad reason: %v", reasons[2])
+	}
+}
+
+// test that AddWithCost and the sizer bound the cache by total cost
+func TestLRUAddWithCost(t *testing.T) {
+	l, err := NewLRU(10)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.SetSizer(func(k interface{}, v interface{}) int64 {
+		return int64(len(v.([]byte)))
+	})
+
+	l.Add(1, make([]byte, 6), 0)
+	l.Add(2, make([]byte, 6), 0)
+	if l.Len() != 1 || l.Cost() != 6 {
+		t.Fatalf("bad len: %v, cost: %v", l.Len(), l.Cost())
+	}
+	if l.AddWithCost(3, 3, 20, 0) {
+		t.Errorf("3 should not have been added")
+	}
+	if !l.AddWithCost(4, 4, 4, 0) || l.Cost() != 10 {
+		t.Errorf("bad cost: %v", l.Cost())
+	}
+}
+
+// test that GetEntry and PeekEntry return the entry metadata
+func TestLRUEntry(t *testing.T) {
+	l, err := NewLRU(2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1, 0)
+	l.Add(2, 2, 0)
+	if e, ok := l.GetEntry(1); !ok || e.Value != 1 || e.AccessCount != 1 || e.TTL != 0 {
+		t.Fatalf("bad entry: %+v", e)
+	}
+	if e, ok := l.PeekEntry(2); !ok || e.Value != 2 || e.AccessCount != 0 {
+		t.Fatalf("bad entry: %+v", e)
+	}
+
+	// PeekEntry 不更新缓存的状态
+	l.Add(3, 3, 0)
+	if l.Contains(2) {
+		t.Errorf("should not have updated recent-ness of 2")
+	}
+}
+
+// test that Expire and Persist change the expiration without re-adding
+func TestLRUExpire(t *testing.T) {
+	l, err := NewLRU(2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1, 0)
+	l.Add(2, 2, 0)
+	if !l.Expire(1, time.Now().UnixNano()/1e6+60000) {
+		t.Fatalf("1 should be contained")
+	}
+	if ttl, ok := l.TTL(1); !ok || ttl <= 0 {
+		t.Fatalf("bad ttl: %v", ttl)
+	}
+	if !l.Touch(1) || !l.Persist(1) {
+		t.Fatalf("1 should be contained")
+	}
+
+	// 1 仍是最老的数据
+	l.Add(3, 3, 0)
+	if l.Contains(1) {
+		t.Errorf("should not have updated recent-ness of 1")
+	}
+}
+
+// test that concurrent CAS loops don't lose updates
+func TestLRUCompareAndSwap(t *testing.T) {
+	l, err := NewLRU(10)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 0, 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 100; n++ {
+				for {
+					v, _, version, _ := l.GetWithVersion(1)
+					if _, ok := l.CompareAndSwap(1, version, v.(int)+1); ok {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if v, _, _ := l.Peek(1); v != 800 {
+		t.Fatalf("lost updates: %v", v)
+	}
+}
+
+func TestPeekExpired(t *testing.T) {
+	type cache interface {
+		Contains(key interface{}) bool
+		Peek(key interface{}) (value interface{}, expirationTime int64, ok bool)
+		PeekEntry(key interface{}) (e Entry, ok bool)
+		Len() int
+	}
+	lru, _ := NewLRU(64)
+	lfu, _ := NewLFU(64)
+	gdsf, _ := NewGDSF(64)
+	hashLru, _ := NewHashLRU(64, 2)
+	hashLfu, _ := NewHashLFU(64, 2)
+	arc, _ := NewARC(64)
+	twoQueue, _ := New2Q(64)
+	adds := map[string]func(key int, expirationTime int64){
+		"lru":     func(key int, exp int64) { lru.Add(key, key, exp) },
+		"lfu":     func(key int, exp int64) { lfu.Add(key, key, exp) },
+		"gdsf":    func(key int, exp int64) { gdsf.Add(key, key, exp) },
+		"hashlru": func(key int, exp int64) { hashLru.Add(key, key, exp) },
+		"hashlfu": func(key int, exp int64) { hashLfu.Add(key, key, exp) },
+		"arc":     func(key int, exp int64) { arc.Add(key, key, exp) },
+		"2q":      func(key int, exp int64) { twoQueue.Add(key, key, exp) },
+	}
+	caches := map[string]cache{"lru": lru, "lfu": lfu, "gdsf": gdsf, "hashlru": hashLru, "hashlfu": hashLfu, "arc": arc, "2q": twoQueue}
+	for name, c := range caches {
+		exp := time.Now().UnixNano()/1e6 - 1
+		for i := 0; i < 32; i++ {
+			adds[name](i, exp)
+		}
+		// 查看过期的条目时会删除它们, 并发执行时不能只持有读锁
+		var wg sync.WaitGroup
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 32; i++ {
+					c.Contains(i)
+					c.Peek(i)
+					c.PeekEntry(i)
+				}
+			}()
+		}
+		wg.Wait()
+		if c.Len() != 0 {
+			t.Fatalf("%s: bad len: %v", name, c.Len())
+		}
+	}
+}
+
+func TestLRUIncr(t *testing.T) {
+	l, err := NewLRU(8)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if v, err := l.Incr("a", 2, 0); err != nil || v != 2 {
+		t.Fatalf("bad: %v %v", v, err)
+	}
+	if v, err := l.Incr("a", 3, 0); err != nil || v != 5 {
+		t.Fatalf("bad: %v %v", v, err)
+	}
+	if v, err := l.Decr("a", 6, 0); err != nil || v != -1 {
+		t.Fatalf("bad: %v %v", v, err)
+	}
+
+	// 已存在的键保留过期时间
+	exp := time.Now().Add(time.Hour).UnixNano() / 1e6
+	l.Incr("b", 1, exp)
+	l.Incr("b", 1, 0)
+	if v, e, ok := l.Get("b"); !ok || v != int64(2) || e != exp {
+		t.Fatalf("bad: %v %v %v", v, e, ok)
+	}
+
+	// 其他整数类型按 int64 累加
+	l.Add("c", 3, 0)
+	if v, err := l.Incr("c", 1, 0); err != nil || v != 4 {
+		t.Fatalf("bad: %v %v", v, err)
+	}
+
+	l.Add("d", "x", 0)
+	if _, err := l.Incr("d", 1, 0); err != ErrNotInteger {
+		t.Fatalf("bad: %v", err)
+	}
+
+	// 超出 int64 范围的计数返回错误
+	l.Add("e", uint64(math.MaxUint64), 0)
+	if _, err := l.Incr("e", 1, 0); err != ErrOverflow {
+		t.Fatalf("bad: %v", err)
+	}
+	l.Add("f", int64(math.MaxInt64), 0)
+	if _, err := l.Incr("f", 1, 0); err != ErrOverflow {
+		t.Fatalf("bad: %v", err)
+	}
+
+	// 缓存未保存的计数返回错误
+	l.SetSizer(func(key interface{}, value interface{}) int64 {
+		return 16
+	})
+	if _, err := l.Incr("g", 1, 0); err != ErrCounterRejected {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
 // Hash 性能压测
 func TestLRU_Performance(t *testing.T) {
 	//fmt.Println("runtime.NumCPU(): ", runtime.NumCPU())
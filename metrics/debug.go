@@ -0,0 +1,288 @@
+package metrics
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/songangweb/mcache"
+)
+
+// keysCache is implemented by the caches that can list their keys, from
+// the first to be evicted to the last.
+// keysCache 可以按淘汰顺序列出键的缓存实现了该接口
+type keysCache interface {
+	Keys() []interface{}
+}
+
+// firstKeysCache is implemented by the caches that can list their first
+// keys without listing all of them.
+// firstKeysCache 可以只列出前 n 个键的缓存实现了该接口
+type firstKeysCache interface {
+	FirstKeys(n int) []interface{}
+}
+
+// shardKeysCache is implemented by the hashed caches, whose keys are only
+// in eviction order within each shard.
+// shardKeysCache 哈希缓存实现了该接口, 其键只在每个分片内按淘汰顺序排列
+type shardKeysCache interface {
+	ShardFirstKeys(n int) [][]interface{}
+}
+
+// settingsCache is implemented by the caches that report their
+// configuration.
+// settingsCache 可以返回其配置的缓存实现了该接口
+type settingsCache interface {
+	Settings() mcache.Settings
+}
+
+// CacheInfo describes a registered cache for inspection.
+// CacheInfo 用于查看的已注册缓存信息
+type CacheInfo struct {
+	Name   string      `json:"name"`
+	Type   string      `json:"type"`
+	Len    int         `json:"len"`
+	Cap    int         `json:"cap"`
+	Shards []int       `json:"shards,omitempty"`
+	Config *ConfigInfo `json:"config,omitempty"`
+	Stats  StatsInfo   `json:"stats"`
+	// Keys 前 N 个键, 哈希缓存不提供
+	Keys []string `json:"keys,omitempty"`
+	// KeysOrder Keys 的顺序: KeysOrderEviction 或 KeysOrderLists
+	KeysOrder string `json:"keys_order,omitempty"`
+	// ShardKeys 哈希缓存每个分片中按淘汰顺序排列的前 N 个键
+	ShardKeys [][]string `json:"shard_keys,omitempty"`
+}
+
+const (
+	// KeysOrderEviction means the keys are listed from the first to be
+	// evicted.
+	// KeysOrderEviction 键从最先被淘汰的开始排列
+	KeysOrderEviction = "eviction"
+
+	// KeysOrderLists means the keys of the internal lists of an ARC or 2Q
+	// cache are listed one list after the other, each from oldest to newest,
+	// which is not the eviction order.
+	// KeysOrderLists ARC, 2Q 缓存的各内部列表的键依次排列, 各自从最老到最新, 不是淘汰顺序
+	KeysOrderLists = "lists"
+)
+
+// ConfigInfo is the JSON form of mcache.Settings.
+// ConfigInfo mcache.Settings 的 JSON 形式
+type ConfigInfo struct {
+	Size    int        `json:"size"`
+	Shards  int        `json:"shards"`
+	Sized   bool       `json:"sized"`
+	Sliding bool       `json:"sliding"`
+	Jitter  JitterInfo `json:"jitter"`
+}
+
+// JitterInfo is the JSON form of mcache.Jitter.
+// JitterInfo mcache.Jitter 的 JSON 形式
+type JitterInfo struct {
+	Percent float64 `json:"percent"`
+	Max     string  `json:"max"`
+	Seed    int64   `json:"seed"`
+}
+
+// StatsInfo is the JSON form of mcache.Stats.
+// StatsInfo mcache.Stats 的 JSON 形式
+type StatsInfo struct {
+	Hits        uint64            `json:"hits"`
+	Misses      uint64            `json:"misses"`
+	HitRatio    float64           `json:"hit_ratio"`
+	Adds        uint64            `json:"adds"`
+	Updates     uint64            `json:"updates"`
+	Evictions   map[string]uint64 `json:"evictions"`
+	Expirations uint64            `json:"expirations"`
+	Loads       uint64            `json:"loads"`
+	LoadErrors  uint64            `json:"load_errors"`
+	LoadTime    string            `json:"load_time"`
+	HotKeys     []HotKeyInfo      `json:"hot_keys,omitempty"`
+}
+
+// HotKeyInfo is the JSON form of mcache.HotKey.
+// HotKeyInfo mcache.HotKey 的 JSON 形式
+type HotKeyInfo struct {
+	Key   string `json:"key"`
+	Count uint64 `json:"count"`
+}
+
+// Info returns the description of the registered caches sorted by name,
+// with the first keys keys of each cache, or of each shard of a hashed
+// cache, in eviction order except for ARC and 2Q caches, see KeysOrder.
+// Info 返回按名称排序的已注册缓存信息, 包含每个缓存(哈希缓存为每个分片)的前 keys 个键,
+// 除 ARC, 2Q 缓存外按淘汰顺序排列, 参见 KeysOrder
+func (r *Registry) Info(keys int) []CacheInfo {
+	names, caches := r.snapshot()
+	infos := make([]CacheInfo, 0, len(names))
+	for _, name := range names {
+		infos = append(infos, cacheInfo(name, caches[name], keys))
+	}
+	return infos
+}
+
+// DebugHandler returns an http.Handler listing the registered caches as
+// JSON, with the first keys keys of each cache. The cache query parameter
+// selects one cache and the keys parameter overrides the number of keys.
+// DebugHandler 返回以 JSON 列出已注册缓存的 http.Handler, 包含每个缓存的前 keys 个键。
+// 查询参数 cache 选择单个缓存, 参数 keys 指定键的数量
+func (r *Registry) DebugHandler(keys int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := keys
+		if v := req.URL.Query().Get("keys"); v != "" {
+			var err error
+			if n, err = strconv.Atoi(v); err != nil {
+				http.Error(w, "bad keys parameter", http.StatusBadRequest)
+				return
+			}
+		}
+
+		var body interface{}
+		if name := req.URL.Query().Get("cache"); name != "" {
+			r.lock.RLock()
+			c, ok := r.caches[name]
+			r.lock.RUnlock()
+			if !ok {
+				http.Error(w, "cache not found", http.StatusNotFound)
+				return
+			}
+			body = cacheInfo(name, c, n)
+		} else {
+			body = r.Info(n)
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(body)
+	})
+}
+
+// Publish publishes the registered caches, without keys, as an expvar
+// variable with the given name. Like expvar.Publish it panics if the name
+// is already in use.
+// Publish 以给定名称将已注册缓存的信息(不含键)发布为 expvar 变量。与 expvar.Publish 一样, 名称已被使用时 panic
+func (r *Registry) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return r.Info(0)
+	}))
+}
+
+// DebugHandler returns the debug handler of the default registry.
+// DebugHandler 返回默认注册表的调试 http.Handler
+func DebugHandler(keys int) http.Handler {
+	return DefaultRegistry.DebugHandler(keys)
+}
+
+// snapshot returns the registered names in order and the caches.
+// snapshot 返回排序后的已注册名称和缓存
+func (r *Registry) snapshot() ([]string, map[string]Cache) {
+	r.lock.RLock()
+	names := make([]string, 0, len(r.caches))
+	caches := make(map[string]Cache, len(r.caches))
+	for name, c := range r.caches {
+		names = append(names, name)
+		caches[name] = c
+	}
+	r.lock.RUnlock()
+	sort.Strings(names)
+	return names, caches
+}
+
+// cacheInfo describes a cache.
+// cacheInfo 生成缓存的描述信息
+func cacheInfo(name string, c Cache, keys int) CacheInfo {
+	info := CacheInfo{
+		Name:  name,
+		Type:  fmt.Sprintf("%T", c),
+		Len:   c.Len(),
+		Cap:   c.Cap(),
+		Stats: statsInfo(c.Stats()),
+	}
+	if sc, ok := c.(shardedCache); ok {
+		info.Shards = sc.ShardLens()
+	}
+	if sc, ok := c.(settingsCache); ok {
+		info.Config = configInfo(sc.Settings())
+	}
+	if keys <= 0 {
+		return info
+	}
+	// 哈希缓存的键只在分片内有序, 按分片列出
+	if kc, ok := c.(shardKeysCache); ok {
+		for _, shard := range kc.ShardFirstKeys(keys) {
+			info.ShardKeys = append(info.ShardKeys, firstKeys(shard, keys))
+		}
+		return info
+	}
+	if kc, ok := c.(firstKeysCache); ok {
+		info.Keys = firstKeys(kc.FirstKeys(keys), keys)
+	} else if kc, ok := c.(keysCache); ok {
+		info.Keys = firstKeys(kc.Keys(), keys)
+	} else {
+		return info
+	}
+	info.KeysOrder = KeysOrderEviction
+	switch c.(type) {
+	case *mcache.ARCCache, *mcache.TwoQueueCache:
+		info.KeysOrder = KeysOrderLists
+	}
+	return info
+}
+
+// firstKeys returns the first n keys as strings.
+// firstKeys 以字符串形式返回前 n 个键
+func firstKeys(all []interface{}, n int) []string {
+	if len(all) > n {
+		all = all[:n]
+	}
+	keys := make([]string, len(all))
+	for i, k := range all {
+		keys[i] = fmt.Sprint(k)
+	}
+	return keys
+}
+
+// configInfo converts the settings to their JSON form.
+// configInfo 将配置转换为 JSON 形式
+func configInfo(s mcache.Settings) *ConfigInfo {
+	return &ConfigInfo{
+		Size:    s.Size,
+		Shards:  s.Shards,
+		Sized:   s.Sized,
+		Sliding: s.Sliding,
+		Jitter: JitterInfo{
+			Percent: s.Jitter.Percent,
+			Max:     s.Jitter.Max.String(),
+			Seed:    s.Jitter.Seed,
+		},
+	}
+}
+
+// statsInfo converts the stats to their JSON form.
+// statsInfo 将统计数据转换为 JSON 形式
+func statsInfo(s mcache.Stats) StatsInfo {
+	info := StatsInfo{
+		Hits:        s.Hits,
+		Misses:      s.Misses,
+		HitRatio:    s.HitRatio(),
+		Adds:        s.Adds,
+		Updates:     s.Updates,
+		Evictions:   make(map[string]uint64, len(s.Evictions)),
+		Expirations: s.Expirations,
+		Loads:       s.Loads,
+		LoadErrors:  s.LoadErrors,
+		LoadTime:    s.LoadTime.String(),
+	}
+	for reason, n := range s.Evictions {
+		info.Evictions[reason.String()] = n
+	}
+	for _, h := range s.HotKeys {
+		info.HotKeys = append(info.HotKeys, HotKeyInfo{fmt.Sprint(h.Key), h.Count})
+	}
+	return info
+}
@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/songangweb/mcache"
+)
+
+func TestDebugHandler(t *testing.T) {
+	r := NewRegistry()
+	l, _ := mcache.NewLRU(8)
+	h, _ := mcache.NewHashLRU(8, 2)
+	r.Register("users", l)
+	r.Register("sessions", h)
+	hot, _ := mcache.NewHotKeys(1, 0)
+	l.SetHotKeys(hot)
+	l.SetJitter(mcache.Jitter{Percent: 10})
+	h.SetSlidingExpiration(true)
+	for i := 0; i < 6; i++ {
+		h.Add(i, i, 0)
+	}
+
+	for i := 0; i < 5; i++ {
+		l.Add(i, i, 0)
+	}
+	// 4 接替了 0 的计数器, 0 再接替另一个计数器, 两者计数相同, 先被计数的 4 排在前面
+	l.Get(0)
+
+	rec := httptest.NewRecorder()
+	r.DebugHandler(3).ServeHTTP(rec, httptest.NewRequest("GET", "/debug/mcache", nil))
+	var infos []CacheInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &infos); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(infos) != 2 || infos[0].Name != "sessions" || len(infos[0].Shards) != 2 {
+		t.Fatalf("bad: %+v", infos)
+	}
+	// 哈希缓存的键按分片列出
+	sessions := infos[0]
+	if sessions.Keys != nil || len(sessions.ShardKeys) != 2 {
+		t.Fatalf("bad keys: %v %v", sessions.Keys, sessions.ShardKeys)
+	}
+	for i, keys := range sessions.ShardKeys {
+		want := sessions.Shards[i]
+		if want > 3 {
+			want = 3
+		}
+		if len(keys) != want {
+			t.Fatalf("bad keys: %v %v", sessions.Shards, sessions.ShardKeys)
+		}
+	}
+	if c := sessions.Config; c == nil || c.Size != 8 || c.Shards != 2 || !c.Sliding || c.Jitter.Percent != 0 {
+		t.Fatalf("bad config: %+v", c)
+	}
+	users := infos[1]
+	if users.Type != "*mcache.LruCache" || users.Len != 5 || users.Cap != 8 || users.Stats.Hits != 1 {
+		t.Fatalf("bad: %+v", users)
+	}
+	// 按淘汰顺序, 0 被访问后最后淘汰
+	if len(users.Keys) != 3 || users.Keys[0] != "1" || users.Keys[2] != "3" || users.KeysOrder != KeysOrderEviction {
+		t.Fatalf("bad keys: %v %v", users.Keys, users.KeysOrder)
+	}
+	if c := users.Config; c == nil || c.Size != 8 || c.Shards != 1 || c.Sliding || c.Jitter.Percent != 10 {
+		t.Fatalf("bad config: %+v", c)
+	}
+	if len(users.Stats.HotKeys) != 1 || users.Stats.HotKeys[0] != (HotKeyInfo{"4", 2}) {
+		t.Fatalf("bad hot keys: %v", users.Stats.HotKeys)
+	}
+
+	rec = httptest.NewRecorder()
+	r.DebugHandler(3).ServeHTTP(rec, httptest.NewRequest("GET", "/debug/mcache?cache=users&keys=10", nil))
+	var info CacheInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(info.Keys) != 5 || info.Keys[4] != "0" {
+		t.Fatalf("bad keys: %v", info.Keys)
+	}
+
+	rec = httptest.NewRecorder()
+	r.DebugHandler(3).ServeHTTP(rec, httptest.NewRequest("GET", "/debug/mcache?cache=none", nil))
+	if rec.Code != 404 {
+		t.Fatalf("bad code: %v", rec.Code)
+	}
+
+	r.Publish("mcache_test")
+	var published []CacheInfo
+	if err := json.Unmarshal([]byte(expvar.Get("mcache_test").String()), &published); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(published) != 2 || published[1].Keys != nil {
+		t.Fatalf("bad: %+v", published)
+	}
+
+	// ARC 的键按内部列表依次排列, 不是淘汰顺序
+	a, _ := mcache.NewARC(4)
+	a.Add(1, 1, 0)
+	a.Add(2, 2, 0)
+	a.Get(1)
+	if info := cacheInfo("arc", a, 3); len(info.Keys) != 2 || info.Keys[0] != "2" || info.KeysOrder != KeysOrderLists {
+		t.Fatalf("bad keys: %v %v", info.Keys, info.KeysOrder)
+	}
+}
@@ -0,0 +1,227 @@
+// Package metrics exposes the statistics of mcache instances in the
+// Prometheus text format, without depending on the Prometheus client.
+// Package metrics 以 Prometheus 文本格式暴露 mcache 实例的统计数据, 不依赖 Prometheus 客户端
+package metrics
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/songangweb/mcache"
+)
+
+// Cache is implemented by every cache type of mcache.
+// Cache mcache 的所有缓存类型都实现了该接口
+type Cache interface {
+	Len() int
+	Cap() int
+	Stats() mcache.Stats
+}
+
+// shardedCache is implemented by the hashed caches.
+// shardedCache 哈希缓存实现了该接口
+type shardedCache interface {
+	ShardLens() []int
+}
+
+// Registry holds the caches to report, by name.
+// Registry 按名称保存需要上报的缓存
+type Registry struct {
+	caches map[string]Cache
+	lock   sync.RWMutex
+}
+
+// DefaultRegistry is the registry used by Register, Unregister and Handler.
+// DefaultRegistry Register, Unregister, Handler 使用的默认注册表
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry creates an empty registry.
+// NewRegistry 构造一个空的注册表
+func NewRegistry() *Registry {
+	return &Registry{caches: make(map[string]Cache)}
+}
+
+// Register adds a cache to the default registry.
+// Register 向默认注册表添加一个缓存
+func Register(name string, c Cache) error {
+	return DefaultRegistry.Register(name, c)
+}
+
+// Unregister removes a cache from the default registry.
+// Unregister 从默认注册表移除一个缓存
+func Unregister(name string) {
+	DefaultRegistry.Unregister(name)
+}
+
+// Handler returns an http.Handler serving the metrics of the default
+// registry.
+// Handler 返回输出默认注册表指标的 http.Handler
+func Handler() http.Handler {
+	return DefaultRegistry
+}
+
+// Register adds a cache under the given name, which is used as the value
+// of the cache label. Returns an error if the name is already registered.
+// Register 以给定名称添加一个缓存, 名称作为 cache 标签的值。名称已注册时返回错误
+func (r *Registry) Register(name string, c Cache) error {
+	if c == nil {
+		return errors.New("must provide a cache")
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.caches[name]; ok {
+		return errors.New("cache already registered: " + name)
+	}
+	r.caches[name] = c
+	return nil
+}
+
+// Unregister removes the cache with the given name.
+// Unregister 移除给定名称的缓存
+func (r *Registry) Unregister(name string) {
+	r.lock.Lock()
+	delete(r.caches, name)
+	r.lock.Unlock()
+}
+
+// ServeHTTP writes the metrics of the registered caches.
+// ServeHTTP 输出已注册缓存的指标
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w)
+}
+
+// sample is a sample of a metric family.
+// sample 指标的一个样本
+type sample struct {
+	labels string
+	value  string
+}
+
+// family is a metric family of the exposition.
+// family 输出中的一个指标
+type family struct {
+	name    string
+	help    string
+	typ     string
+	samples []sample
+}
+
+// WriteTo writes the metrics of the registered caches in the Prometheus
+// text format, sorted by cache name.
+// WriteTo 以 Prometheus 文本格式输出已注册缓存的指标, 按缓存名称排序
+func (r *Registry) WriteTo(w io.Writer) (n int64, err error) {
+	names, caches := r.snapshot()
+
+	entries := &family{name: "mcache_entries", help: "Number of entries in the cache.", typ: "gauge"}
+	capacity := &family{name: "mcache_capacity", help: "Capacity of the cache.", typ: "gauge"}
+	hits := &family{name: "mcache_hits_total", help: "Number of lookups that found the key.", typ: "counter"}
+	misses := &family{name: "mcache_misses_total", help: "Number of lookups that missed the key.", typ: "counter"}
+	ratio := &family{name: "mcache_hit_ratio", help: "Ratio of hits to lookups.", typ: "gauge"}
+	adds := &family{name: "mcache_adds_total", help: "Number of values stored by Add.", typ: "counter"}
+	updates := &family{name: "mcache_updates_total", help: "Number of values replaced by a write.", typ: "counter"}
+	evictions := &family{name: "mcache_evictions_total", help: "Number of entries evicted, by reason.", typ: "counter"}
+	expirations := &family{name: "mcache_expirations_total", help: "Number of entries removed after expiring.", typ: "counter"}
+	loads := &family{name: "mcache_loads_total", help: "Number of recorded loads.", typ: "counter"}
+	loadErrors := &family{name: "mcache_load_errors_total", help: "Number of recorded loads that failed.", typ: "counter"}
+	loadSeconds := &family{name: "mcache_load_seconds_total", help: "Total duration of the recorded loads.", typ: "counter"}
+	shards := &family{name: "mcache_shard_entries", help: "Number of entries in each shard of a hashed cache.", typ: "gauge"}
+
+	for _, name := range names {
+		c := caches[name]
+		s := c.Stats()
+		l := label("cache", name)
+
+		entries.add(l, formatInt(int64(c.Len())))
+		capacity.add(l, formatInt(int64(c.Cap())))
+		hits.add(l, formatUint(s.Hits))
+		misses.add(l, formatUint(s.Misses))
+		ratio.add(l, formatFloat(s.HitRatio()))
+		adds.add(l, formatUint(s.Adds))
+		updates.add(l, formatUint(s.Updates))
+		reasons := make([]int, 0, len(s.Evictions))
+		for reason := range s.Evictions {
+			reasons = append(reasons, int(reason))
+		}
+		sort.Ints(reasons)
+		for _, reason := range reasons {
+			er := mcache.EvictionReason(reason)
+			evictions.add(l+","+label("reason", er.String()), formatUint(s.Evictions[er]))
+		}
+		expirations.add(l, formatUint(s.Expirations))
+		loads.add(l, formatUint(s.Loads))
+		loadErrors.add(l, formatUint(s.LoadErrors))
+		loadSeconds.add(l, formatFloat(s.LoadTime.Seconds()))
+		if sc, ok := c.(shardedCache); ok {
+			for i, length := range sc.ShardLens() {
+				shards.add(l+","+label("shard", strconv.Itoa(i)), formatInt(int64(length)))
+			}
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	cw := &countWriter{w: bw}
+	for _, f := range []*family{entries, capacity, hits, misses, ratio, adds, updates, evictions, expirations, loads, loadErrors, loadSeconds, shards} {
+		f.write(cw)
+	}
+	err = bw.Flush()
+	return cw.n, err
+}
+
+// add appends a sample.
+// add 添加一个样本
+func (f *family) add(labels string, value string) {
+	f.samples = append(f.samples, sample{labels, value})
+}
+
+// write writes the family, nothing when it has no samples.
+// write 输出指标, 没有样本时不输出
+func (f *family) write(w io.Writer) {
+	if len(f.samples) == 0 {
+		return
+	}
+	io.WriteString(w, "# HELP "+f.name+" "+f.help+"\n")
+	io.WriteString(w, "# TYPE "+f.name+" "+f.typ+"\n")
+	for _, s := range f.samples {
+		io.WriteString(w, f.name+"{"+s.labels+"} "+s.value+"\n")
+	}
+}
+
+// countWriter counts the bytes written.
+// countWriter 统计写入的字节数
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// labelEscaper escapes label values as required by the text format.
+// labelEscaper 按文本格式的要求转义标签值
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func label(name, value string) string {
+	return name + `="` + labelEscaper.Replace(value) + `"`
+}
+
+func formatInt(v int64) string {
+	return strconv.FormatInt(v, 10)
+}
+
+func formatUint(v uint64) string {
+	return strconv.FormatUint(v, 10)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/songangweb/mcache"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+
+	l, _ := mcache.NewLRU(2)
+	h, _ := mcache.NewHashLRU(8, 2)
+	if err := r.Register("users", l); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := r.Register(`a"b`, h); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := r.Register("users", l); err == nil {
+		t.Fatalf("should fail")
+	}
+
+	l.Add(1, 1, 0)
+	l.Add(2, 2, 0)
+	l.Add(3, 3, 0)
+	l.Get(3)
+	l.Get(1)
+	h.Add(1, 1, 0)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("bad content type: %v", rec.Header().Get("Content-Type"))
+	}
+
+	for _, line := range []string{
+		"# TYPE mcache_entries gauge",
+		`mcache_entries{cache="users"} 2`,
+		`mcache_capacity{cache="users"} 2`,
+		`mcache_hits_total{cache="users"} 1`,
+		`mcache_misses_total{cache="users"} 1`,
+		`mcache_hit_ratio{cache="users"} 0.5`,
+		`mcache_evictions_total{cache="users",reason="capacity"} 1`,
+		`mcache_entries{cache="a\"b"} 1`,
+		`mcache_shard_entries{cache="a\"b",shard="1"}`,
+	} {
+		if !strings.Contains(body, line) {
+			t.Fatalf("missing %q in:\n%s", line, body)
+		}
+	}
+
+	r.Unregister("users")
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if strings.Contains(rec.Body.String(), `cache="users"`) {
+		t.Fatalf("should be unregistered")
+	}
+}
+
+func TestCacheTypes(t *testing.T) {
+	lru, _ := mcache.NewLRU(4)
+	lfu, _ := mcache.NewLFU(4)
+	gdsf, _ := mcache.NewGDSF(4)
+	hashLru, _ := mcache.NewHashLRU(4, 2)
+	hashLfu, _ := mcache.NewHashLFU(4, 2)
+	arc, _ := mcache.NewARC(4)
+	twoQueue, _ := mcache.New2Q(4)
+
+	for _, c := range []Cache{lru, lfu, gdsf, hashLru, hashLfu, arc, twoQueue} {
+		if c.Cap() != 4 {
+			t.Fatalf("bad cap: %v", c.Cap())
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"errors"
+	"time"
+)
+
+// FixedWindow allows up to limit events per key in each window. Windows
+// are aligned to the unix epoch; reserved events beyond the limit are
+// placed in the following windows.
+// FixedWindow 固定窗口限流器, 每个键在每个窗口内最多允许 limit 次事件。
+// 窗口按 unix 纪元对齐, 超出限制的预约事件顺延到后续窗口
+type FixedWindow struct {
+	store
+	limit  int64
+	window int64
+}
+
+// fixedWindowState is the state of a key, count may exceed limit when
+// events are reserved in later windows.
+// fixedWindowState 键的状态, 预约到后续窗口时 count 可以超过 limit
+type fixedWindowState struct {
+	start int64
+	count int64
+}
+
+// NewFixedWindow creates a fixed window limiter tracking up to size keys.
+// NewFixedWindow 构造一个最多跟踪 size 个键的固定窗口限流器
+func NewFixedWindow(size, limit int, window time.Duration) (*FixedWindow, error) {
+	if limit <= 0 {
+		return nil, errors.New("must provide a positive limit")
+	}
+	if window <= 0 {
+		return nil, errors.New("must provide a positive window")
+	}
+	s, err := newStore(size)
+	if err != nil {
+		return nil, err
+	}
+	return &FixedWindow{store: s, limit: int64(limit), window: int64(window)}, nil
+}
+
+// Allow reports whether an event for the key may happen now, and if so
+// records it.
+// Allow 判断键的一次事件现在是否允许发生, 允许时记录该事件
+func (l *FixedWindow) Allow(key interface{}) bool {
+	_, ok := l.take(key, false)
+	return ok
+}
+
+// Reserve records an event for the key and returns how long the caller
+// must wait before acting on it.
+// Reserve 记录键的一次事件, 返回调用方执行前需要等待的时长
+func (l *FixedWindow) Reserve(key interface{}) time.Duration {
+	delay, _ := l.take(key, true)
+	return delay
+}
+
+// take records an event for the key, unless it would have to wait and
+// reserve is false.
+// take 记录键的一次事件, reserve 为 false 且需要等待时不记录
+func (l *FixedWindow) take(key interface{}, reserve bool) (delay time.Duration, ok bool) {
+	st := l.state(key, func() interface{} { return &fixedWindowState{} })
+	s := st.(*fixedWindowState)
+	now := l.now().UnixNano()
+
+	// 滚动到当前窗口, 已过去的窗口释放各自的额度
+	if now >= s.start+l.window {
+		n := (now - s.start) / l.window
+		s.count -= n * l.limit
+		if s.count < 0 {
+			s.count = 0
+		}
+		s.start += n * l.window
+	}
+
+	slot := s.count / l.limit
+	if slot > 0 && !reserve {
+		l.done(key, l.endAt(s))
+		return 0, false
+	}
+	s.count++
+	if slot > 0 {
+		delay = time.Duration(s.start + slot*l.window - now)
+	}
+	l.done(key, l.endAt(s))
+	return delay, true
+}
+
+// endAt returns the end in nanoseconds of the window of the last recorded
+// event, from then on the state is the same as a new one.
+// endAt 返回最后一次记录的事件所在窗口的结束时间(纳秒), 此后状态与新建时相同
+func (l *FixedWindow) endAt(s *fixedWindowState) int64 {
+	if s.count == 0 {
+		return s.start + l.window
+	}
+	return s.start + ((s.count-1)/l.limit+1)*l.window
+}
@@ -0,0 +1,102 @@
+// Package ratelimit provides per-key rate limiters whose state is kept in a
+// bounded HashLruCache: the number of tracked keys is capped, idle keys
+// expire and the least recently used ones are evicted first.
+// Package ratelimit 提供按键限流的限流器, 状态保存在有界的 HashLruCache 中:
+// 跟踪的键数量有上限, 空闲的键会过期, 超出容量时淘汰最近最少使用的键
+package ratelimit
+
+import (
+	"errors"
+	"runtime"
+	"time"
+
+	"github.com/songangweb/mcache"
+)
+
+// Limiter is the common interface of the rate limiters.
+// Limiter 限流器的通用接口
+type Limiter interface {
+	// Allow reports whether an event for the key may happen now, and if so
+	// records it.
+	// Allow 判断键的一次事件现在是否允许发生, 允许时记录该事件
+	Allow(key interface{}) bool
+
+	// Reserve records an event for the key and returns how long the caller
+	// must wait before acting on it, 0 when it may act now.
+	// Reserve 记录键的一次事件, 返回调用方执行前需要等待的时长, 为 0 时可立即执行
+	Reserve(key interface{}) time.Duration
+
+	// Reset forgets the state of the key.
+	// Reset 清除键的限流状态
+	Reset(key interface{})
+
+	// Len returns the number of tracked keys.
+	// Len 返回跟踪的键数量
+	Len() int
+}
+
+// store keeps the per-key limiter state in a bounded cache.
+// store 在有界缓存中保存每个键的限流状态
+type store struct {
+	cache *mcache.HashLruCache
+	// keys 每个键的锁, 键的状态只在持有其锁时创建和更新
+	keys *mcache.KeyLocker
+	now  func() time.Time
+}
+
+// newStore creates a store tracking up to size keys.
+// newStore 构造一个最多跟踪 size 个键的 store
+func newStore(size int) (store, error) {
+	if size <= 0 {
+		return store{}, errors.New("must provide a positive size")
+	}
+	// 分片数不超过 size, 使跟踪的键数量不超过 size
+	sliceNum := runtime.NumCPU()
+	if sliceNum > size {
+		sliceNum = size
+	}
+	c, err := mcache.NewHashLRU(size, sliceNum)
+	if err != nil {
+		return store{}, err
+	}
+	return store{cache: c, keys: mcache.NewKeyLocker(sliceNum), now: time.Now}, nil
+}
+
+// state locks the key and returns its state, creating it with newState if
+// it is missing. The caller must call done to unlock it. The state may be
+// evicted meanwhile, but no other one is created for the key until then.
+// state 锁定键并返回其状态, 不存在时用 newState 创建。调用方需要调用 done 解锁。
+// 期间状态可能被淘汰, 但在解锁之前不会为该键创建新的状态
+func (s *store) state(key interface{}, newState func() interface{}) interface{} {
+	s.keys.Lock(key)
+	v, _ := s.cache.ComputeIfAbsent(key, 0, func() (interface{}, bool) {
+		return newState(), true
+	})
+	return v
+}
+
+// done sets the time in nanoseconds after which the state of the key is
+// no longer needed, then unlocks the key. Setting the expiration while the
+// key is locked keeps it in step with the latest update of the state, and
+// never applies it to another state: if the state was evicted, there is
+// nothing to set.
+// done 设置键的状态不再需要的时间(纳秒)并解锁该键。在键加锁时设置过期时间, 保证与最后一次更新一致,
+// 且不会作用于其它状态: 状态已被淘汰时不做任何设置
+func (s *store) done(key interface{}, expireAt int64) {
+	// 缓存按实际时间过期, 按剩余时长换算
+	remain := expireAt - s.now().UnixNano()
+	s.cache.Expire(key, (time.Now().UnixNano()+remain)/1e6+1)
+	s.keys.Unlock(key)
+}
+
+// Reset forgets the state of the key.
+// Reset 清除键的限流状态
+func (s *store) Reset(key interface{}) {
+	s.cache.Remove(key)
+}
+
+// Len returns the number of tracked keys.
+// Len 返回跟踪的键数量
+func (s *store) Len() int {
+	return s.cache.Len()
+}
@@ -0,0 +1,194 @@
+package ratelimit
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock 测试用的可控时钟
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{t: time.Unix(1000, 0)}
+}
+
+func TestFixedWindow(t *testing.T) {
+	l, err := NewFixedWindow(16, 2, time.Second)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	c := newFakeClock()
+	l.now = c.now
+
+	if !l.Allow("a") || !l.Allow("a") {
+		t.Fatalf("should allow")
+	}
+	if l.Allow("a") {
+		t.Fatalf("should not allow")
+	}
+	if !l.Allow("b") {
+		t.Fatalf("keys should be limited separately")
+	}
+
+	// 预约到后续窗口
+	if d := l.Reserve("a"); d != time.Second {
+		t.Fatalf("bad: %v", d)
+	}
+	if d := l.Reserve("a"); d != time.Second {
+		t.Fatalf("bad: %v", d)
+	}
+	if d := l.Reserve("a"); d != 2*time.Second {
+		t.Fatalf("bad: %v", d)
+	}
+
+	c.advance(time.Second)
+	if l.Allow("a") {
+		t.Fatalf("window should be taken by reservations")
+	}
+	c.advance(2 * time.Second)
+	if !l.Allow("a") {
+		t.Fatalf("should allow")
+	}
+
+	l.Reset("a")
+	if l.Len() != 1 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+}
+
+func TestSlidingLog(t *testing.T) {
+	l, err := NewSlidingLog(16, 2, time.Second)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	c := newFakeClock()
+	l.now = c.now
+
+	l.Allow("a")
+	c.advance(600 * time.Millisecond)
+	l.Allow("a")
+	c.advance(200 * time.Millisecond)
+	if l.Allow("a") {
+		t.Fatalf("should not allow")
+	}
+
+	// 第一次事件滑出窗口后允许
+	c.advance(200 * time.Millisecond)
+	if !l.Allow("a") {
+		t.Fatalf("should allow")
+	}
+
+	if d := l.Reserve("a"); d != 600*time.Millisecond {
+		t.Fatalf("bad: %v", d)
+	}
+	if d := l.Reserve("a"); d != time.Second {
+		t.Fatalf("bad: %v", d)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	l, err := NewTokenBucket(16, 10, 2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	c := newFakeClock()
+	l.now = c.now
+
+	if !l.Allow("a") || !l.Allow("a") {
+		t.Fatalf("should allow burst")
+	}
+	if l.Allow("a") {
+		t.Fatalf("should not allow")
+	}
+
+	c.advance(100 * time.Millisecond)
+	if !l.Allow("a") {
+		t.Fatalf("should allow after refill")
+	}
+
+	if d := l.Reserve("a"); d != 100*time.Millisecond {
+		t.Fatalf("bad: %v", d)
+	}
+	if d := l.Reserve("a"); d != 200*time.Millisecond {
+		t.Fatalf("bad: %v", d)
+	}
+}
+
+func TestLimiterBounded(t *testing.T) {
+	l, err := NewTokenBucket(4, 1, 1)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				l.Allow(i*100 + j)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if l.Len() > 4 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+
+	var _ Limiter = l
+	var _ Limiter = &FixedWindow{}
+	var _ Limiter = &SlidingLog{}
+}
+
+func TestStoreEvicted(t *testing.T) {
+	s, err := newStore(1)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	first := s.state("a", func() interface{} { return new(int) })
+
+	// 状态被淘汰后, 在 done 之前不会创建新的状态
+	got := make(chan interface{})
+	go func() {
+		st := s.state("a", func() interface{} { return new(int) })
+		s.done("a", s.now().Add(time.Second).UnixNano())
+		got <- st
+	}()
+	s.cache.Add("b", 0, 0)
+	select {
+	case <-got:
+		t.Fatalf("state created before done")
+	case <-time.After(20 * time.Millisecond):
+	}
+	s.done("a", s.now().Add(time.Second).UnixNano())
+	if st := <-got; st == first {
+		t.Fatalf("evicted state reused")
+	}
+	if s.cache.Contains("b") {
+		t.Fatalf("bad keys: %v", s.cache.Keys())
+	}
+}
+
+func TestLimiterInvalid(t *testing.T) {
+	if _, err := NewFixedWindow(0, 1, time.Second); err == nil {
+		t.Fatalf("should fail")
+	}
+	if _, err := NewSlidingLog(1, 0, time.Second); err == nil {
+		t.Fatalf("should fail")
+	}
+	if _, err := NewTokenBucket(1, 0, 1); err == nil {
+		t.Fatalf("should fail")
+	}
+}
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"errors"
+	"time"
+)
+
+// SlidingLog allows up to limit events per key in any window ending at the
+// time of an event. It keeps the times of the last limit events of each
+// key, reserved events are logged at the time they may happen.
+// SlidingLog 滑动窗口日志限流器, 以任意事件时刻结束的窗口内每个键最多允许 limit 次事件。
+// 每个键保存最近 limit 次事件的时间, 预约的事件按可执行的时间记录
+type SlidingLog struct {
+	store
+	limit  int
+	window int64
+}
+
+// slidingLogState is the state of a key, the event times in order.
+// slidingLogState 键的状态, 按顺序保存的事件时间
+type slidingLogState struct {
+	log []int64
+}
+
+// NewSlidingLog creates a sliding window log limiter tracking up to size
+// keys.
+// NewSlidingLog 构造一个最多跟踪 size 个键的滑动窗口日志限流器
+func NewSlidingLog(size, limit int, window time.Duration) (*SlidingLog, error) {
+	if limit <= 0 {
+		return nil, errors.New("must provide a positive limit")
+	}
+	if window <= 0 {
+		return nil, errors.New("must provide a positive window")
+	}
+	s, err := newStore(size)
+	if err != nil {
+		return nil, err
+	}
+	return &SlidingLog{store: s, limit: limit, window: int64(window)}, nil
+}
+
+// Allow reports whether an event for the key may happen now, and if so
+// records it.
+// Allow 判断键的一次事件现在是否允许发生, 允许时记录该事件
+func (l *SlidingLog) Allow(key interface{}) bool {
+	_, ok := l.take(key, false)
+	return ok
+}
+
+// Reserve records an event for the key and returns how long the caller
+// must wait before acting on it.
+// Reserve 记录键的一次事件, 返回调用方执行前需要等待的时长
+func (l *SlidingLog) Reserve(key interface{}) time.Duration {
+	delay, _ := l.take(key, true)
+	return delay
+}
+
+// take records an event for the key, unless it would have to wait and
+// reserve is false.
+// take 记录键的一次事件, reserve 为 false 且需要等待时不记录
+func (l *SlidingLog) take(key interface{}, reserve bool) (delay time.Duration, ok bool) {
+	st := l.state(key, func() interface{} { return &slidingLogState{} })
+	s := st.(*slidingLogState)
+	now := l.now().UnixNano()
+
+	// 事件最早在倒数第 limit 次事件一个窗口之后发生, 且不早于最后一次事件
+	at := now
+	n := len(s.log)
+	if n >= l.limit && s.log[n-l.limit]+l.window > at {
+		at = s.log[n-l.limit] + l.window
+	}
+	if n > 0 && s.log[n-1] > at {
+		at = s.log[n-1]
+	}
+	if at > now && !reserve {
+		l.done(key, s.log[n-1]+l.window)
+		return 0, false
+	}
+
+	if n < l.limit {
+		s.log = append(s.log, at)
+	} else {
+		copy(s.log, s.log[1:])
+		s.log[n-1] = at
+	}
+	l.done(key, at+l.window)
+	return time.Duration(at - now), true
+}
@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"errors"
+	"time"
+)
+
+// TokenBucket refills the bucket of each key with rate tokens per second up
+// to burst tokens, every event takes a token. Reserved events may take the
+// bucket below zero and wait until it refills.
+// TokenBucket 令牌桶限流器, 每个键的令牌桶以每秒 rate 个令牌的速度补充, 最多 burst 个,
+// 每次事件消耗一个令牌。预约的事件可以使令牌数低于零, 并等待补充
+type TokenBucket struct {
+	store
+	rate  float64
+	burst float64
+}
+
+// tokenBucketState is the state of a key.
+// tokenBucketState 键的状态
+type tokenBucketState struct {
+	tokens float64
+	last   int64
+}
+
+// NewTokenBucket creates a token bucket limiter tracking up to size keys.
+// NewTokenBucket 构造一个最多跟踪 size 个键的令牌桶限流器
+func NewTokenBucket(size int, rate float64, burst int) (*TokenBucket, error) {
+	if rate <= 0 {
+		return nil, errors.New("must provide a positive rate")
+	}
+	if burst <= 0 {
+		return nil, errors.New("must provide a positive burst")
+	}
+	s, err := newStore(size)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenBucket{store: s, rate: rate, burst: float64(burst)}, nil
+}
+
+// Allow reports whether an event for the key may happen now, and if so
+// records it.
+// Allow 判断键的一次事件现在是否允许发生, 允许时记录该事件
+func (l *TokenBucket) Allow(key interface{}) bool {
+	_, ok := l.take(key, false)
+	return ok
+}
+
+// Reserve records an event for the key and returns how long the caller
+// must wait before acting on it.
+// Reserve 记录键的一次事件, 返回调用方执行前需要等待的时长
+func (l *TokenBucket) Reserve(key interface{}) time.Duration {
+	delay, _ := l.take(key, true)
+	return delay
+}
+
+// take takes a token for the key, unless it would have to wait and reserve
+// is false.
+// take 为键消耗一个令牌, reserve 为 false 且需要等待时不消耗
+func (l *TokenBucket) take(key interface{}, reserve bool) (delay time.Duration, ok bool) {
+	now := l.now().UnixNano()
+	st := l.state(key, func() interface{} {
+		return &tokenBucketState{tokens: l.burst, last: now}
+	})
+	s := st.(*tokenBucketState)
+
+	// 补充上次更新以来的令牌
+	if now > s.last {
+		s.tokens += float64(now-s.last) / float64(time.Second) * l.rate
+		if s.tokens > l.burst {
+			s.tokens = l.burst
+		}
+		s.last = now
+	}
+
+	if s.tokens < 1 && !reserve {
+		l.done(key, l.fullAt(s))
+		return 0, false
+	}
+	s.tokens--
+	if s.tokens < 0 {
+		delay = time.Duration(-s.tokens / l.rate * float64(time.Second))
+	}
+	l.done(key, l.fullAt(s))
+	return delay, true
+}
+
+// fullAt returns the time in nanoseconds at which the bucket is full again,
+// from then on the state is the same as a new one.
+// fullAt 返回令牌桶重新装满的时间(纳秒), 此后状态与新建时相同
+func (l *TokenBucket) fullAt(s *tokenBucketState) int64 {
+	return s.last + int64((l.burst-s.tokens)/l.rate*float64(time.Second))
+}
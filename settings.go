@@ -0,0 +1,67 @@
+package mcache
+
+import "sync"
+
+// Settings describes how a cache is configured.
+// Settings 缓存的配置
+type Settings struct {
+	// Size 缓存容量, 设置 sizer 时为总成本
+	Size int
+	// Shards 哈希缓存的分片数量, 其它缓存为 1
+	Shards int
+	// Sized 是否通过 SetSizer 设置了计算成本的函数
+	Sized bool
+	// Sliding 是否开启滑动过期模式
+	Sliding bool
+	// Jitter 通过 SetJitter 设置的过期时间随机调整
+	Jitter Jitter
+}
+
+// settingsRecorder keeps the settings changed after the construction of a
+// cache, guarded by its own lock so that hashed caches need no shard lock
+// to read them.
+// settingsRecorder 保存缓存构造后修改的配置, 使用单独的锁, 哈希缓存读取时不需要分片锁
+type settingsRecorder struct {
+	sized   bool
+	sliding bool
+	jitter  Jitter
+	lock    sync.Mutex
+}
+
+// setSized records whether a sizer is set.
+// setSized 记录是否设置了 sizer
+func (r *settingsRecorder) setSized(sized bool) {
+	r.lock.Lock()
+	r.sized = sized
+	r.lock.Unlock()
+}
+
+// setSliding records the sliding expiration mode.
+// setSliding 记录滑动过期模式
+func (r *settingsRecorder) setSliding(sliding bool) {
+	r.lock.Lock()
+	r.sliding = sliding
+	r.lock.Unlock()
+}
+
+// setJitter records the jitter.
+// setJitter 记录过期时间的随机调整
+func (r *settingsRecorder) setJitter(jitter Jitter) {
+	r.lock.Lock()
+	r.jitter = jitter
+	r.lock.Unlock()
+}
+
+// get returns the settings of a cache of the given size and shards.
+// get 返回给定容量和分片数量的缓存的配置
+func (r *settingsRecorder) get(size, shards int) Settings {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return Settings{
+		Size:    size,
+		Shards:  shards,
+		Sized:   r.sized,
+		Sliding: r.sliding,
+		Jitter:  r.jitter,
+	}
+}
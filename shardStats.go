@@ -0,0 +1,90 @@
+package mcache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ShardStats holds the statistics of a shard of a hashed cache.
+// ShardStats 哈希缓存一个分片的统计数据
+type ShardStats struct {
+	// Len 分片中的缓存条数
+	Len int
+	// Hits 分片的命中次数
+	Hits uint64
+	// Misses 分片的未命中次数
+	Misses uint64
+	// Locks 按键操作获取分片锁的次数
+	Locks uint64
+	// LockWait 按键操作等待分片锁的总时间
+	LockWait time.Duration
+}
+
+// SkewReport describes how keys and traffic are spread over the shards of
+// a hashed cache. A skew is the ratio of the largest shard to the mean, 1
+// for a perfect spread. Keys of types InterfaceToString does not support
+// all map to the same shard, which shows up as a high LenSkew.
+// SkewReport 描述键和访问在哈希缓存各分片上的分布。倾斜度为最大分片与平均值之比, 完全均匀时为 1。
+// InterfaceToString 不支持的键类型都会落到同一个分片, 表现为 LenSkew 很高
+type SkewReport struct {
+	// Shards 每个分片的统计数据
+	Shards []ShardStats
+	// LenSkew 条数的倾斜度
+	LenSkew float64
+	// LookupSkew 查询次数(命中加未命中)的倾斜度
+	LookupSkew float64
+	// LockWaitSkew 锁等待时间的倾斜度
+	LockWaitSkew float64
+	// HotShard 查询次数最多的分片
+	HotShard int
+}
+
+// shardStats returns the statistics of a shard from its counters.
+// shardStats 根据分片的计数生成分片统计数据
+func shardStats(length int, s *statsCounter) ShardStats {
+	return ShardStats{
+		Len:      length,
+		Hits:     atomic.LoadUint64(&s.hits),
+		Misses:   atomic.LoadUint64(&s.misses),
+		Locks:    atomic.LoadUint64(&s.locks),
+		LockWait: time.Duration(atomic.LoadUint64(&s.lockWait)),
+	}
+}
+
+// skewReport computes the skew report of the given shards.
+// skewReport 计算给定分片的倾斜报告
+func skewReport(shards []ShardStats) SkewReport {
+	r := SkewReport{Shards: shards}
+	lens := make([]float64, len(shards))
+	lookups := make([]float64, len(shards))
+	waits := make([]float64, len(shards))
+	for i, s := range shards {
+		lens[i] = float64(s.Len)
+		lookups[i] = float64(s.Hits + s.Misses)
+		waits[i] = float64(s.LockWait)
+		if lookups[i] > lookups[r.HotShard] {
+			r.HotShard = i
+		}
+	}
+	r.LenSkew = skew(lens)
+	r.LookupSkew = skew(lookups)
+	r.LockWaitSkew = skew(waits)
+	return r
+}
+
+// skew returns the ratio of the largest value to the mean, 0 when all
+// values are 0.
+// skew 返回最大值与平均值之比, 全部为 0 时返回 0
+func skew(values []float64) float64 {
+	var max, sum float64
+	for _, v := range values {
+		sum += v
+		if v > max {
+			max = v
+		}
+	}
+	if sum == 0 {
+		return 0
+	}
+	return max / (sum / float64(len(values)))
+}
@@ -0,0 +1,54 @@
+package mcache
+
+import (
+	"testing"
+)
+
+type unsupportedKey struct {
+	id int
+}
+
+func TestShardStats(t *testing.T) {
+	l, err := NewHashLRU(1000, 4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 200; i++ {
+		l.Add(i, i, 0)
+		l.Get(i)
+	}
+	shards := l.ShardStats()
+	if len(shards) != 4 {
+		t.Fatalf("bad: %v", shards)
+	}
+	var length int
+	var hits, locks uint64
+	for _, s := range shards {
+		length += s.Len
+		hits += s.Hits
+		locks += s.Locks
+	}
+	if length != 200 || hits != 200 || locks != 400 {
+		t.Fatalf("bad: %v %v %v", length, hits, locks)
+	}
+	if r := l.Skew(); r.LenSkew < 1 || r.LenSkew > 2 {
+		t.Fatalf("bad skew: %+v", r)
+	}
+
+	// 不支持的键类型都落到同一个分片
+	h, _ := NewHashLFU(1000, 4)
+	for i := 0; i < 100; i++ {
+		h.Add(unsupportedKey{i}, i, 0)
+		h.Get(unsupportedKey{i})
+	}
+	r := h.Skew()
+	if r.LenSkew != 4 || r.LookupSkew != 4 || r.Shards[r.HotShard].Len != 100 {
+		t.Fatalf("bad skew: %+v", r)
+	}
+
+	h.ResetStats()
+	if s := h.ShardStats()[r.HotShard]; s.Hits != 0 || s.Locks != 0 || s.Len != 100 {
+		t.Fatalf("bad: %+v", s)
+	}
+}
@@ -0,0 +1,985 @@
+package simplegdsf
+
+import (
+	"container/heap"
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// EvictCallback is used to get a callback when a cache entry is evicted
+// EvictCallback 用于在缓存条目被淘汰时的回调函数
+type EvictCallback func(key interface{}, value interface{}, expirationTime int64)
+
+// EvictReasonCallback is used to get a callback with the reason when a cache
+// entry is evicted
+// EvictReasonCallback 用于在缓存条目被淘汰时的回调函数, 同时返回淘汰原因
+type EvictReasonCallback func(key interface{}, value interface{}, expirationTime int64, reason EvictionReason)
+
+// Jitter randomizes the expiration times of added entries, so that entries
+// added together don't expire together. The lifetime of an entry is moved by
+// a random offset within ±Percent% of the lifetime, or within ±Max when
+// Percent is not set; Max also caps the percentage range.
+// Jitter 随机调整新增条目的过期时间, 避免同时放入的条目同时过期。存活时长在
+// ±Percent% 范围内随机调整, 未设置 Percent 时在 ±Max 范围内调整; 同时设置时 Max 为调整上限
+type Jitter struct {
+	// Percent 按存活时长的百分比随机调整, 如 10 表示 ±10%
+	Percent float64
+	// Max 随机调整的最大时长
+	Max time.Duration
+	// Seed 随机数种子, 为0时使用当前时间; 相同的种子按相同的比例序列调整存活时长
+	Seed int64
+}
+
+// AddCallback is used to get a callback when a value is stored
+// AddCallback 用于在值写入缓存时的回调函数
+type AddCallback func(key interface{})
+
+// Sizer is used to compute the size of a cache entry
+// Sizer 用于计算缓存条目的大小(如占用的字节数)
+type Sizer func(key interface{}, value interface{}) int64
+
+// EvictionReason describes why an entry left the cache
+// EvictionReason 缓存条目被淘汰的原因
+type EvictionReason int
+
+const (
+	// ReasonCapacity 因容量不足被淘汰
+	ReasonCapacity EvictionReason = iota
+	// ReasonExpired 因过期被删除
+	ReasonExpired
+	// ReasonRemoved 被主动移除
+	ReasonRemoved
+	// ReasonReplaced 旧值被新值替换
+	ReasonReplaced
+	// ReasonPurged 因清空缓存被删除
+	ReasonPurged
+	// ReasonResized 因调整缓存大小被淘汰
+	ReasonResized
+)
+
+// String returns the name of the reason
+// String 返回淘汰原因的名称
+func (r EvictionReason) String() string {
+	switch r {
+	case ReasonCapacity:
+		return "capacity"
+	case ReasonExpired:
+		return "expired"
+	case ReasonRemoved:
+		return "removed"
+	case ReasonReplaced:
+		return "replaced"
+	case ReasonPurged:
+		return "purged"
+	case ReasonResized:
+		return "resized"
+	}
+	return "unknown"
+}
+
+// GDSF implements a non-thread safe fixed size GreedyDual-Size-Frequency
+// cache. Every entry has a size, counted against the capacity, and a
+// recompute cost. The entry with the lowest priority
+// clock + frequency*cost/size is evicted first, and the clock is raised to
+// the priority of each evicted entry so that old entries age out.
+// GDSF 实现一个非线程安全的固定大小的 GreedyDual-Size-Frequency 缓存。
+// 每个条目有大小(占用容量)和重新计算的成本, 优先淘汰
+// clock + 访问次数*成本/大小 最小的条目, 淘汰时将 clock 提升到被淘汰条目的优先级,
+// 使长期不访问的条目逐渐老化
+type GDSF struct {
+	size      int
+	used      int64   // 当前缓存的总大小
+	clock     float64 // 老化时钟
+	seq       uint64  // 插入序号, 优先级相同时先淘汰较早的条目
+	evictList entryHeap
+	items     map[interface{}]*entry
+	onEvict   EvictReasonCallback
+	onAdd     AddCallback
+	sizer     Sizer
+	sliding   bool // 滑动过期模式
+	jitter    Jitter
+	rand      *rand.Rand
+	version   uint64 // 最近分配的版本号
+}
+
+// entry is used to hold a value in the evictList
+// 缓存详细信息
+type entry struct {
+	key            interface{}
+	value          interface{}
+	weight         int64 // 访问次数
+	expirationTime int64
+	size           int64
+	cost           float64 // 重新计算的成本
+	priority       float64
+	seq            uint64
+	index          int    // 在堆中的位置
+	created        int64  // 创建时间
+	accessed       int64  // 最后访问时间
+	ttl            int64  // 设置过期时间时的存活时长(毫秒)
+	sliding        bool   // 是否为滑动过期
+	version        uint64 // 版本号, 每次写入时分配
+}
+
+// Entry describes a cache entry and its metadata
+// Entry 缓存条目及其元数据, 时间均为毫秒时间戳
+type Entry struct {
+	Key            interface{}
+	Value          interface{}
+	ExpirationTime int64         // 过期时间, 0为永不过期
+	CreationTime   int64         // 创建时间
+	AccessTime     int64         // 最后访问时间
+	AccessCount    int64         // 访问次数
+	Cost           int64         // 大小
+	TTL            time.Duration // 剩余存活时间, 永不过期时为0
+	Lifetime       time.Duration // 设置过期时间时的存活时长, Touch 按此时长延长
+	Sliding        bool          // 是否为滑动过期, Get 时按 Lifetime 延长过期时间
+	Version        uint64        // 版本号, 每次写入时分配
+}
+
+// NewGDSF constructs a GDSF of the given size
+// NewGDSF 构造一个给定大小的GDSF
+func NewGDSF(size int, onEvict EvictCallback) (*GDSF, error) {
+	return NewGDSFWithEvictReason(size, reasonCallback(onEvict))
+}
+
+// NewGDSFWithEvictReason constructs a GDSF of the given size whose eviction
+// callback receives the eviction reason
+// NewGDSFWithEvictReason 构造一个给定大小的GDSF, 淘汰回调中包含淘汰原因
+func NewGDSFWithEvictReason(size int, onEvict EvictReasonCallback) (*GDSF, error) {
+	if size <= 0 {
+		return nil, errors.New("must provide a positive size")
+	}
+	c := &GDSF{
+		size:    size,
+		items:   make(map[interface{}]*entry),
+		onEvict: onEvict,
+	}
+	return c, nil
+}
+
+// Purge is used to completely clear the cache.
+// Purge 用于完全清除缓存
+func (c *GDSF) Purge() {
+	for k, v := range c.items {
+		if c.onEvict != nil {
+			c.onEvict(k, v.value, v.expirationTime, ReasonPurged)
+		}
+		delete(c.items, k)
+	}
+	c.evictList = nil
+	c.used = 0
+	c.clock = 0
+}
+
+// PurgeOverdue is used to completely clear the overdue cache.
+// PurgeOverdue 清除过期缓存
+func (c *GDSF) PurgeOverdue() {
+	for _, ent := range c.items {
+		// 判断此值是否已经超时,如果超时则进行删除
+		if checkExpirationTime(ent.expirationTime) {
+			c.removeElement(ent, ReasonExpired)
+		}
+	}
+}
+
+// Add adds a value to the cache with a recompute cost of 1.
+// Add 向缓存添加一个值, 重新计算的成本为1。如果已经存在,则更新信息
+func (c *GDSF) Add(key, value interface{}, expirationTime int64) (ok bool) {
+	return c.AddWithSizeCost(key, value, c.sizeOf(key, value), 1, expirationTime)
+}
+
+// AddWithCost adds a value whose capacity cost (its size) is cost, with a
+// recompute cost of 1. Returns false if the cost exceeds the capacity.
+// AddWithCost 向缓存添加一个占用容量为 cost 的值, 重新计算的成本为1。
+// 超过缓存总容量时不会放入,返回false
+func (c *GDSF) AddWithCost(key, value interface{}, cost int64, expirationTime int64) (ok bool) {
+	return c.AddWithSizeCost(key, value, cost, 1, expirationTime)
+}
+
+// AddWithSizeCost adds a value with the given size and recompute cost,
+// evicting the lowest priority entries until it fits. Returns false if the
+// size exceeds the capacity.
+// AddWithSizeCost 向缓存添加一个指定大小和重新计算成本的值, 淘汰优先级最低的数据直到可以放入。
+// 大小超过缓存总容量时不会放入,返回false
+func (c *GDSF) AddWithSizeCost(key, value interface{}, size int64, cost float64, expirationTime int64) (ok bool) {
+	return c.add(key, value, size, cost, c.jittered(expirationTime))
+}
+
+// add adds a value with the given size and recompute cost to the cache.
+// add 向缓存添加一个指定大小和重新计算成本的值
+func (c *GDSF) add(key, value interface{}, size int64, cost float64, expirationTime int64) (ok bool) {
+	if size < 0 {
+		size = 0
+	}
+	if cost < 0 {
+		cost = 0
+	}
+	// 大小超过缓存总容量,因容量不足移除已存在的旧数据,不再放入
+	if size > int64(c.size) {
+		if ent, ok := c.items[key]; ok {
+			c.removeElement(ent, ReasonCapacity)
+		}
+		return false
+	}
+	// 判断缓存中是否已经存在数据,如果已经存在则更新数据
+	if ent, ok := c.items[key]; ok {
+		if c.onEvict != nil {
+			c.onEvict(key, ent.value, ent.expirationTime, ReasonReplaced)
+		}
+		c.used += size - ent.size
+		ent.value = value
+		ent.setExpirationTime(expirationTime)
+		ent.size = size
+		ent.version = c.nextVersion()
+		ent.cost = cost
+		ent.weight++
+		ent.accessed = time.Now().UnixNano() / 1e6
+		c.touch(ent)
+		c.removeOverflow(ent)
+		c.added(key)
+		return true
+	}
+	// 淘汰优先级最低的数据,直到新数据可以放入
+	for c.evictList.Len() > 0 && c.used+size > int64(c.size) {
+		c.removeOldest(ReasonCapacity)
+	}
+	// 创建数据
+	c.seq++
+	now := time.Now().UnixNano() / 1e6
+	ent := &entry{
+		key:            key,
+		value:          value,
+		weight:         1,
+		expirationTime: expirationTime,
+		size:           size,
+		cost:           cost,
+		seq:            c.seq,
+		created:        now,
+		accessed:       now,
+		ttl:            lifetime(expirationTime, now),
+		version:        c.nextVersion(),
+	}
+	ent.priority = c.priorityOf(ent)
+	heap.Push(&c.evictList, ent)
+	c.items[key] = ent
+	c.used += size
+
+	c.added(key)
+	return true
+}
+
+// added calls the add callback.
+// added 调用写入回调
+func (c *GDSF) added(key interface{}) {
+	if c.onAdd != nil {
+		c.onAdd(key)
+	}
+}
+
+// Get looks up a key's value from the cache.
+// Get 从缓存中查找一个键的值。
+func (c *GDSF) Get(key interface{}) (value interface{}, expirationTime int64, ok bool) {
+	// 判断缓存是否存在
+	if ent, ok := c.items[key]; ok {
+		// 判断此值是否已经超时,如果超时则进行删除
+		if checkExpirationTime(ent.expirationTime) {
+			c.removeElement(ent, ReasonExpired)
+			return nil, 0, false
+		}
+		ent.weight++
+		ent.accessed = time.Now().UnixNano() / 1e6
+		c.touch(ent)
+		if c.sliding || ent.sliding {
+			ent.slide()
+		}
+		return ent.value, ent.expirationTime, true
+	}
+	return nil, 0, false
+}
+
+// GetWithVersion looks up a key's value and version from the cache,
+// updating the priority like Get.
+// GetWithVersion 从缓存中查找一个键的值及版本号, 与 Get 一样更新缓存的状态
+func (c *GDSF) GetWithVersion(key interface{}) (value interface{}, expirationTime int64, version uint64, ok bool) {
+	if value, expirationTime, ok = c.Get(key); ok {
+		version = c.items[key].version
+	}
+	return value, expirationTime, version, ok
+}
+
+// CompareAndSwap replaces the value of a key, keeping its expiration time,
+// if its version is expectedVersion. Returns the current version and
+// whether the value was replaced. The entry is removed if the new value
+// exceeds the capacity.
+// CompareAndSwap 当键的版本号等于 expectedVersion 时替换其值并保留过期时间,
+// 返回当前版本号及是否替换成功。新值超过缓存总容量时条目被移除
+func (c *GDSF) CompareAndSwap(key interface{}, expectedVersion uint64, value interface{}) (version uint64, swapped bool) {
+	ent, ok := c.live(key)
+	if !ok {
+		return 0, false
+	}
+	if ent.version != expectedVersion {
+		return ent.version, false
+	}
+	ttl, sliding := ent.ttl, ent.sliding
+	if !c.add(key, value, c.sizeOf(key, value), ent.cost, ent.expirationTime) {
+		return 0, false
+	}
+	ent.ttl, ent.sliding = ttl, sliding
+	return ent.version, true
+}
+
+// CompareAndDelete removes a key if its version is expectedVersion.
+// CompareAndDelete 当键的版本号等于 expectedVersion 时移除该键
+func (c *GDSF) CompareAndDelete(key interface{}, expectedVersion uint64) (deleted bool) {
+	ent, ok := c.live(key)
+	if !ok || ent.version != expectedVersion {
+		return false
+	}
+	c.removeElement(ent, ReasonRemoved)
+	return true
+}
+
+// Update replaces the value of a key by the result of fn, keeping its
+// expiration time. fn receives the current value and whether the key
+// exists, the key is removed when fn returns keep false and new keys never
+// expire. Returns the value in the cache and whether the key is present.
+// fn is called under the cache lock and must not use the cache.
+// Update 用 fn 的结果替换一个键的值并保留过期时间。fn 接收当前值及键是否存在,
+// 返回 keep 为false时移除该键, 新增的键永不过期。返回缓存中的值及键是否存在。
+// fn 在缓存锁内执行, 不能访问缓存
+func (c *GDSF) Update(key interface{}, fn func(old interface{}, exists bool) (value interface{}, keep bool)) (value interface{}, ok bool) {
+	ent, exists := c.live(key)
+	var old interface{}
+	if exists {
+		old = ent.value
+	}
+	value, keep := fn(old, exists)
+	switch {
+	case !keep:
+		if exists {
+			c.removeElement(ent, ReasonRemoved)
+		}
+		return nil, false
+	case exists:
+		_, ok = c.CompareAndSwap(key, ent.version, value)
+	default:
+		ok = c.Add(key, value, 0)
+	}
+	if !ok {
+		return nil, false
+	}
+	return value, true
+}
+
+// Compute replaces the value of a key by the result of fn with the given
+// expiration time. fn receives the current value and whether the key
+// exists, the key is removed when fn returns keep false. Returns the value
+// in the cache and whether the key is present.
+// fn is called under the cache lock and must not use the cache.
+// Compute 用 fn 的结果及给定的过期时间替换一个键的值。fn 接收当前值及键是否存在,
+// 返回 keep 为false时移除该键。返回缓存中的值及键是否存在。fn 在缓存锁内执行, 不能访问缓存
+func (c *GDSF) Compute(key interface{}, expirationTime int64, fn func(old interface{}, exists bool) (value interface{}, keep bool)) (value interface{}, ok bool) {
+	ent, exists := c.live(key)
+	var old interface{}
+	if exists {
+		old = ent.value
+	}
+	value, keep := fn(old, exists)
+	if !keep {
+		if exists {
+			c.removeElement(ent, ReasonRemoved)
+		}
+		return nil, false
+	}
+	if !c.Add(key, value, expirationTime) {
+		return nil, false
+	}
+	return value, true
+}
+
+// ComputeIfAbsent returns the value of a key like Get, or adds the result
+// of fn with the given expiration time if the key is missing and fn
+// returns keep true. Returns the value in the cache and whether the key is
+// present. fn is called under the cache lock and must not use the cache.
+// ComputeIfAbsent 与 Get 一样返回一个键的值, 键不存在时调用 fn, 返回 keep 为true时以给定的过期时间放入缓存。
+// 返回缓存中的值及键是否存在。fn 在缓存锁内执行, 不能访问缓存
+func (c *GDSF) ComputeIfAbsent(key interface{}, expirationTime int64, fn func() (value interface{}, keep bool)) (value interface{}, ok bool) {
+	if value, _, ok = c.Get(key); ok {
+		return value, true
+	}
+	value, keep := fn()
+	if !keep || !c.Add(key, value, expirationTime) {
+		return nil, false
+	}
+	return value, true
+}
+
+// GetEntry looks up a key's entry and metadata from the cache, updating
+// the priority like Get.
+// GetEntry 从缓存中查找一个键的条目及元数据, 与 Get 一样更新缓存的状态
+func (c *GDSF) GetEntry(key interface{}) (e Entry, ok bool) {
+	if _, _, ok = c.Get(key); ok {
+		e = c.items[key].info()
+	}
+	return e, ok
+}
+
+// PeekEntry returns a key's entry and metadata without updating the
+// priority of the key.
+// PeekEntry 返回一个键的条目及元数据, 不更新缓存的状态
+func (c *GDSF) PeekEntry(key interface{}) (e Entry, ok bool) {
+	if _, _, ok = c.Peek(key); ok {
+		e = c.items[key].info()
+	}
+	return e, ok
+}
+
+// AddEntry adds an entry with the given recompute cost to the cache keeping
+// its metadata, AccessCount is used as the frequency and Cost as the size.
+// Returns false if the size exceeds the capacity.
+// AddEntry 向缓存添加一个指定重新计算成本的条目并保留其元数据, AccessCount 作为访问次数, Cost 作为大小。
+// 大小超过缓存总容量时不会放入,返回false
+func (c *GDSF) AddEntry(e Entry, cost float64) (ok bool) {
+	if !c.add(e.Key, e.Value, e.Cost, cost, e.ExpirationTime) {
+		return false
+	}
+	ent := c.items[e.Key]
+	if e.CreationTime != 0 {
+		ent.created = e.CreationTime
+	}
+	if e.AccessTime != 0 {
+		ent.accessed = e.AccessTime
+	}
+	if e.Lifetime > 0 {
+		ent.ttl = int64(e.Lifetime / time.Millisecond)
+	}
+	ent.sliding = e.Sliding
+	if e.Version != 0 {
+		// 保留版本号, 之后分配的版本号大于它
+		ent.version = e.Version
+		if e.Version > c.version {
+			c.version = e.Version
+		}
+	}
+	ent.weight = e.AccessCount
+	if ent.weight < 1 {
+		ent.weight = 1
+	}
+	c.touch(ent)
+	return true
+}
+
+// RecomputeCost returns the recompute cost of a key, without updating its
+// priority.
+// RecomputeCost 返回一个键的重新计算成本, 不更新缓存的状态
+func (c *GDSF) RecomputeCost(key interface{}) (cost float64, ok bool) {
+	if _, _, ok = c.Peek(key); ok {
+		cost = c.items[key].cost
+	}
+	return cost, ok
+}
+
+// Contains checks if a key is in the cache, without updating the priority
+// or deleting it for being stale.
+// Contains 检查某个键是否在缓存中，但不更新缓存的状态
+func (c *GDSF) Contains(key interface{}) (ok bool) {
+	ent, ok := c.items[key]
+	if ok {
+		// 判断此值是否已经超时,如果超时则进行删除
+		if checkExpirationTime(ent.expirationTime) {
+			c.removeElement(ent, ReasonExpired)
+			return !ok
+		}
+	}
+	return ok
+}
+
+// Peek returns the key value (or undefined if not found) without updating
+// the priority of the key.
+// Peek 在不更新的情况下返回键值(如果没有找到则返回false),不更新缓存的状态
+func (c *GDSF) Peek(key interface{}) (value interface{}, expirationTime int64, ok bool) {
+	var ent *entry
+	if ent, ok = c.items[key]; ok {
+		// 判断是否已经超时
+		if checkExpirationTime(ent.expirationTime) {
+			c.removeElement(ent, ReasonExpired)
+			return nil, 0, false
+		}
+		return ent.value, ent.expirationTime, true
+	}
+	return nil, 0, ok
+}
+
+// Expire sets the expiration time of a key without updating its priority.
+// Expire 设置一个键的过期时间, 不更新缓存的状态
+func (c *GDSF) Expire(key interface{}, expirationTime int64) (ok bool) {
+	ent, ok := c.live(key)
+	if ok {
+		ent.setExpirationTime(expirationTime)
+	}
+	return ok
+}
+
+// Persist removes the expiration time of a key.
+// Persist 移除一个键的过期时间, 使其永不过期
+func (c *GDSF) Persist(key interface{}) (ok bool) {
+	return c.Expire(key, 0)
+}
+
+// Touch extends the expiration time of a key by the lifetime it was given
+// when its expiration time was set.
+// Touch 按设置过期时间时的存活时长延长一个键的过期时间, 不更新缓存的状态
+func (c *GDSF) Touch(key interface{}) (ok bool) {
+	ent, ok := c.live(key)
+	if ok {
+		ent.slide()
+	}
+	return ok
+}
+
+// TTL returns the remaining time to live of a key, 0 if it never expires.
+// TTL 返回一个键的剩余存活时间, 永不过期时为0
+func (c *GDSF) TTL(key interface{}) (ttl time.Duration, ok bool) {
+	ent, ok := c.live(key)
+	if ok && ent.expirationTime != 0 {
+		ttl = time.Duration(ent.expirationTime-time.Now().UnixNano()/1e6) * time.Millisecond
+	}
+	return ttl, ok
+}
+
+// AddSliding adds a value which expires after being idle for the given
+// duration, every Get extends its expiration time.
+// AddSliding 向缓存添加一个滑动过期的值, 空闲 idle 时长后过期, 每次 Get 都会延长过期时间
+func (c *GDSF) AddSliding(key, value interface{}, idle time.Duration) (ok bool) {
+	if !c.Add(key, value, 0) {
+		return false
+	}
+	return c.ExpireSliding(key, idle)
+}
+
+// ExpireSliding makes a key expire after being idle for the given duration,
+// without updating its priority.
+// ExpireSliding 设置一个键为滑动过期, 空闲 idle 时长后过期, 不更新缓存的状态
+func (c *GDSF) ExpireSliding(key interface{}, idle time.Duration) (ok bool) {
+	ent, ok := c.live(key)
+	if ok {
+		// 存活时长取 idle, 不受两次读取时间之间的误差影响
+		ttl := int64(idle / time.Millisecond)
+		ent.setExpirationTime(time.Now().UnixNano()/1e6 + ttl)
+		ent.ttl, ent.sliding = ttl, true
+	}
+	return ok
+}
+
+// SetSlidingExpiration turns the sliding expiration mode on or off. In this
+// mode every Get extends the expiration time of an entry by the lifetime it
+// was given when its expiration time was set, Peek does not.
+// SetSlidingExpiration 开启或关闭滑动过期模式。开启后每次 Get 都会按设置过期时间时的存活时长
+// 延长条目的过期时间, Peek 不会延长
+func (c *GDSF) SetSlidingExpiration(sliding bool) {
+	c.sliding = sliding
+}
+
+// Remove removes the provided key from the cache, returning if the
+// key was contained.
+// Remove 从缓存中移除提供的键
+func (c *GDSF) Remove(key interface{}) (ok bool) {
+	if ent, ok := c.items[key]; ok {
+		c.removeElement(ent, ReasonRemoved)
+		return ok
+	}
+	return ok
+}
+
+// RemoveOldest removes the lowest priority item from the cache.
+// RemoveOldest 从缓存中移除优先级最低的项
+func (c *GDSF) RemoveOldest() (key interface{}, value interface{}, expirationTime int64, ok bool) {
+	if c.evictList.Len() > 0 {
+		ent := c.evictList[0]
+		// 判断是否已经超时
+		if checkExpirationTime(ent.expirationTime) {
+			c.removeElement(ent, ReasonExpired)
+			return c.RemoveOldest()
+		}
+		c.evict(ent, ReasonRemoved)
+		return ent.key, ent.value, ent.expirationTime, true
+	}
+	return nil, nil, 0, false
+}
+
+// GetOldest returns the lowest priority entry
+// GetOldest 返回优先级最低的条目
+func (c *GDSF) GetOldest() (key interface{}, value interface{}, expirationTime int64, ok bool) {
+	if c.evictList.Len() > 0 {
+		ent := c.evictList[0]
+		// 判断此值是否已经超时,如果超时则进行删除
+		if checkExpirationTime(ent.expirationTime) {
+			c.removeElement(ent, ReasonExpired)
+			return c.GetOldest()
+		}
+		return ent.key, ent.value, ent.expirationTime, true
+	}
+	return nil, nil, 0, false
+}
+
+// Keys returns a slice of the keys in the cache, from lowest to highest
+// priority.
+// Keys 返回缓存的切片，从优先级最低的到最高的。
+func (c *GDSF) Keys() []interface{} {
+	ents := make(entryHeap, len(c.evictList))
+	copy(ents, c.evictList)
+	sort.Slice(ents, func(i, j int) bool {
+		return ents.Less(i, j)
+	})
+	keys := make([]interface{}, len(ents))
+	for i, ent := range ents {
+		keys[i] = ent.key
+	}
+	return keys
+}
+
+// FirstKeys returns up to n keys of the cache, from lowest to highest
+// priority, walking the heap from its root instead of sorting all entries.
+// FirstKeys 返回缓存中至多 n 个键，从优先级最低的到最高的，从堆顶开始遍历而不排序所有条目
+func (c *GDSF) FirstKeys(n int) []interface{} {
+	if n > len(c.evictList) {
+		n = len(c.evictList)
+	}
+	if n <= 0 {
+		return []interface{}{}
+	}
+	keys := make([]interface{}, 0, n)
+	// 候选为已取出节点的子节点, 其中优先级最低的即为下一个键
+	cand := &indexHeap{ents: c.evictList, index: []int{0}}
+	for len(keys) < n {
+		i := heap.Pop(cand).(int)
+		keys = append(keys, c.evictList[i].key)
+		for _, child := range []int{2*i + 1, 2*i + 2} {
+			if child < len(c.evictList) {
+				heap.Push(cand, child)
+			}
+		}
+	}
+	return keys
+}
+
+// Len returns the number of items in the cache.
+// Len 返回缓存中的条数
+func (c *GDSF) Len() int {
+	return c.evictList.Len()
+}
+
+// Cap returns the capacity of the cache, a total cost when a sizer is set.
+// Cap 返回缓存的容量, 设置了成本函数时为总成本
+func (c *GDSF) Cap() int {
+	return c.size
+}
+
+// Cost returns the total size of items in the cache.
+// Cost 返回缓存中所有条目的总大小
+func (c *GDSF) Cost() int64 {
+	return c.used
+}
+
+// SetAddCallback sets the function called with the key of every value
+// stored, whether added or replacing another one, nil removes it.
+// SetAddCallback 设置每次写入值时以其键调用的函数, 包括新增和替换, 为nil时移除
+func (c *GDSF) SetAddCallback(onAdd AddCallback) {
+	c.onAdd = onAdd
+}
+
+// SetSizer sets the function used by Add to compute entry sizes.
+// SetSizer 设置 Add 计算条目大小的函数, 为nil时每条数据大小为1
+func (c *GDSF) SetSizer(sizer Sizer) {
+	c.sizer = sizer
+}
+
+// SetJitter sets the jitter applied to the expiration times of added
+// entries, a zero Jitter turns it off.
+// SetJitter 设置新增条目过期时间的随机调整, Jitter 为零值时关闭
+func (c *GDSF) SetJitter(jitter Jitter) {
+	c.jitter = jitter
+	c.rand = nil
+	if jitter.Percent > 0 || jitter.Max > 0 {
+		seed := jitter.Seed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		c.rand = rand.New(rand.NewSource(seed))
+	}
+}
+
+// Resize changes the cache size.
+// Resize 改变缓存大小。
+func (c *GDSF) Resize(size int) (evicted int) {
+	c.size = size
+	for c.evictList.Len() > 0 && c.used > int64(c.size) {
+		c.removeOldest(ReasonResized)
+		evicted++
+	}
+	return evicted
+}
+
+// ResizeWeight scales the access counts by percentage and recomputes the
+// priorities.
+// ResizeWeight 按百分比调整访问次数, 并重新计算优先级。
+func (c *GDSF) ResizeWeight(percentage int) {
+	if percentage > 0 && percentage < 100 {
+		for _, ent := range c.evictList {
+			ent.weight = int64(math.Ceil(float64(ent.weight) * float64(percentage) / 100))
+			ent.priority = c.priorityOf(ent)
+		}
+		heap.Init(&c.evictList)
+	}
+}
+
+// touch recomputes the priority of an entry after an access.
+// touch 访问后重新计算条目的优先级
+func (c *GDSF) touch(ent *entry) {
+	ent.priority = c.priorityOf(ent)
+	heap.Fix(&c.evictList, ent.index)
+}
+
+// priorityOf returns clock + frequency*cost/size for an entry.
+// priorityOf 计算条目的优先级 clock + 访问次数*成本/大小
+func (c *GDSF) priorityOf(ent *entry) float64 {
+	size := ent.size
+	if size < 1 {
+		size = 1
+	}
+	return c.clock + float64(ent.weight)*ent.cost/float64(size)
+}
+
+// removeOverflow removes the lowest priority items, except keep, until the
+// size fits the capacity.
+// removeOverflow 淘汰优先级最低的数据(不包括 keep),直到总大小不超过缓存容量
+func (c *GDSF) removeOverflow(keep *entry) {
+	for c.evictList.Len() > 0 && c.used > int64(c.size) {
+		ent := c.evictList[0]
+		if ent == keep {
+			// 暂时移出堆, 淘汰其余数据后再放回
+			heap.Remove(&c.evictList, keep.index)
+			c.removeOverflow(nil)
+			heap.Push(&c.evictList, keep)
+			return
+		}
+		c.evict(ent, ReasonCapacity)
+	}
+}
+
+// removeOldest removes the lowest priority item from the cache.
+// removeOldest 从缓存中移除优先级最低的项。
+func (c *GDSF) removeOldest(reason EvictionReason) {
+	if c.evictList.Len() > 0 {
+		c.evict(c.evictList[0], reason)
+	}
+}
+
+// evict removes an entry because of its priority and ages the clock.
+// evict 按优先级淘汰一个条目, 并将 clock 提升到其优先级
+func (c *GDSF) evict(ent *entry, reason EvictionReason) {
+	if ent.priority > c.clock {
+		c.clock = ent.priority
+	}
+	c.removeElement(ent, reason)
+}
+
+// live returns the entry of a key, removing it if it has expired.
+// live 返回一个键的条目, 已过期时将其删除
+func (c *GDSF) live(key interface{}) (ent *entry, ok bool) {
+	if ent, ok = c.items[key]; ok {
+		if checkExpirationTime(ent.expirationTime) {
+			c.removeElement(ent, ReasonExpired)
+			return nil, false
+		}
+	}
+	return ent, ok
+}
+
+// jittered returns the expiration time moved by a random offset.
+// jittered 返回随机调整后的过期时间
+func (c *GDSF) jittered(expirationTime int64) int64 {
+	if c.rand == nil || expirationTime == 0 {
+		return expirationTime
+	}
+	now := time.Now().UnixNano() / 1e6
+	life := expirationTime - now
+	if life <= 0 {
+		return expirationTime
+	}
+	var span int64
+	if c.jitter.Percent > 0 {
+		span = int64(float64(life) * c.jitter.Percent / 100)
+	}
+	if limit := int64(c.jitter.Max / time.Millisecond); limit > 0 && (span == 0 || span > limit) {
+		span = limit
+	}
+	if span <= 0 {
+		return expirationTime
+	}
+	// 抽取 [-1, 1) 内的比例再按范围缩放, 相同种子抽取的比例与当前时间无关
+	life += int64((2*c.rand.Float64() - 1) * float64(span))
+	if life < 1 {
+		life = 1
+	}
+	return now + life
+}
+
+// nextVersion returns a new version number.
+// nextVersion 分配一个新的版本号
+func (c *GDSF) nextVersion() uint64 {
+	c.version++
+	return c.version
+}
+
+// removeElement is used to remove a given entry from the cache
+// removeElement 从缓存中移除一个条目
+func (c *GDSF) removeElement(ent *entry, reason EvictionReason) {
+	heap.Remove(&c.evictList, ent.index)
+	delete(c.items, ent.key)
+	c.used -= ent.size
+	if c.onEvict != nil {
+		c.onEvict(ent.key, ent.value, ent.expirationTime, reason)
+	}
+}
+
+// sizeOf returns the size of an entry computed by the sizer.
+// sizeOf 计算条目的大小, 未设置 sizer 时为1
+func (c *GDSF) sizeOf(key, value interface{}) int64 {
+	if c.sizer == nil {
+		return 1
+	}
+	return c.sizer(key, value)
+}
+
+// setExpirationTime sets the expiration time and the lifetime it implies.
+// setExpirationTime 设置过期时间及对应的存活时长
+func (e *entry) setExpirationTime(expirationTime int64) {
+	e.expirationTime = expirationTime
+	e.ttl = lifetime(expirationTime, time.Now().UnixNano()/1e6)
+	e.sliding = false
+}
+
+// slide extends the expiration time by the lifetime.
+// slide 按存活时长延长过期时间
+func (e *entry) slide() {
+	if e.expirationTime != 0 {
+		e.expirationTime = time.Now().UnixNano()/1e6 + e.ttl
+	}
+}
+
+// info returns the entry and its metadata.
+// info 返回条目及其元数据
+func (e *entry) info() Entry {
+	var ttl time.Duration
+	if e.expirationTime != 0 {
+		ttl = time.Duration(e.expirationTime-time.Now().UnixNano()/1e6) * time.Millisecond
+	}
+	return Entry{
+		Key:            e.key,
+		Value:          e.value,
+		ExpirationTime: e.expirationTime,
+		CreationTime:   e.created,
+		AccessTime:     e.accessed,
+		AccessCount:    e.weight,
+		Cost:           e.size,
+		TTL:            ttl,
+		Lifetime:       time.Duration(e.ttl) * time.Millisecond,
+		Sliding:        e.sliding,
+		Version:        e.version,
+	}
+}
+
+// lifetime returns the time to live in milliseconds of an expiration time
+// set at now, 0 if it never expires.
+// lifetime 返回在 now 设置的过期时间对应的存活时长(毫秒), 永不过期时为0
+func lifetime(expirationTime, now int64) int64 {
+	if expirationTime == 0 || expirationTime <= now {
+		return 0
+	}
+	return expirationTime - now
+}
+
+// reasonCallback adapts an EvictCallback, which is not called for
+// replaced values.
+// reasonCallback 将 EvictCallback 转换为 EvictReasonCallback, 值被替换时不回调
+func reasonCallback(onEvict EvictCallback) EvictReasonCallback {
+	if onEvict == nil {
+		return nil
+	}
+	return func(key interface{}, value interface{}, expirationTime int64, reason EvictionReason) {
+		if reason != ReasonReplaced {
+			onEvict(key, value, expirationTime)
+		}
+	}
+}
+
+// entryHeap is a min-heap of entries ordered by priority
+// entryHeap 按优先级排序的最小堆
+type entryHeap []*entry
+
+func (h entryHeap) Len() int { return len(h) }
+
+func (h entryHeap) Less(i, j int) bool {
+	if h[i].priority == h[j].priority {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].priority < h[j].priority
+}
+
+func (h entryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *entryHeap) Push(x interface{}) {
+	ent := x.(*entry)
+	ent.index = len(*h)
+	*h = append(*h, ent)
+}
+
+func (h *entryHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	ent := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return ent
+}
+
+// indexHeap is a min-heap of indexes into an entryHeap, implementing
+// heap.Interface.
+// indexHeap entryHeap 下标的最小堆, 实现 heap.Interface
+type indexHeap struct {
+	ents  entryHeap
+	index []int
+}
+
+func (h *indexHeap) Len() int           { return len(h.index) }
+func (h *indexHeap) Less(i, j int) bool { return h.ents.Less(h.index[i], h.index[j]) }
+func (h *indexHeap) Swap(i, j int)      { h.index[i], h.index[j] = h.index[j], h.index[i] }
+
+func (h *indexHeap) Push(x interface{}) {
+	h.index = append(h.index, x.(int))
+}
+
+func (h *indexHeap) Pop() interface{} {
+	i := h.index[len(h.index)-1]
+	h.index = h.index[:len(h.index)-1]
+	return i
+}
+
+// checkExpirationTime is Determine if the cache has expired
+// checkExpirationTime 判断缓存是否已经过期
+func checkExpirationTime(expirationTime int64) (ok bool) {
+	if 0 != expirationTime && expirationTime <= time.Now().UnixNano()/1e6 {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,320 @@
+package simplegdsf
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGDSF(t *testing.T) {
+
+	initTime := initTime()
+	evictCounter := 0
+	onEvicted := func(k interface{}, v interface{}, expirationTime int64) {
+		if k != v {
+			t.Fatalf("Evict values not equal (%v!=%v) , time = %v", k, v, expirationTime)
+		}
+		evictCounter++
+	}
+	l, err := NewGDSF(128, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 256; i++ {
+		l.Add(i, i, initTime)
+		for c := 0; c < i; c++ {
+			l.Get(i)
+		}
+	}
+
+	if l.Len() != 128 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+
+	if evictCounter != 128 {
+		t.Fatalf("bad evict count: %v", evictCounter)
+	}
+
+	for i, k := range l.Keys() {
+		if v, expirationTime, ok := l.Peek(k); !ok || v != k || v != i+128 {
+			t.Fatalf("bad i: %v, key: %v, v: %v, time: %v", i, k, v, expirationTime)
+		}
+	}
+	// FirstKeys 与 Keys 的开头一致
+	keys := l.Keys()
+	for _, n := range []int{0, 1, 10, 128, 200} {
+		want := keys
+		if n < len(want) {
+			want = want[:n]
+		}
+		first := l.FirstKeys(n)
+		if len(first) != len(want) {
+			t.Fatalf("bad first keys: %v", first)
+		}
+		for i := range first {
+			if first[i] != want[i] {
+				t.Fatalf("bad first keys: %v", first)
+			}
+		}
+	}
+	for i := 0; i < 128; i++ {
+		_, expirationTime, ok := l.Get(i)
+		if ok {
+			t.Fatalf("should be evicted , time: %v", expirationTime)
+		}
+	}
+
+	for i := 128; i < 192; i++ {
+		ok := l.Remove(i)
+		if !ok {
+			t.Fatalf("should be contained")
+		}
+		ok = l.Remove(i)
+		if ok {
+			t.Fatalf("should not be contained")
+		}
+	}
+	if l.Len() != 64 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+
+	l.Purge()
+	if l.Len() != 0 || l.Cost() != 0 {
+		t.Fatalf("bad len: %v, cost: %v", l.Len(), l.Cost())
+	}
+	if _, expirationTime, ok := l.Get(200); ok {
+		t.Fatalf("should contain nothing, time: %v", expirationTime)
+	}
+}
+
+// Test that cheap large entries are evicted before expensive small ones
+func TestGDSF_AddWithSizeCost(t *testing.T) {
+	initTime := initTime()
+
+	l, err := NewGDSF(100, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// 大而廉价 priority = 1*10/50 = 0.2
+	l.AddWithSizeCost("large", "large", 50, 10, initTime)
+	// 小而昂贵 priority = 1*100/10 = 10
+	l.AddWithSizeCost("small", "small", 10, 100, initTime)
+	// 中等 priority = 1*30/30 = 1
+	l.AddWithSizeCost("medium", "medium", 30, 30, initTime)
+
+	if k, _, _, ok := l.GetOldest(); !ok || k != "large" {
+		t.Fatalf("bad oldest: %v", k)
+	}
+
+	// 需要淘汰 large 才能放入
+	if !l.AddWithSizeCost("new", "new", 40, 40, initTime) {
+		t.Fatalf("new should have been added")
+	}
+	if l.Contains("large") || !l.Contains("small") || !l.Contains("medium") {
+		t.Fatalf("bad keys: %v", l.Keys())
+	}
+	if l.Cost() != 80 {
+		t.Fatalf("bad cost: %v", l.Cost())
+	}
+
+	if l.AddWithSizeCost("huge", "huge", 101, 1, initTime) {
+		t.Fatalf("huge should not have been added")
+	}
+
+	// 已存在的数据更新后大小超过缓存容量, 因容量不足被淘汰
+	var reasons []EvictionReason
+	l.onEvict = func(k interface{}, v interface{}, expirationTime int64, reason EvictionReason) {
+		reasons = append(reasons, reason)
+	}
+	if l.AddWithSizeCost("small", "small", 101, 1, initTime) {
+		t.Fatalf("small should not have been added")
+	}
+	if len(reasons) != 1 || reasons[0] != ReasonCapacity || l.Contains("small") || l.Cost() != 70 {
+		t.Fatalf("bad reasons: %v, cost: %v", reasons, l.Cost())
+	}
+}
+
+// Test that the clock ages entries which are no longer accessed
+func TestGDSF_Clock(t *testing.T) {
+	initTime := initTime()
+
+	l, err := NewGDSF(2, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1, initTime)
+	for i := 0; i < 5; i++ {
+		l.Get(1)
+	}
+	// 不断写入新的数据, clock 逐渐升高, 新数据最终会超过 1 的优先级
+	for i := 2; i < 20; i++ {
+		l.Add(i, i, initTime)
+		l.Get(i)
+	}
+	if l.Contains(1) {
+		t.Fatalf("1 should have aged out")
+	}
+}
+
+// Test that Contains and Peek don't update the priority
+func TestGDSF_Peek(t *testing.T) {
+	initTime := initTime()
+
+	l, err := NewGDSF(2, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1, initTime)
+	l.Add(2, 2, initTime)
+	l.Get(2)
+	if v, _, ok := l.Peek(1); !ok || v != 1 {
+		t.Errorf("1 should be set to 1: %v, %v", v, ok)
+	}
+	if !l.Contains(1) {
+		t.Errorf("1 should be contained")
+	}
+
+	l.Add(3, 3, initTime)
+	if l.Contains(1) {
+		t.Errorf("should not have updated priority of 1")
+	}
+
+	// 2 与 3 优先级相同, 先淘汰较早放入的 2
+	k, _, _, ok := l.RemoveOldest()
+	if !ok || k != 2 {
+		t.Fatalf("bad: %v", k)
+	}
+}
+
+// Test that Resize can upsize and downsize
+func TestGDSF_Resize(t *testing.T) {
+	initTime := initTime()
+
+	onEvictCounter := 0
+	onEvicted := func(k interface{}, v interface{}, expirationTime int64) {
+		onEvictCounter++
+	}
+	l, err := NewGDSF(2, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Downsize
+	l.Add(1, 1, initTime)
+	l.Get(1)
+	l.Add(2, 2, initTime)
+	evicted := l.Resize(1)
+	if evicted != 1 {
+		t.Errorf("1 element should have been evicted: %v", evicted)
+	}
+	if onEvictCounter != 1 {
+		t.Errorf("onEvicted should have been called 1 time: %v", onEvictCounter)
+	}
+	if !l.Contains(1) {
+		t.Errorf("Element 1 should have been kept")
+	}
+
+	// Upsize
+	evicted = l.Resize(2)
+	if evicted != 0 {
+		t.Errorf("0 elements should have been evicted: %v", evicted)
+	}
+
+	l.Add(3, 3, initTime)
+	if !l.Contains(1) || !l.Contains(3) {
+		t.Errorf("Cache should have contained 2 elements")
+	}
+}
+
+// Test that expired entries are removed
+func TestGDSF_PurgeOverdue(t *testing.T) {
+	l, err := NewGDSF(10, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1, time.Now().UnixNano()/1e6-1)
+	l.Add(2, 2, 0)
+	l.PurgeOverdue()
+	if l.Len() != 1 || l.Contains(1) || !l.Contains(2) {
+		t.Fatalf("bad keys: %v", l.Keys())
+	}
+}
+
+// Test that entries report their metadata
+func TestGDSF_Entry(t *testing.T) {
+	initTime := initTime()
+
+	l, err := NewGDSF(10, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.AddWithSizeCost(1, 1, 4, 2, initTime)
+	l.Get(1)
+	e, ok := l.PeekEntry(1)
+	if !ok || e.Value != 1 || e.AccessCount != 2 || e.Cost != 4 || e.TTL <= 0 || e.CreationTime == 0 {
+		t.Fatalf("bad entry: %+v", e)
+	}
+	if e, _ = l.GetEntry(1); e.AccessCount != 3 {
+		t.Fatalf("GetEntry should update the weight: %v", e.AccessCount)
+	}
+}
+
+// Test that AddEntry keeps the frequency and the recompute cost
+func TestGDSF_AddEntry(t *testing.T) {
+	l, err := NewGDSF(10, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.AddEntry(Entry{Key: 1, Value: 1, AccessCount: 5, Cost: 2, Version: 7}, 3)
+	l.Add(2, 2, 0)
+	if cost, ok := l.RecomputeCost(1); !ok || cost != 3 {
+		t.Fatalf("bad cost: %v", cost)
+	}
+	e, _ := l.PeekEntry(1)
+	if e.AccessCount != 5 || e.Cost != 2 || e.Version != 7 {
+		t.Fatalf("bad entry: %+v", e)
+	}
+	// 2 的优先级更低, 最先淘汰
+	if k := l.Keys(); k[0] != 2 {
+		t.Fatalf("bad keys: %v", k)
+	}
+}
+
+// Test that Expire, Persist, Touch and TTL only change the expiration
+func TestGDSF_Expire(t *testing.T) {
+	l, err := NewGDSF(2, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	now := time.Now().UnixNano() / 1e6
+	l.Add(1, 1, now+100)
+	time.Sleep(60 * time.Millisecond)
+	if !l.Touch(1) {
+		t.Fatalf("1 should be contained")
+	}
+	if ttl, ok := l.TTL(1); !ok || ttl <= 60*time.Millisecond {
+		t.Fatalf("bad ttl: %v", ttl)
+	}
+	if !l.Persist(1) {
+		t.Fatalf("1 should be contained")
+	}
+	if _, expirationTime, _ := l.Peek(1); expirationTime != 0 {
+		t.Fatalf("1 should not expire: %v", expirationTime)
+	}
+	l.Expire(1, now-1)
+	if l.Contains(1) {
+		t.Fatalf("1 should have expired")
+	}
+}
+
+// 生成当前时间
+func initTime() int64 {
+	return time.Now().UnixNano()/1e6 + 2000
+}
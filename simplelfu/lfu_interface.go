@@ -1,20 +1,73 @@
 package simplelfu
 
+import "time"
+
 // LFUCache 是简单LFU缓存的接口。
 type LFUCache interface {
 
 	// Add 向缓存添加一个值。如果已经存在,则更新信息
 	Add(key, value interface{}, expirationTime int64) (ok bool)
 
+	// AddWithCost 向缓存添加一个指定成本的值,淘汰数据直到可以放入
+	AddWithCost(key, value interface{}, cost int64, expirationTime int64) (ok bool)
+
 	// Get 从缓存中查找一个键的值。
 	Get(key interface{}) (value interface{}, expirationTime int64, ok bool)
 
+	// GetEntry 从缓存中查找一个键的条目及元数据
+	GetEntry(key interface{}) (e Entry, ok bool)
+
+	// PeekEntry 返回一个键的条目及元数据, 不更新缓存的状态
+	PeekEntry(key interface{}) (e Entry, ok bool)
+
+	// AddEntry 向缓存添加一个条目并保留其元数据, AccessCount 作为权重
+	AddEntry(e Entry) (ok bool)
+
+	// GetWithVersion 从缓存中查找一个键的值及版本号
+	GetWithVersion(key interface{}) (value interface{}, expirationTime int64, version uint64, ok bool)
+
+	// CompareAndSwap 当键的版本号等于 expectedVersion 时替换其值
+	CompareAndSwap(key interface{}, expectedVersion uint64, value interface{}) (version uint64, swapped bool)
+
+	// CompareAndDelete 当键的版本号等于 expectedVersion 时移除该键
+	CompareAndDelete(key interface{}, expectedVersion uint64) (deleted bool)
+
+	// Update 用 fn 的结果替换一个键的值并保留过期时间
+	Update(key interface{}, fn func(old interface{}, exists bool) (value interface{}, keep bool)) (value interface{}, ok bool)
+
+	// Compute 用 fn 的结果及给定的过期时间替换一个键的值
+	Compute(key interface{}, expirationTime int64, fn func(old interface{}, exists bool) (value interface{}, keep bool)) (value interface{}, ok bool)
+
+	// ComputeIfAbsent 返回一个键的值, 不存在时放入 fn 的结果
+	ComputeIfAbsent(key interface{}, expirationTime int64, fn func() (value interface{}, keep bool)) (value interface{}, ok bool)
+
 	// Contains 检查某个键是否在缓存中，但不更新缓存的状态
 	Contains(key interface{}) (ok bool)
 
 	// Peek 在不更新的情况下返回键值(如果没有找到则返回false),不更新缓存的状态
 	Peek(key interface{}) (value interface{}, expirationTime int64, ok bool)
 
+	// Expire 设置一个键的过期时间, 不更新缓存的状态
+	Expire(key interface{}, expirationTime int64) (ok bool)
+
+	// Persist 移除一个键的过期时间, 使其永不过期
+	Persist(key interface{}) (ok bool)
+
+	// Touch 按设置过期时间时的存活时长延长一个键的过期时间
+	Touch(key interface{}) (ok bool)
+
+	// TTL 返回一个键的剩余存活时间, 永不过期时为0
+	TTL(key interface{}) (ttl time.Duration, ok bool)
+
+	// AddSliding 向缓存添加一个滑动过期的值, 空闲 idle 时长后过期
+	AddSliding(key, value interface{}, idle time.Duration) (ok bool)
+
+	// ExpireSliding 设置一个键为滑动过期, 空闲 idle 时长后过期
+	ExpireSliding(key interface{}, idle time.Duration) (ok bool)
+
+	// SetSlidingExpiration 开启或关闭滑动过期模式, 开启后每次 Get 都会延长过期时间
+	SetSlidingExpiration(sliding bool)
+
 	// Remove 从缓存中移除提供的键。
 	Remove(key interface{}) (ok bool)
 
@@ -27,16 +80,31 @@ type LFUCache interface {
 	// Keys 返回缓存中键的切片，从最老到最新
 	Keys() []interface{}
 
+	// FirstKeys 返回缓存中至多 n 个键，从最老到最新
+	FirstKeys(n int) []interface{}
+
 	// Len 获取缓存已存在的缓存条数
 	Len() int
 
+	// Cap 获取缓存的容量
+	Cap() int
+
+	// Cost 获取缓存中所有条目的总成本
+	Cost() int64
+
+	// SetSizer 设置计算条目成本的函数
+	SetSizer(sizer Sizer)
+
+	// SetJitter 设置新增条目过期时间的随机调整
+	SetJitter(jitter Jitter)
+
 	// Purge 清除所有缓存项
 	Purge()
 
 	// PurgeOverdue 清除所有过期缓存项。
 	PurgeOverdue()
 
-	// Resize 调整缓存大小，返回调整前的数量
+	// Resize 调整缓存容量(总成本)，返回淘汰的数量
 	Resize(int) int
 
 	// ResizeWeight 调整缓存中weight引用次数
@@ -237,6 +237,299 @@ func TestLFU_Resize(t *testing.T) {
 	}
 }
 
+// Test that AddWithCost evicts until the new entry fits
+func TestLFU_AddWithCost(t *testing.T) {
+	initTime := initTime()
+
+	evictCounter := 0
+	onEvicted := func(k interface{}, v interface{}, expirationTime int64) {
+		evictCounter++
+	}
+	l, err := NewLFU(10, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.AddWithCost(1, 1, 4, initTime)
+	l.AddWithCost(2, 2, 4, initTime)
+	if l.Cost() != 8 {
+		t.Fatalf("bad cost: %v", l.Cost())
+	}
+
+	// 需要淘汰两条数据才能放入
+	if !l.AddWithCost(3, 3, 9, initTime) {
+		t.Fatalf("3 should have been added")
+	}
+	if evictCounter != 2 || l.Len() != 1 || l.Cost() != 9 {
+		t.Fatalf("bad evict count: %v, len: %v, cost: %v", evictCounter, l.Len(), l.Cost())
+	}
+
+	// 成本超过缓存容量
+	if l.AddWithCost(4, 4, 11, initTime) {
+		t.Fatalf("4 should not have been added")
+	}
+	if l.Contains(4) || !l.Contains(3) {
+		t.Fatalf("bad contents: %v", l.Keys())
+	}
+
+	// 更新已存在的数据时重新计算成本
+	l.AddWithCost(3, 3, 2, initTime)
+	if l.Cost() != 2 {
+		t.Fatalf("bad cost: %v", l.Cost())
+	}
+
+	evicted := l.Resize(1)
+	if evicted != 1 || l.Len() != 0 || l.Cost() != 0 {
+		t.Fatalf("bad evicted: %v, len: %v, cost: %v", evicted, l.Len(), l.Cost())
+	}
+
+	// 已存在的数据更新后成本超过缓存容量, 因容量不足被淘汰
+	l.AddWithCost(5, 5, 1, initTime)
+	evictCounter = 0
+	if l.AddWithCost(5, 5, 2, initTime) {
+		t.Fatalf("5 should not have been added")
+	}
+	if evictCounter != 1 || l.Contains(5) || l.Cost() != 0 {
+		t.Fatalf("bad evict count: %v, cost: %v", evictCounter, l.Cost())
+	}
+}
+
+// Test that Add uses the sizer to compute costs
+func TestLFU_Sizer(t *testing.T) {
+	initTime := initTime()
+
+	l, err := NewLFU(10, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.SetSizer(func(k interface{}, v interface{}) int64 {
+		return int64(len(v.(string)))
+	})
+
+	l.Add(1, "aaaa", initTime)
+	l.Add(2, "bbbb", initTime)
+	l.Add(3, "cccc", initTime)
+	if l.Len() != 2 || l.Cost() != 8 {
+		t.Fatalf("bad len: %v, cost: %v", l.Len(), l.Cost())
+	}
+	if l.Contains(2) {
+		t.Fatalf("2 should have been evicted")
+	}
+}
+
+// Test that the eviction callback receives the reason
+func TestLFU_EvictReason(t *testing.T) {
+	initTime := initTime()
+
+	reasons := make(map[interface{}]EvictionReason)
+	onEvicted := func(k interface{}, v interface{}, expirationTime int64, reason EvictionReason) {
+		reasons[v] = reason
+	}
+	l, err := NewLFUWithEvictReason(2, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, "replaced", initTime)
+	l.Add(1, "removed", initTime)
+	l.Add(2, "expired", time.Now().UnixNano()/1e6-1)
+	l.Contains(2)
+	l.Remove(1)
+
+	l.Add(3, "capacity", initTime)
+	l.Add(4, "resized", initTime)
+	l.Get(4)
+	l.Add(5, "purged", initTime)
+	l.Get(5)
+	l.Get(5)
+	l.Resize(1)
+	l.Purge()
+
+	expected := map[interface{}]EvictionReason{
+		"capacity": ReasonCapacity,
+		"replaced": ReasonReplaced,
+		"expired":  ReasonExpired,
+		"removed":  ReasonRemoved,
+		"resized":  ReasonResized,
+		"purged":   ReasonPurged,
+	}
+	for v, reason := range expected {
+		if reasons[v] != reason {
+			t.Errorf("bad reason for %v: %v", v, reasons[v])
+		}
+	}
+}
+
+// Test that the callback without reason is not called for replaced values
+func TestLFU_EvictReplaced(t *testing.T) {
+	initTime := initTime()
+
+	evictCounter := 0
+	onEvicted := func(k interface{}, v interface{}, expirationTime int64) {
+		evictCounter++
+	}
+	l, err := NewLFU(2, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1, initTime)
+	l.Add(1, 2, initTime)
+	if evictCounter != 0 {
+		t.Errorf("bad evict count: %v", evictCounter)
+	}
+}
+
+// Test that entries report their weight and AddEntry keeps it
+func TestLFU_Entry(t *testing.T) {
+	initTime := initTime()
+
+	l, err := NewLFU(3, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1, initTime)
+	l.Add(2, 2, initTime)
+	l.Get(1)
+
+	e, ok := l.PeekEntry(1)
+	if !ok || e.Value != 1 || e.AccessCount != 2 || e.Cost != 1 || e.TTL <= 0 {
+		t.Fatalf("bad entry: %+v", e)
+	}
+	if e, _ = l.PeekEntry(1); e.AccessCount != 2 {
+		t.Fatalf("PeekEntry should not update the weight: %v", e.AccessCount)
+	}
+	if e, _ = l.GetEntry(1); e.AccessCount != 3 {
+		t.Fatalf("GetEntry should update the weight: %v", e.AccessCount)
+	}
+
+	// 权重为5的条目排在最前, 不会被优先淘汰
+	if !l.AddEntry(Entry{Key: 3, Value: 3, AccessCount: 5, Cost: 1, CreationTime: 1}) {
+		t.Fatalf("3 should have been added")
+	}
+	if e, _ = l.PeekEntry(3); e.AccessCount != 5 || e.CreationTime != 1 {
+		t.Fatalf("bad entry: %+v", e)
+	}
+	if k := l.Keys(); k[0] != 2 || k[2] != 3 {
+		t.Fatalf("bad keys: %v", k)
+	}
+}
+
+// Test that peeking an expired key reports it missing and removes it
+func TestLFU_PeekExpired(t *testing.T) {
+	l, err := NewLFU(2, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1, time.Now().UnixNano()/1e6-1)
+	if _, ok := l.PeekEntry(1); ok {
+		t.Fatalf("1 should have expired")
+	}
+	l.Add(1, 1, time.Now().UnixNano()/1e6-1)
+	if _, _, ok := l.Peek(1); ok || l.Len() != 0 {
+		t.Fatalf("1 should have been removed")
+	}
+}
+
+// Test that Expire, Persist, Touch and TTL only change the expiration
+func TestLFU_Expire(t *testing.T) {
+	l, err := NewLFU(2, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	now := time.Now().UnixNano() / 1e6
+	l.Add(1, 1, now+100)
+	l.Add(2, 2, 0)
+
+	if !l.Expire(2, now+60000) {
+		t.Fatalf("2 should be contained")
+	}
+	if ttl, ok := l.TTL(2); !ok || ttl <= 59*time.Second {
+		t.Fatalf("bad ttl: %v, %v", ttl, ok)
+	}
+	if !l.Persist(2) {
+		t.Fatalf("2 should be contained")
+	}
+	if ttl, ok := l.TTL(2); !ok || ttl != 0 {
+		t.Fatalf("bad ttl: %v, %v", ttl, ok)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if !l.Touch(1) {
+		t.Fatalf("1 should be contained")
+	}
+	if ttl, _ := l.TTL(1); ttl <= 60*time.Millisecond {
+		t.Fatalf("bad ttl: %v", ttl)
+	}
+	if e, _ := l.PeekEntry(1); e.AccessCount != 1 {
+		t.Fatalf("should not have updated the weight: %v", e.AccessCount)
+	}
+
+	l.Expire(1, now-1)
+	if _, ok := l.TTL(1); ok {
+		t.Fatalf("1 should have expired")
+	}
+}
+
+// Test that Get extends sliding entries and Peek doesn't
+func TestLFU_Sliding(t *testing.T) {
+	l, err := NewLFU(4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.AddSliding(1, 1, 100*time.Millisecond)
+	l.AddSliding(2, 2, 100*time.Millisecond)
+	for i := 0; i < 3; i++ {
+		time.Sleep(50 * time.Millisecond)
+		if _, _, ok := l.Get(1); !ok {
+			t.Fatalf("1 should have been extended")
+		}
+		l.Peek(2)
+	}
+	if l.Contains(2) {
+		t.Fatalf("2 should have expired")
+	}
+
+	l.SetSlidingExpiration(true)
+	l.Add(3, 3, time.Now().UnixNano()/1e6+100)
+	for i := 0; i < 3; i++ {
+		time.Sleep(50 * time.Millisecond)
+		if _, _, ok := l.Get(3); !ok {
+			t.Fatalf("3 should have been extended")
+		}
+	}
+}
+
+// Test that writes change the version and CAS checks it
+func TestLFU_CompareAndSwap(t *testing.T) {
+	initTime := initTime()
+
+	l, err := NewLFU(2, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1, initTime)
+	_, _, v1, _ := l.GetWithVersion(1)
+	v2, ok := l.CompareAndSwap(1, v1, 2)
+	if !ok || v2 <= v1 {
+		t.Fatalf("should swap: %v, %v", v2, ok)
+	}
+	if _, ok := l.CompareAndSwap(1, v1, 3); ok {
+		t.Fatalf("should not swap with a stale version")
+	}
+	if v, _, _ := l.Peek(1); v != 2 {
+		t.Fatalf("bad value: %v", v)
+	}
+	if !l.CompareAndDelete(1, v2) || l.Contains(1) {
+		t.Fatalf("1 should have been deleted")
+	}
+}
+
 // 生成当前时间 + 2秒
 func initTime() int64 {
 	return time.Now().UnixNano()/1e6 + 2000
@@ -3,19 +3,95 @@ package simplelru
 import (
 	"container/list"
 	"errors"
+	"math/rand"
 	"time"
 )
 // EvictCallback is used to get a callback when a cache entry is evicted
 // EvictCallback 用于在缓存条目被淘汰时的回调函数
 type EvictCallback func(key interface{}, value interface{}, expirationTime int64)
 
+// EvictReasonCallback is used to get a callback with the reason when a cache
+// entry is evicted
+// EvictReasonCallback 用于在缓存条目被淘汰时的回调函数, 同时返回淘汰原因
+type EvictReasonCallback func(key interface{}, value interface{}, expirationTime int64, reason EvictionReason)
+
+// Jitter randomizes the expiration times of added entries, so that entries
+// added together don't expire together. The lifetime of an entry is moved by
+// a random offset within ±Percent% of the lifetime, or within ±Max when
+// Percent is not set; Max also caps the percentage range.
+// Jitter 随机调整新增条目的过期时间, 避免同时放入的条目同时过期。存活时长在
+// ±Percent% 范围内随机调整, 未设置 Percent 时在 ±Max 范围内调整; 同时设置时 Max 为调整上限
+type Jitter struct {
+	// Percent 按存活时长的百分比随机调整, 如 10 表示 ±10%
+	Percent float64
+	// Max 随机调整的最大时长
+	Max time.Duration
+	// Seed 随机数种子, 为0时使用当前时间; 相同的种子按相同的比例序列调整存活时长
+	Seed int64
+}
+
+// AddCallback is used to get a callback when a value is stored
+// AddCallback 用于在值写入缓存时的回调函数
+type AddCallback func(key interface{})
+
+// Sizer is used to compute the cost of a cache entry
+// Sizer 用于计算缓存条目的成本(如占用的字节数)
+type Sizer func(key interface{}, value interface{}) int64
+
+// EvictionReason describes why an entry left the cache
+// EvictionReason 缓存条目被淘汰的原因
+type EvictionReason int
+
+const (
+	// ReasonCapacity 因容量不足被淘汰
+	ReasonCapacity EvictionReason = iota
+	// ReasonExpired 因过期被删除
+	ReasonExpired
+	// ReasonRemoved 被主动移除
+	ReasonRemoved
+	// ReasonReplaced 旧值被新值替换
+	ReasonReplaced
+	// ReasonPurged 因清空缓存被删除
+	ReasonPurged
+	// ReasonResized 因调整缓存大小被淘汰
+	ReasonResized
+)
+
+// String returns the name of the reason
+// String 返回淘汰原因的名称
+func (r EvictionReason) String() string {
+	switch r {
+	case ReasonCapacity:
+		return "capacity"
+	case ReasonExpired:
+		return "expired"
+	case ReasonRemoved:
+		return "removed"
+	case ReasonReplaced:
+		return "replaced"
+	case ReasonPurged:
+		return "purged"
+	case ReasonResized:
+		return "resized"
+	}
+	return "unknown"
+}
+
 // LRU implements a non-thread safe fixed size LRU cache
 // LRU 实现一个非线程安全的固定大小的LRU缓存
 type LRU struct {
 	size      int
+	cost      int64 // 当前缓存的总成本
 	evictList *list.List
 	items     map[interface{}]*list.Element
-	onEvict   EvictCallback
+	onEvict   EvictReasonCallback
+	onAdd     AddCallback
+	sizer     Sizer
+	sliding   bool // 滑动过期模式
+	jitter    Jitter
+	rand      *rand.Rand
+	version   uint64  // 最近分配的版本号
+	versions  *uint64 // 与其它缓存共用的版本号计数器, 为nil时使用 version
 }
 
 // entry is used to hold a value in the evictList
@@ -24,11 +100,41 @@ type entry struct {
 	key            interface{}
 	value          interface{}
 	expirationTime int64
+	cost           int64
+	created        int64  // 创建时间
+	accessed       int64  // 最后访问时间
+	ttl            int64  // 设置过期时间时的存活时长(毫秒)
+	sliding        bool   // 是否为滑动过期
+	version        uint64 // 版本号, 每次写入时分配
+	accesses       int64  // 访问次数
+}
+
+// Entry describes a cache entry and its metadata
+// Entry 缓存条目及其元数据, 时间均为毫秒时间戳
+type Entry struct {
+	Key            interface{}
+	Value          interface{}
+	ExpirationTime int64         // 过期时间, 0为永不过期
+	CreationTime   int64         // 创建时间
+	AccessTime     int64         // 最后访问时间
+	AccessCount    int64         // 访问次数, LFU 中为权重
+	Cost           int64         // 成本
+	TTL            time.Duration // 剩余存活时间, 永不过期时为0
+	Lifetime       time.Duration // 设置过期时间时的存活时长, Touch 按此时长延长
+	Sliding        bool          // 是否为滑动过期, Get 时按 Lifetime 延长过期时间
+	Version        uint64        // 版本号, 每次写入时分配
 }
 
 // NewLRU constructs an LRU of the given size
 // NewLRU 构造一个给定大小的LRU
 func NewLRU(size int, onEvict EvictCallback) (*LRU, error) {
+	return NewLRUWithEvictReason(size, reasonCallback(onEvict))
+}
+
+// NewLRUWithEvictReason constructs an LRU of the given size whose eviction
+// callback receives the eviction reason
+// NewLRUWithEvictReason 构造一个给定大小的LRU, 淘汰回调中包含淘汰原因
+func NewLRUWithEvictReason(size int, onEvict EvictReasonCallback) (*LRU, error) {
 	if size <= 0 {
 		return nil, errors.New("must provide a positive size")
 	}
@@ -46,11 +152,12 @@ func NewLRU(size int, onEvict EvictCallback) (*LRU, error) {
 func (c *LRU) Purge() {
 	for k, v := range c.items {
 		if c.onEvict != nil {
-			c.onEvict(k, v.Value.(*entry).value, v.Value.(*entry).expirationTime)
+			c.onEvict(k, v.Value.(*entry).value, v.Value.(*entry).expirationTime, ReasonPurged)
 		}
 		delete(c.items, k)
 	}
 	c.evictList.Init()
+	c.cost = 0
 }
 
 // PurgeOverdue is used to completely clear the overdue cache.
@@ -59,33 +166,76 @@ func (c *LRU) PurgeOverdue() {
 	for _, ent := range c.items {
 		// 判断此值是否已经超时,如果超时则进行删除
 		if checkExpirationTime(ent.Value.(*entry).expirationTime) {
-			c.removeElement(ent)
+			c.removeElement(ent, ReasonExpired)
 		}
 	}
-	c.evictList.Init()
 }
 
 // Add adds a value to the cache.  Returns true if an eviction occurred.
 // Add 向缓存添加一个值。如果已经存在,则更新信息
 func (c *LRU) Add(key, value interface{}, expirationTime int64) (ok bool) {
+	return c.AddWithCost(key, value, c.costOf(key, value), expirationTime)
+}
+
+// AddWithCost adds a value with the given cost to the cache, evicting the
+// oldest entries until it fits. Returns false if the cost exceeds the capacity.
+// AddWithCost 向缓存添加一个指定成本的值,淘汰最老的数据直到可以放入。
+// 成本超过缓存总容量时不会放入,返回false
+func (c *LRU) AddWithCost(key, value interface{}, cost int64, expirationTime int64) (ok bool) {
+	return c.add(key, value, cost, c.jittered(expirationTime))
+}
+
+// add adds a value with the given cost to the cache.
+// add 向缓存添加一个指定成本的值
+func (c *LRU) add(key, value interface{}, cost int64, expirationTime int64) (ok bool) {
+	if cost < 0 {
+		cost = 0
+	}
+	// 成本超过缓存总容量,因容量不足移除已存在的旧数据,不再放入
+	if cost > int64(c.size) {
+		if ent, ok := c.items[key]; ok {
+			c.removeElement(ent, ReasonCapacity)
+		}
+		return false
+	}
 	// 判断缓存中是否已经存在数据,如果已经存在则更新数据
 	if ent, ok := c.items[key]; ok {
 		c.evictList.MoveToFront(ent)
+		ent.Value.(*entry).touch()
+		if c.onEvict != nil {
+			c.onEvict(key, ent.Value.(*entry).value, ent.Value.(*entry).expirationTime, ReasonReplaced)
+		}
+		c.cost += cost - ent.Value.(*entry).cost
 		ent.Value.(*entry).value = value
-		ent.Value.(*entry).expirationTime = expirationTime
+		ent.Value.(*entry).setExpirationTime(expirationTime)
+		ent.Value.(*entry).cost = cost
+		ent.Value.(*entry).version = c.nextVersion()
+		c.removeOverflow()
+		c.added(key)
 		return true
 	}
-	// 判断缓存条数是否已经达到限制
-	if c.evictList.Len() >= c.size {
-		c.removeOldest()
+	// 淘汰最老的数据,直到新数据可以放入
+	for c.evictList.Len() > 0 && c.cost+cost > int64(c.size) {
+		c.removeOldest(ReasonCapacity)
 	}
 	// 创建数据
-	ent := &entry{key, value, expirationTime}
+	now := time.Now().UnixNano() / 1e6
+	ent := &entry{key, value, expirationTime, cost, now, now, lifetime(expirationTime, now), false, c.nextVersion(), 0}
 
 	c.items[key] = c.evictList.PushFront(ent)
+	c.cost += cost
+	c.added(key)
 	return true
 }
 
+// added calls the add callback.
+// added 调用写入回调
+func (c *LRU) added(key interface{}) {
+	if c.onAdd != nil {
+		c.onAdd(key)
+	}
+}
+
 // Get looks up a key's value from the cache.
 // Get 从缓存中查找一个键的值。
 func (c *LRU) Get(key interface{}) (value interface{}, expirationTime int64, ok bool) {
@@ -93,16 +243,194 @@ func (c *LRU) Get(key interface{}) (value interface{}, expirationTime int64, ok
 	if ent, ok := c.items[key]; ok {
 		// 判断此值是否已经超时,如果超时则进行删除
 		if checkExpirationTime(ent.Value.(*entry).expirationTime) {
-			c.removeElement(ent)
+			c.removeElement(ent, ReasonExpired)
 			return nil, 0, false
 		}
 		// 数据移到头部
 		c.evictList.MoveToFront(ent)
+		ent.Value.(*entry).touch()
+		if c.sliding || ent.Value.(*entry).sliding {
+			ent.Value.(*entry).slide()
+		}
 		return ent.Value.(*entry).value, ent.Value.(*entry).expirationTime, true
 	}
 	return nil, 0, false
 }
 
+// GetWithVersion looks up a key's value and version from the cache,
+// updating the recent-ness like Get.
+// GetWithVersion 从缓存中查找一个键的值及版本号, 与 Get 一样更新缓存的状态
+func (c *LRU) GetWithVersion(key interface{}) (value interface{}, expirationTime int64, version uint64, ok bool) {
+	if value, expirationTime, ok = c.Get(key); ok {
+		version = c.items[key].Value.(*entry).version
+	}
+	return value, expirationTime, version, ok
+}
+
+// CompareAndSwap replaces the value of a key, keeping its expiration time,
+// if its version is expectedVersion. Returns the current version and
+// whether the value was replaced. The entry is removed if the new value
+// exceeds the capacity.
+// CompareAndSwap 当键的版本号等于 expectedVersion 时替换其值并保留过期时间,
+// 返回当前版本号及是否替换成功。新值超过缓存总容量时条目被移除
+func (c *LRU) CompareAndSwap(key interface{}, expectedVersion uint64, value interface{}) (version uint64, swapped bool) {
+	ent, ok := c.live(key)
+	if !ok {
+		return 0, false
+	}
+	if ent.Value.(*entry).version != expectedVersion {
+		return ent.Value.(*entry).version, false
+	}
+	ttl, sliding := ent.Value.(*entry).ttl, ent.Value.(*entry).sliding
+	if !c.add(key, value, c.costOf(key, value), ent.Value.(*entry).expirationTime) {
+		return 0, false
+	}
+	ent.Value.(*entry).ttl, ent.Value.(*entry).sliding = ttl, sliding
+	return ent.Value.(*entry).version, true
+}
+
+// CompareAndDelete removes a key if its version is expectedVersion.
+// CompareAndDelete 当键的版本号等于 expectedVersion 时移除该键
+func (c *LRU) CompareAndDelete(key interface{}, expectedVersion uint64) (deleted bool) {
+	ent, ok := c.live(key)
+	if !ok || ent.Value.(*entry).version != expectedVersion {
+		return false
+	}
+	c.removeElement(ent, ReasonRemoved)
+	return true
+}
+
+// Update replaces the value of a key by the result of fn, keeping its
+// expiration time. fn receives the current value and whether the key
+// exists, the key is removed when fn returns keep false and new keys never
+// expire. Returns the value in the cache and whether the key is present.
+// fn is called under the cache lock and must not use the cache.
+// Update 用 fn 的结果替换一个键的值并保留过期时间。fn 接收当前值及键是否存在,
+// 返回 keep 为false时移除该键, 新增的键永不过期。返回缓存中的值及键是否存在。
+// fn 在缓存锁内执行, 不能访问缓存
+func (c *LRU) Update(key interface{}, fn func(old interface{}, exists bool) (value interface{}, keep bool)) (value interface{}, ok bool) {
+	ent, exists := c.live(key)
+	var old interface{}
+	if exists {
+		old = ent.Value.(*entry).value
+	}
+	value, keep := fn(old, exists)
+	switch {
+	case !keep:
+		if exists {
+			c.removeElement(ent, ReasonRemoved)
+		}
+		return nil, false
+	case exists:
+		_, ok = c.CompareAndSwap(key, ent.Value.(*entry).version, value)
+	default:
+		ok = c.Add(key, value, 0)
+	}
+	if !ok {
+		return nil, false
+	}
+	return value, true
+}
+
+// Compute replaces the value of a key by the result of fn with the given
+// expiration time. fn receives the current value and whether the key
+// exists, the key is removed when fn returns keep false. Returns the value
+// in the cache and whether the key is present.
+// fn is called under the cache lock and must not use the cache.
+// Compute 用 fn 的结果及给定的过期时间替换一个键的值。fn 接收当前值及键是否存在,
+// 返回 keep 为false时移除该键。返回缓存中的值及键是否存在。fn 在缓存锁内执行, 不能访问缓存
+func (c *LRU) Compute(key interface{}, expirationTime int64, fn func(old interface{}, exists bool) (value interface{}, keep bool)) (value interface{}, ok bool) {
+	ent, exists := c.live(key)
+	var old interface{}
+	if exists {
+		old = ent.Value.(*entry).value
+	}
+	value, keep := fn(old, exists)
+	if !keep {
+		if exists {
+			c.removeElement(ent, ReasonRemoved)
+		}
+		return nil, false
+	}
+	if !c.Add(key, value, expirationTime) {
+		return nil, false
+	}
+	return value, true
+}
+
+// ComputeIfAbsent returns the value of a key like Get, or adds the result
+// of fn with the given expiration time if the key is missing and fn
+// returns keep true. Returns the value in the cache and whether the key is
+// present. fn is called under the cache lock and must not use the cache.
+// ComputeIfAbsent 与 Get 一样返回一个键的值, 键不存在时调用 fn, 返回 keep 为true时以给定的过期时间放入缓存。
+// 返回缓存中的值及键是否存在。fn 在缓存锁内执行, 不能访问缓存
+func (c *LRU) ComputeIfAbsent(key interface{}, expirationTime int64, fn func() (value interface{}, keep bool)) (value interface{}, ok bool) {
+	if value, _, ok = c.Get(key); ok {
+		return value, true
+	}
+	value, keep := fn()
+	if !keep || !c.Add(key, value, expirationTime) {
+		return nil, false
+	}
+	return value, true
+}
+
+// GetEntry looks up a key's entry and metadata from the cache, updating
+// the recent-ness like Get.
+// GetEntry 从缓存中查找一个键的条目及元数据, 与 Get 一样更新缓存的状态
+func (c *LRU) GetEntry(key interface{}) (e Entry, ok bool) {
+	if _, _, ok = c.Get(key); ok {
+		e = c.items[key].Value.(*entry).info()
+	}
+	return e, ok
+}
+
+// PeekEntry returns a key's entry and metadata without updating the
+// recent-ness of the key.
+// PeekEntry 返回一个键的条目及元数据, 不更新缓存的状态
+func (c *LRU) PeekEntry(key interface{}) (e Entry, ok bool) {
+	if _, _, ok = c.Peek(key); ok {
+		e = c.items[key].Value.(*entry).info()
+	}
+	return e, ok
+}
+
+// AddEntry adds an entry to the cache keeping its metadata, used to move
+// entries between caches. An entry without Lifetime has a new expiration
+// time, which is jittered. Returns false if the cost exceeds the capacity.
+// AddEntry 向缓存添加一个条目并保留其元数据, 用于在缓存之间移动条目。
+// 成本超过缓存总容量时不会放入,返回false
+func (c *LRU) AddEntry(e Entry) (ok bool) {
+	expirationTime := e.ExpirationTime
+	if e.Lifetime == 0 {
+		// 新设置的过期时间
+		expirationTime = c.jittered(expirationTime)
+	}
+	if !c.add(e.Key, e.Value, e.Cost, expirationTime) {
+		return false
+	}
+	ent := c.items[e.Key].Value.(*entry)
+	if e.CreationTime != 0 {
+		ent.created = e.CreationTime
+	}
+	if e.AccessTime != 0 {
+		ent.accessed = e.AccessTime
+	}
+	if e.Lifetime > 0 {
+		ent.ttl = int64(e.Lifetime / time.Millisecond)
+	}
+	ent.sliding = e.Sliding
+	if e.Version != 0 {
+		// 保留版本号, 之后分配的版本号大于它
+		ent.version = e.Version
+		if v := c.counter(); e.Version > *v {
+			*v = e.Version
+		}
+	}
+	ent.accesses = e.AccessCount
+	return true
+}
+
 // Contains checks if a key is in the cache, without updating the recent-ness
 // or deleting it for being stale.
 // Contains 检查某个键是否在缓存中，但不更新缓存的状态
@@ -111,7 +439,7 @@ func (c *LRU) Contains(key interface{}) (ok bool) {
 	if ok {
 		// 判断此值是否已经超时,如果超时则进行删除
 		if checkExpirationTime(ent.Value.(*entry).expirationTime) {
-			c.removeElement(ent)
+			c.removeElement(ent, ReasonExpired)
 			return !ok
 		}
 	}
@@ -126,20 +454,90 @@ func (c *LRU) Peek(key interface{}) (value interface{}, expirationTime int64, ok
 	if ent, ok = c.items[key]; ok {
 		// 判断是否已经超时
 		if checkExpirationTime(ent.Value.(*entry).expirationTime) {
-			c.removeElement(ent)
-			return nil, 0, ok
+			c.removeElement(ent, ReasonExpired)
+			return nil, 0, false
 		}
 		return ent.Value.(*entry).value, ent.Value.(*entry).expirationTime, true
 	}
 	return nil, 0, ok
 }
 
+// Expire sets the expiration time of a key without updating its recent-ness.
+// Expire 设置一个键的过期时间, 不更新缓存的状态
+func (c *LRU) Expire(key interface{}, expirationTime int64) (ok bool) {
+	ent, ok := c.live(key)
+	if ok {
+		ent.Value.(*entry).setExpirationTime(expirationTime)
+	}
+	return ok
+}
+
+// Persist removes the expiration time of a key.
+// Persist 移除一个键的过期时间, 使其永不过期
+func (c *LRU) Persist(key interface{}) (ok bool) {
+	return c.Expire(key, 0)
+}
+
+// Touch extends the expiration time of a key by the lifetime it was given
+// when its expiration time was set.
+// Touch 按设置过期时间时的存活时长延长一个键的过期时间, 不更新缓存的状态
+func (c *LRU) Touch(key interface{}) (ok bool) {
+	ent, ok := c.live(key)
+	if ok {
+		ent.Value.(*entry).slide()
+	}
+	return ok
+}
+
+// TTL returns the remaining time to live of a key, 0 if it never expires.
+// TTL 返回一个键的剩余存活时间, 永不过期时为0
+func (c *LRU) TTL(key interface{}) (ttl time.Duration, ok bool) {
+	ent, ok := c.live(key)
+	if ok && ent.Value.(*entry).expirationTime != 0 {
+		ttl = time.Duration(ent.Value.(*entry).expirationTime-time.Now().UnixNano()/1e6) * time.Millisecond
+	}
+	return ttl, ok
+}
+
+// AddSliding adds a value which expires after being idle for the given
+// duration, every Get extends its expiration time.
+// AddSliding 向缓存添加一个滑动过期的值, 空闲 idle 时长后过期, 每次 Get 都会延长过期时间
+func (c *LRU) AddSliding(key, value interface{}, idle time.Duration) (ok bool) {
+	if !c.Add(key, value, 0) {
+		return false
+	}
+	return c.ExpireSliding(key, idle)
+}
+
+// ExpireSliding makes a key expire after being idle for the given duration,
+// without updating its recent-ness.
+// ExpireSliding 设置一个键为滑动过期, 空闲 idle 时长后过期, 不更新缓存的状态
+func (c *LRU) ExpireSliding(key interface{}, idle time.Duration) (ok bool) {
+	ent, ok := c.live(key)
+	if ok {
+		// 存活时长取 idle, 不受两次读取时间之间的误差影响
+		ttl := int64(idle / time.Millisecond)
+		ent.Value.(*entry).setExpirationTime(time.Now().UnixNano()/1e6 + ttl)
+		ent.Value.(*entry).ttl, ent.Value.(*entry).sliding = ttl, true
+	}
+	return ok
+}
+
+// SetSlidingExpiration turns the sliding expiration mode on or off. In this
+// mode every Get extends the expiration time of an entry by the lifetime it
+// was given when its expiration time was set, Peek does not.
+// SetSlidingExpiration 开启或关闭滑动过期模式。开启后每次 Get 都会按设置过期时间时的存活时长
+// 延长条目的过期时间, Peek 不会延长
+func (c *LRU) SetSlidingExpiration(sliding bool) {
+	c.sliding = sliding
+}
+
 // Remove removes the provided key from the cache, returning if the
 // key was contained.
 // Remove 从缓存中移除提供的键
 func (c *LRU) Remove(key interface{}) (ok bool) {
 	if ent, ok := c.items[key]; ok {
-		c.removeElement(ent)
+		c.removeElement(ent, ReasonRemoved)
 		return ok
 	}
 	return ok
@@ -151,11 +549,11 @@ func (c *LRU) RemoveOldest() (key interface{}, value interface{}, expirationTime
 	if ent := c.evictList.Back(); ent != nil {
 		// 判断是否已经超时
 		if checkExpirationTime(ent.Value.(*entry).expirationTime) {
-			c.removeElement(ent)
+			c.removeElement(ent, ReasonExpired)
 			return c.RemoveOldest()
 		}
 
-		c.removeElement(ent)
+		c.removeElement(ent, ReasonRemoved)
 		return ent.Value.(*entry).key, ent.Value.(*entry).value, ent.Value.(*entry).expirationTime, true
 	}
 	return nil, nil, 0, false
@@ -167,7 +565,7 @@ func (c *LRU) GetOldest() (key interface{}, value interface{}, expirationTime in
 	if ent := c.evictList.Back(); ent != nil {
 		// 判断此值是否已经超时,如果超时则进行删除
 		if checkExpirationTime(ent.Value.(*entry).expirationTime) {
-			c.removeElement(ent)
+			c.removeElement(ent, ReasonExpired)
 			return c.GetOldest()
 		}
 		return ent.Value.(*entry).key, ent.Value.(*entry).value, ent.Value.(*entry).expirationTime, true
@@ -178,11 +576,22 @@ func (c *LRU) GetOldest() (key interface{}, value interface{}, expirationTime in
 // Keys returns a slice of the keys in the cache, from oldest to newest.
 // Keys 返回缓存的切片，从最老的到最新的。
 func (c *LRU) Keys() []interface{} {
-	keys := make([]interface{}, len(c.items))
-	i := 0
-	for ent := c.evictList.Back(); ent != nil; ent = ent.Prev() {
-		keys[i] = ent.Value.(*entry).key
-		i++
+	return c.FirstKeys(len(c.items))
+}
+
+// FirstKeys returns up to n keys of the cache, from oldest to newest,
+// without walking the others.
+// FirstKeys 返回缓存中至多 n 个键，从最老的到最新的，不遍历其余的键
+func (c *LRU) FirstKeys(n int) []interface{} {
+	if n > len(c.items) {
+		n = len(c.items)
+	}
+	if n < 0 {
+		n = 0
+	}
+	keys := make([]interface{}, 0, n)
+	for ent := c.evictList.Back(); ent != nil && len(keys) < n; ent = ent.Prev() {
+		keys = append(keys, ent.Value.(*entry).key)
 	}
 	return keys
 }
@@ -193,37 +602,232 @@ func (c *LRU) Len() int {
 	return c.evictList.Len()
 }
 
+// Cap returns the capacity of the cache, a total cost when a sizer is set.
+// Cap 返回缓存的容量, 设置了成本函数时为总成本
+func (c *LRU) Cap() int {
+	return c.size
+}
+
+// Cost returns the total cost of items in the cache.
+// Cost 返回缓存中所有条目的总成本
+func (c *LRU) Cost() int64 {
+	return c.cost
+}
+
+// SetAddCallback sets the function called with the key of every value
+// stored, whether added or replacing another one, nil removes it.
+// SetAddCallback 设置每次写入值时以其键调用的函数, 包括新增和替换, 为nil时移除
+func (c *LRU) SetAddCallback(onAdd AddCallback) {
+	c.onAdd = onAdd
+}
+
+// SetSizer sets the function used by Add to compute entry costs.
+// SetSizer 设置 Add 计算条目成本的函数, 为nil时每条数据成本为1
+func (c *LRU) SetSizer(sizer Sizer) {
+	c.sizer = sizer
+}
+
+// SetJitter sets the jitter applied to the expiration times of added
+// entries, a zero Jitter turns it off.
+// SetJitter 设置新增条目过期时间的随机调整, Jitter 为零值时关闭
+func (c *LRU) SetJitter(jitter Jitter) {
+	c.jitter = jitter
+	c.rand = nil
+	if jitter.Percent > 0 || jitter.Max > 0 {
+		seed := jitter.Seed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		c.rand = rand.New(rand.NewSource(seed))
+	}
+}
+
 // Resize changes the cache size.
 // Resize 改变缓存大小。
 func (c *LRU) Resize(size int) (evicted int) {
-	diff := c.Len() - size
-	if diff < 0 {
-		diff = 0
-	}
-	for i := 0; i < diff; i++ {
-		c.removeOldest()
-	}
 	c.size = size
-	return diff
+	for c.evictList.Len() > 0 && c.cost > int64(c.size) {
+		c.removeOldest(ReasonResized)
+		evicted++
+	}
+	return evicted
 }
 
 // removeOldest removes the oldest item from the cache.
 // removeOldest 从缓存中移除最老的项。
-func (c *LRU) removeOldest() {
+func (c *LRU) removeOldest(reason EvictionReason) {
 	ent := c.evictList.Back()
 	if ent != nil {
-		c.removeElement(ent)
+		c.removeElement(ent, reason)
+	}
+}
+
+// removeOverflow removes the oldest items until the cost fits the capacity.
+// removeOverflow 淘汰最老的数据,直到总成本不超过缓存容量
+func (c *LRU) removeOverflow() {
+	for c.evictList.Len() > 0 && c.cost > int64(c.size) {
+		c.removeOldest(ReasonCapacity)
+	}
+}
+
+// costOf returns the cost of an entry computed by the sizer.
+// costOf 计算条目的成本, 未设置 sizer 时为1
+func (c *LRU) costOf(key, value interface{}) int64 {
+	if c.sizer == nil {
+		return 1
 	}
+	return c.sizer(key, value)
+}
+
+// live returns the element of a key, removing it if it has expired.
+// live 返回一个键的列表元素, 已过期时将其删除
+func (c *LRU) live(key interface{}) (ent *list.Element, ok bool) {
+	if ent, ok = c.items[key]; ok {
+		if checkExpirationTime(ent.Value.(*entry).expirationTime) {
+			c.removeElement(ent, ReasonExpired)
+			return nil, false
+		}
+	}
+	return ent, ok
+}
+
+// jittered returns the expiration time moved by a random offset.
+// jittered 返回随机调整后的过期时间
+func (c *LRU) jittered(expirationTime int64) int64 {
+	if c.rand == nil || expirationTime == 0 {
+		return expirationTime
+	}
+	now := time.Now().UnixNano() / 1e6
+	life := expirationTime - now
+	if life <= 0 {
+		return expirationTime
+	}
+	var span int64
+	if c.jitter.Percent > 0 {
+		span = int64(float64(life) * c.jitter.Percent / 100)
+	}
+	if limit := int64(c.jitter.Max / time.Millisecond); limit > 0 && (span == 0 || span > limit) {
+		span = limit
+	}
+	if span <= 0 {
+		return expirationTime
+	}
+	// 抽取 [-1, 1) 内的比例再按范围缩放, 相同种子抽取的比例与当前时间无关
+	life += int64((2*c.rand.Float64() - 1) * float64(span))
+	if life < 1 {
+		life = 1
+	}
+	return now + life
+}
+
+// nextVersion returns a new version number.
+// nextVersion 分配一个新的版本号
+func (c *LRU) nextVersion() uint64 {
+	v := c.counter()
+	*v++
+	return *v
+}
+
+// counter returns the version counter in use.
+// counter 返回使用中的版本号计数器
+func (c *LRU) counter() *uint64 {
+	if c.versions != nil {
+		return c.versions
+	}
+	return &c.version
+}
+
+// ShareVersions makes the cache draw its version numbers from counter, so
+// that caches sharing it, e.g. the lists of a cache moving entries between
+// them, never assign a version twice. The counter must only be used under
+// the lock guarding all these caches.
+// ShareVersions 使缓存从 counter 分配版本号, 共用计数器的缓存(例如条目在其间移动的多个列表)不会重复分配版本号。
+// 计数器只能在保护这些缓存的同一把锁内使用
+func (c *LRU) ShareVersions(counter *uint64) {
+	if c.version > *counter {
+		*counter = c.version
+	}
+	c.versions = counter
 }
 
 // removeElement is used to remove a given list element from the cache
 // removeElement 从缓存中移除一个列表元素
-func (c *LRU) removeElement(e *list.Element) {
+func (c *LRU) removeElement(e *list.Element, reason EvictionReason) {
 	c.evictList.Remove(e)
 	delete(c.items, e.Value.(*entry).key)
+	c.cost -= e.Value.(*entry).cost
 	if c.onEvict != nil {
-		c.onEvict(e.Value.(*entry).key, e.Value.(*entry).value, e.Value.(*entry).expirationTime)
+		c.onEvict(e.Value.(*entry).key, e.Value.(*entry).value, e.Value.(*entry).expirationTime, reason)
+	}
+}
+
+// touch records an access of the entry.
+// touch 记录一次访问
+func (e *entry) touch() {
+	e.accessed = time.Now().UnixNano() / 1e6
+	e.accesses++
+}
+
+// setExpirationTime sets the expiration time and the lifetime it implies.
+// setExpirationTime 设置过期时间及对应的存活时长
+func (e *entry) setExpirationTime(expirationTime int64) {
+	e.expirationTime = expirationTime
+	e.ttl = lifetime(expirationTime, time.Now().UnixNano()/1e6)
+	e.sliding = false
+}
+
+// slide extends the expiration time by the lifetime.
+// slide 按存活时长延长过期时间
+func (e *entry) slide() {
+	if e.expirationTime != 0 {
+		e.expirationTime = time.Now().UnixNano()/1e6 + e.ttl
+	}
+}
+
+// info returns the entry and its metadata.
+// info 返回条目及其元数据
+func (e *entry) info() Entry {
+	var ttl time.Duration
+	if e.expirationTime != 0 {
+		ttl = time.Duration(e.expirationTime-time.Now().UnixNano()/1e6) * time.Millisecond
+	}
+	return Entry{
+		Key:            e.key,
+		Value:          e.value,
+		ExpirationTime: e.expirationTime,
+		CreationTime:   e.created,
+		AccessTime:     e.accessed,
+		AccessCount:    e.accesses,
+		Cost:           e.cost,
+		TTL:            ttl,
+		Lifetime:       time.Duration(e.ttl) * time.Millisecond,
+		Sliding:        e.sliding,
+		Version:        e.version,
+	}
+}
+
+// reasonCallback adapts an EvictCallback, which is not called for
+// replaced values.
+// reasonCallback 将 EvictCallback 转换为 EvictReasonCallback, 值被替换时不回调
+func reasonCallback(onEvict EvictCallback) EvictReasonCallback {
+	if onEvict == nil {
+		return nil
+	}
+	return func(key interface{}, value interface{}, expirationTime int64, reason EvictionReason) {
+		if reason != ReasonReplaced {
+			onEvict(key, value, expirationTime)
+		}
+	}
+}
+
+// lifetime returns the time to live in milliseconds of an expiration time
+// set at now, 0 if it never expires.
+// lifetime 返回在 now 设置的过期时间对应的存活时长(毫秒), 永不过期时为0
+func lifetime(expirationTime, now int64) int64 {
+	if expirationTime == 0 || expirationTime <= now {
+		return 0
 	}
+	return expirationTime - now
 }
 
 // checkExpirationTime is Determine if the cache has expired
@@ -224,6 +224,498 @@ func TestLRU_Resize(t *testing.T) {
 	}
 }
 
+// Test that AddWithCost evicts until the new entry fits
+func TestLRU_AddWithCost(t *testing.T) {
+	initTime := initTime()
+
+	evictCounter := 0
+	onEvicted := func(k interface{}, v interface{}, expirationTime int64) {
+		evictCounter++
+	}
+	l, err := NewLRU(10, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.AddWithCost(1, 1, 4, initTime)
+	l.AddWithCost(2, 2, 4, initTime)
+	if l.Cost() != 8 {
+		t.Fatalf("bad cost: %v", l.Cost())
+	}
+
+	// 需要淘汰两条数据才能放入
+	if !l.AddWithCost(3, 3, 9, initTime) {
+		t.Fatalf("3 should have been added")
+	}
+	if evictCounter != 2 || l.Len() != 1 || l.Cost() != 9 {
+		t.Fatalf("bad evict count: %v, len: %v, cost: %v", evictCounter, l.Len(), l.Cost())
+	}
+
+	// 成本超过缓存容量
+	if l.AddWithCost(4, 4, 11, initTime) {
+		t.Fatalf("4 should not have been added")
+	}
+	if l.Contains(4) || !l.Contains(3) {
+		t.Fatalf("bad contents: %v", l.Keys())
+	}
+
+	// 更新已存在的数据时重新计算成本
+	l.AddWithCost(3, 3, 2, initTime)
+	if l.Cost() != 2 {
+		t.Fatalf("bad cost: %v", l.Cost())
+	}
+
+	evicted := l.Resize(1)
+	if evicted != 1 || l.Len() != 0 || l.Cost() != 0 {
+		t.Fatalf("bad evicted: %v, len: %v, cost: %v", evicted, l.Len(), l.Cost())
+	}
+
+	// 已存在的数据更新后成本超过缓存容量, 因容量不足被淘汰
+	l.AddWithCost(5, 5, 1, initTime)
+	evictCounter = 0
+	if l.AddWithCost(5, 5, 2, initTime) {
+		t.Fatalf("5 should not have been added")
+	}
+	if evictCounter != 1 || l.Contains(5) || l.Cost() != 0 {
+		t.Fatalf("bad evict count: %v, cost: %v", evictCounter, l.Cost())
+	}
+}
+
+// Test that Add uses the sizer to compute costs
+func TestLRU_Sizer(t *testing.T) {
+	initTime := initTime()
+
+	l, err := NewLRU(10, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.SetSizer(func(k interface{}, v interface{}) int64 {
+		return int64(len(v.(string)))
+	})
+
+	l.Add(1, "aaaa", initTime)
+	l.Add(2, "bbbb", initTime)
+	l.Add(3, "cccc", initTime)
+	if l.Len() != 2 || l.Cost() != 8 {
+		t.Fatalf("bad len: %v, cost: %v", l.Len(), l.Cost())
+	}
+	if l.Contains(1) {
+		t.Fatalf("1 should have been evicted")
+	}
+}
+
+// Test that the eviction callback receives the reason
+func TestLRU_EvictReason(t *testing.T) {
+	initTime := initTime()
+
+	reasons := make(map[interface{}]EvictionReason)
+	onEvicted := func(k interface{}, v interface{}, expirationTime int64, reason EvictionReason) {
+		reasons[v] = reason
+	}
+	l, err := NewLRUWithEvictReason(2, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, "replaced", initTime)
+	l.Add(1, "removed", initTime)
+	l.Add(2, "expired", time.Now().UnixNano()/1e6-1)
+	l.Contains(2)
+	l.Remove(1)
+
+	l.Add(3, "capacity", initTime)
+	l.Add(4, "resized", initTime)
+	l.Get(4)
+	l.Add(5, "purged", initTime)
+	l.Get(5)
+	l.Get(5)
+	l.Resize(1)
+	l.Purge()
+
+	expected := map[interface{}]EvictionReason{
+		"capacity": ReasonCapacity,
+		"replaced": ReasonReplaced,
+		"expired":  ReasonExpired,
+		"removed":  ReasonRemoved,
+		"resized":  ReasonResized,
+		"purged":   ReasonPurged,
+	}
+	for v, reason := range expected {
+		if reasons[v] != reason {
+			t.Errorf("bad reason for %v: %v", v, reasons[v])
+		}
+	}
+}
+
+// Test that the callback without reason is not called for replaced values
+func TestLRU_EvictReplaced(t *testing.T) {
+	initTime := initTime()
+
+	evictCounter := 0
+	onEvicted := func(k interface{}, v interface{}, expirationTime int64) {
+		evictCounter++
+	}
+	l, err := NewLRU(2, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1, initTime)
+	l.Add(1, 2, initTime)
+	if evictCounter != 0 {
+		t.Errorf("bad evict count: %v", evictCounter)
+	}
+}
+
+// Test that entries report their metadata and PeekEntry doesn't update it
+func TestLRU_Entry(t *testing.T) {
+	l, err := NewLRU(2, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	expirationTime := time.Now().UnixNano()/1e6 + 60000
+	l.AddWithCost(1, 1, 2, expirationTime)
+	l.Get(1)
+	l.Get(1)
+
+	e, ok := l.PeekEntry(1)
+	if !ok || e.Key != 1 || e.Value != 1 || e.ExpirationTime != expirationTime || e.Cost != 2 {
+		t.Fatalf("bad entry: %+v", e)
+	}
+	if e.AccessCount != 2 || e.CreationTime == 0 || e.AccessTime < e.CreationTime {
+		t.Fatalf("bad metadata: %+v", e)
+	}
+	if e.TTL <= 59*time.Second || e.TTL > time.Minute {
+		t.Fatalf("bad ttl: %v", e.TTL)
+	}
+	if e, _ = l.PeekEntry(1); e.AccessCount != 2 {
+		t.Fatalf("PeekEntry should not count as an access: %v", e.AccessCount)
+	}
+	if e, _ = l.GetEntry(1); e.AccessCount != 3 {
+		t.Fatalf("GetEntry should count as an access: %v", e.AccessCount)
+	}
+	if _, ok = l.PeekEntry(2); ok {
+		t.Fatalf("2 should not be contained")
+	}
+
+	// AddEntry 保留元数据
+	e.Key = 3
+	e.CreationTime = 1
+	if !l.AddEntry(e) {
+		t.Fatalf("3 should have been added")
+	}
+	if e, _ = l.PeekEntry(3); e.CreationTime != 1 || e.AccessCount != 3 || e.Cost != 2 {
+		t.Fatalf("bad entry: %+v", e)
+	}
+	if l.Contains(1) {
+		t.Fatalf("1 should have been evicted")
+	}
+}
+
+// Test that peeking an expired key reports it missing and removes it
+func TestLRU_PeekExpired(t *testing.T) {
+	l, err := NewLRU(2, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1, time.Now().UnixNano()/1e6-1)
+	if _, ok := l.PeekEntry(1); ok {
+		t.Fatalf("1 should have expired")
+	}
+	l.Add(1, 1, time.Now().UnixNano()/1e6-1)
+	if _, _, ok := l.Peek(1); ok || l.Len() != 0 {
+		t.Fatalf("1 should have been removed")
+	}
+}
+
+// Test that Expire, Persist, Touch and TTL only change the expiration
+func TestLRU_Expire(t *testing.T) {
+	l, err := NewLRU(2, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	now := time.Now().UnixNano() / 1e6
+	l.Add(1, 1, now+1000)
+	l.Add(2, 2, 0)
+
+	if ttl, ok := l.TTL(2); !ok || ttl != 0 {
+		t.Fatalf("bad ttl: %v, %v", ttl, ok)
+	}
+	if !l.Expire(2, now+60000) {
+		t.Fatalf("2 should be contained")
+	}
+	if ttl, ok := l.TTL(2); !ok || ttl <= 59*time.Second || ttl > time.Minute {
+		t.Fatalf("bad ttl: %v, %v", ttl, ok)
+	}
+	if !l.Persist(2) {
+		t.Fatalf("2 should be contained")
+	}
+	if _, expirationTime, _ := l.Peek(2); expirationTime != 0 {
+		t.Fatalf("2 should not expire: %v", expirationTime)
+	}
+
+	// Touch 按原存活时长延长过期时间
+	l.Expire(1, now+100)
+	time.Sleep(60 * time.Millisecond)
+	if !l.Touch(1) {
+		t.Fatalf("1 should be contained")
+	}
+	if ttl, _ := l.TTL(1); ttl <= 60*time.Millisecond {
+		t.Fatalf("bad ttl: %v", ttl)
+	}
+
+	// 不更新缓存的状态
+	if k := l.Keys(); k[0] != 1 || k[1] != 2 {
+		t.Fatalf("bad keys: %v", k)
+	}
+
+	l.Expire(1, now-1)
+	if l.Touch(1) || l.Contains(1) {
+		t.Fatalf("1 should have expired")
+	}
+	if l.Expire(3, 0) || l.Persist(3) {
+		t.Fatalf("3 should not be contained")
+	}
+}
+
+// Test that Get extends sliding entries and Peek doesn't
+func TestLRU_Sliding(t *testing.T) {
+	l, err := NewLRU(4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.AddSliding(1, 1, 100*time.Millisecond)
+	l.Add(2, 2, time.Now().UnixNano()/1e6+100)
+	for i := 0; i < 3; i++ {
+		time.Sleep(50 * time.Millisecond)
+		if _, _, ok := l.Get(1); !ok {
+			t.Fatalf("1 should have been extended")
+		}
+		l.Peek(2)
+	}
+	if l.Contains(2) {
+		t.Fatalf("2 should have expired")
+	}
+	if e, _ := l.PeekEntry(1); !e.Sliding || e.Lifetime != 100*time.Millisecond {
+		t.Fatalf("bad entry: %+v", e)
+	}
+
+	// 重新设置过期时间后不再滑动
+	l.Expire(1, time.Now().UnixNano()/1e6+100)
+	if e, _ := l.PeekEntry(1); e.Sliding {
+		t.Fatalf("1 should not slide: %+v", e)
+	}
+
+	// 滑动过期模式
+	l.SetSlidingExpiration(true)
+	l.Add(3, 3, time.Now().UnixNano()/1e6+100)
+	l.Add(4, 4, 0)
+	for i := 0; i < 3; i++ {
+		time.Sleep(50 * time.Millisecond)
+		if _, _, ok := l.Get(3); !ok {
+			t.Fatalf("3 should have been extended")
+		}
+	}
+	if _, expirationTime, ok := l.Get(4); !ok || expirationTime != 0 {
+		t.Fatalf("4 should not expire: %v", expirationTime)
+	}
+}
+
+// Test that the jitter spreads expiration times within its range
+func TestLRU_Jitter(t *testing.T) {
+	newLRU := func(jitter Jitter) *LRU {
+		l, err := NewLRU(100, nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		l.SetJitter(jitter)
+		return l
+	}
+
+	expirationTime := time.Now().UnixNano()/1e6 + 100000
+	l1 := newLRU(Jitter{Max: 10 * time.Second, Seed: 1})
+	l2 := newLRU(Jitter{Max: 10 * time.Second, Seed: 1})
+	seen := make(map[int64]bool)
+	for i := 0; i < 100; i++ {
+		l1.Add(i, i, expirationTime)
+		l2.Add(i, i, expirationTime)
+		_, e1, _ := l1.Peek(i)
+		_, e2, _ := l2.Peek(i)
+		if e1 != e2 {
+			t.Fatalf("same seed should give the same expiration: %v != %v", e1, e2)
+		}
+		if e1 < expirationTime-10000 || e1 > expirationTime+10000 {
+			t.Fatalf("expiration out of range: %v", e1-expirationTime)
+		}
+		seen[e1] = true
+	}
+	if len(seen) < 50 {
+		t.Fatalf("expiration times should be spread: %v", len(seen))
+	}
+
+	// 按百分比调整
+	l := newLRU(Jitter{Percent: 10})
+	for i := 0; i < 100; i++ {
+		l.Add(i, i, expirationTime)
+		if _, e, _ := l.Peek(i); e < expirationTime-10100 || e > expirationTime+10000 {
+			t.Fatalf("expiration out of range: %v", e-expirationTime)
+		}
+	}
+
+	// 按百分比调整时相同的种子同样可重复, 只有两次添加之间存活时长的变化按比例带来差异
+	start := time.Now()
+	l1 = newLRU(Jitter{Percent: 10, Seed: 2})
+	for i := 0; i < 100; i++ {
+		l1.Add(i, i, expirationTime)
+	}
+	time.Sleep(5 * time.Millisecond)
+	l2 = newLRU(Jitter{Percent: 10, Seed: 2})
+	for i := 0; i < 100; i++ {
+		l2.Add(i, i, expirationTime)
+	}
+	limit := int64(time.Since(start)/time.Millisecond)/10 + 1
+	for i := 0; i < 100; i++ {
+		_, e1, _ := l1.Peek(i)
+		_, e2, _ := l2.Peek(i)
+		if d := e1 - e2; d < -limit || d > limit {
+			t.Fatalf("same seed should give the same expiration: %v != %v", e1, e2)
+		}
+	}
+
+	// Max 限制调整范围
+	l = newLRU(Jitter{Percent: 10, Max: 5 * time.Millisecond, Seed: 1})
+	for i := 0; i < 100; i++ {
+		l.Add(i, i, expirationTime)
+		if _, e, _ := l.Peek(i); e < expirationTime-100 || e > expirationTime+5 {
+			t.Fatalf("expiration out of range: %v", e-expirationTime)
+		}
+	}
+
+	// 永不过期的条目不受影响
+	l.Add(100, 100, 0)
+	if _, e, _ := l.Peek(100); e != 0 {
+		t.Fatalf("100 should not expire: %v", e)
+	}
+}
+
+// Test that writes change the version and CAS checks it
+func TestLRU_CompareAndSwap(t *testing.T) {
+	initTime := initTime()
+
+	l, err := NewLRU(2, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1, initTime)
+	_, _, v1, ok := l.GetWithVersion(1)
+	if !ok || v1 == 0 {
+		t.Fatalf("bad version: %v", v1)
+	}
+	l.Add(1, 2, initTime)
+	_, _, v2, _ := l.GetWithVersion(1)
+	if v2 <= v1 {
+		t.Fatalf("version should increase: %v <= %v", v2, v1)
+	}
+
+	if v, ok := l.CompareAndSwap(1, v1, 3); ok || v != v2 {
+		t.Fatalf("should not swap with a stale version: %v", v)
+	}
+	v3, ok := l.CompareAndSwap(1, v2, 3)
+	if !ok || v3 <= v2 {
+		t.Fatalf("should swap: %v, %v", v3, ok)
+	}
+	if v, expirationTime, _ := l.Peek(1); v != 3 || expirationTime != initTime {
+		t.Fatalf("bad value: %v, time: %v", v, expirationTime)
+	}
+	if _, ok := l.CompareAndSwap(2, 0, 2); ok {
+		t.Fatalf("2 should not be contained")
+	}
+
+	if l.CompareAndDelete(1, v2) {
+		t.Fatalf("should not delete with a stale version")
+	}
+	if !l.CompareAndDelete(1, v3) || l.Contains(1) {
+		t.Fatalf("1 should have been deleted")
+	}
+
+	// 删除后重新放入的条目版本号不会重复
+	l.Add(1, 1, initTime)
+	if _, _, v, _ := l.GetWithVersion(1); v <= v3 {
+		t.Fatalf("version should increase: %v <= %v", v, v3)
+	}
+}
+
+// Test Update, Compute and ComputeIfAbsent
+func TestLRU_Update(t *testing.T) {
+	initTime := initTime()
+
+	l, err := NewLRU(4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	incr := func(old interface{}, exists bool) (interface{}, bool) {
+		if !exists {
+			return 1, true
+		}
+		return old.(int) + 1, true
+	}
+
+	// 新增的键永不过期
+	if v, ok := l.Update(1, incr); !ok || v != 1 {
+		t.Fatalf("bad value: %v, %v", v, ok)
+	}
+	if _, expirationTime, _ := l.Peek(1); expirationTime != 0 {
+		t.Fatalf("1 should not expire: %v", expirationTime)
+	}
+
+	// 保留过期时间
+	l.Add(2, 1, initTime)
+	if v, ok := l.Update(2, incr); !ok || v != 2 {
+		t.Fatalf("bad value: %v, %v", v, ok)
+	}
+	if v, expirationTime, _ := l.Peek(2); v != 2 || expirationTime != initTime {
+		t.Fatalf("bad value: %v, time: %v", v, expirationTime)
+	}
+
+	// keep 为false时移除
+	if _, ok := l.Update(2, func(old interface{}, exists bool) (interface{}, bool) {
+		return nil, false
+	}); ok || l.Contains(2) {
+		t.Fatalf("2 should have been removed")
+	}
+
+	// Compute 设置过期时间
+	if v, ok := l.Compute(1, initTime, incr); !ok || v != 2 {
+		t.Fatalf("bad value: %v, %v", v, ok)
+	}
+	if _, expirationTime, _ := l.Peek(1); expirationTime != initTime {
+		t.Fatalf("bad time: %v", expirationTime)
+	}
+
+	calls := 0
+	load := func() (interface{}, bool) {
+		calls++
+		return 3, true
+	}
+	if v, ok := l.ComputeIfAbsent(3, initTime, load); !ok || v != 3 {
+		t.Fatalf("bad value: %v, %v", v, ok)
+	}
+	if v, ok := l.ComputeIfAbsent(3, initTime, load); !ok || v != 3 || calls != 1 {
+		t.Fatalf("should not load twice: %v, %v", v, calls)
+	}
+	if _, ok := l.ComputeIfAbsent(4, initTime, func() (interface{}, bool) {
+		return nil, false
+	}); ok || l.Contains(4) {
+		t.Fatalf("4 should not have been added")
+	}
+}
+
 // 生成当前时间
 func initTime() int64 {
 	return time.Now().UnixNano()/1e6 + 2000
@@ -0,0 +1,351 @@
+package mcache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+	"time"
+)
+
+// Snapshot format, all integers are varints unless noted:
+//
+//	magic "MCSNAP", format version byte
+//	kind string, codec name string, p (ARC target size)
+//	records: flags byte (0 ends the records), list byte, key, value if
+//	flagValue, ExpirationTime, CreationTime, AccessTime, AccessCount, Cost,
+//	Lifetime, Sliding byte, Version, recompute cost (float64 bits, GDSF)
+//	CRC-32 (IEEE) of everything before, 4 bytes big endian
+//
+// Strings and byte slices are prefixed with their length. Keys are always
+// encoded with gob to keep their types, values with the codec.
+// 快照格式, 除特别说明外整数均为 varint:
+// 魔数 "MCSNAP" 及格式版本; 缓存类型, 编码名称, p(ARC 的目标大小);
+// 记录: 标志(为 0 时结束), 列表, 键, 值(有值时), 过期时间等元数据;
+// 最后是之前所有内容的 CRC-32, 4 字节大端。字符串和字节切片带长度前缀, 键总是使用 gob 编码以保留类型, 值使用给定的编码
+const (
+	snapshotMagic   = "MCSNAP"
+	snapshotVersion = 1
+)
+
+const (
+	// 记录标志
+	flagEntry = 1 << iota
+	flagValue
+)
+
+// 快照中的列表, 依次为 LRU/LFU/GDSF 或 ARC 的 T1, 2Q 的 recent; ARC 的 T2, 2Q 的 frequent;
+// ARC 的 B1, 2Q 的 recentEvict; ARC 的 B2
+const (
+	listMain byte = iota
+	listSecond
+	listGhost
+	listGhostSecond
+)
+
+// ErrSnapshotCorrupt is returned when a snapshot fails its checksum or
+// cannot be parsed.
+// ErrSnapshotCorrupt 快照校验失败或无法解析
+var ErrSnapshotCorrupt = errors.New("mcache: corrupt snapshot")
+
+// snapshotRecord is an entry of a snapshot.
+// snapshotRecord 快照中的一个条目
+type snapshotRecord struct {
+	list  byte
+	entry Entry
+	cost  float64
+}
+
+// snapshot is a parsed snapshot.
+// snapshot 解析后的快照
+type snapshot struct {
+	p       int
+	records []snapshotRecord
+}
+
+// live reports whether a record has not expired yet.
+// live 判断记录是否未过期
+func (r snapshotRecord) live(now int64) bool {
+	return r.entry.ExpirationTime == 0 || r.entry.ExpirationTime > now
+}
+
+// writeSnapshot writes the records of a cache of the given kind.
+// writeSnapshot 写入给定类型缓存的快照
+func writeSnapshot(w io.Writer, kind string, codec Codec, s snapshot) error {
+	if codec == nil {
+		codec = GobCodec{}
+	}
+	crc := crc32.NewIEEE()
+	bw := bufio.NewWriter(io.MultiWriter(w, crc))
+	sw := &snapshotWriter{w: bw}
+
+	sw.raw([]byte(snapshotMagic))
+	sw.raw([]byte{snapshotVersion})
+	sw.bytes([]byte(kind))
+	sw.bytes([]byte(codec.Name()))
+	sw.varint(int64(s.p))
+	for _, r := range s.records {
+		if err := sw.record(r, codec); err != nil {
+			return err
+		}
+	}
+	sw.raw([]byte{0})
+	if sw.err != nil {
+		return sw.err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	_, err := w.Write(sum[:])
+	return err
+}
+
+// readSnapshot reads and verifies a snapshot of a cache of the given kind.
+// A nil codec uses the registered one named in the snapshot.
+// readSnapshot 读取并校验给定类型缓存的快照。codec 为 nil 时使用快照中记录的已注册编码
+func readSnapshot(r io.Reader, kind string, codec Codec) (s snapshot, err error) {
+	crc := crc32.NewIEEE()
+	br := bufio.NewReader(r)
+	sr := &snapshotReader{r: br, crc: crc}
+
+	if string(sr.raw(len(snapshotMagic))) != snapshotMagic {
+		return s, ErrSnapshotCorrupt
+	}
+	if v := sr.raw(1); sr.err == nil && v[0] != snapshotVersion {
+		return s, fmt.Errorf("mcache: unsupported snapshot version %d", v[0])
+	}
+	if k := string(sr.bytes()); sr.err == nil && k != kind {
+		return s, fmt.Errorf("mcache: snapshot of a %s cache, not %s", k, kind)
+	}
+	name := string(sr.bytes())
+	if sr.err != nil {
+		return s, ErrSnapshotCorrupt
+	}
+	if codec, err = codecFor(name, codec); err != nil {
+		return s, err
+	}
+	s.p = int(sr.varint())
+
+	// 解码错误在校验通过后才返回, 损坏的快照总是返回 ErrSnapshotCorrupt
+	var decodeErr error
+	for sr.err == nil {
+		flags := sr.raw(1)[0]
+		if sr.err != nil || flags == 0 {
+			break
+		}
+		rec, err := sr.record(flags, codec)
+		if err != nil {
+			if decodeErr == nil {
+				decodeErr = err
+			}
+			continue
+		}
+		s.records = append(s.records, rec)
+	}
+	if sr.err != nil {
+		return s, ErrSnapshotCorrupt
+	}
+
+	// 校验和不计入自身
+	sum := crc.Sum32()
+	var trailer [4]byte
+	if _, err := io.ReadFull(br, trailer[:]); err != nil || binary.BigEndian.Uint32(trailer[:]) != sum {
+		return s, ErrSnapshotCorrupt
+	}
+	if decodeErr != nil {
+		return snapshot{}, decodeErr
+	}
+	return s, nil
+}
+
+// snapshotWriter writes the fields of a snapshot, keeping the first error.
+// snapshotWriter 写入快照的字段, 保留第一个错误
+type snapshotWriter struct {
+	w   io.Writer
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func (sw *snapshotWriter) raw(p []byte) {
+	if sw.err == nil {
+		_, sw.err = sw.w.Write(p)
+	}
+}
+
+func (sw *snapshotWriter) varint(v int64) {
+	sw.raw(sw.buf[:binary.PutVarint(sw.buf[:], v)])
+}
+
+func (sw *snapshotWriter) uvarint(v uint64) {
+	sw.raw(sw.buf[:binary.PutUvarint(sw.buf[:], v)])
+}
+
+func (sw *snapshotWriter) bytes(p []byte) {
+	sw.uvarint(uint64(len(p)))
+	sw.raw(p)
+}
+
+// record writes a record, starting with its flags.
+// record 写入一条记录, 以其标志开头
+func (sw *snapshotWriter) record(r snapshotRecord, codec Codec) error {
+	flags := byte(flagEntry)
+	if r.entry.Value != nil {
+		flags |= flagValue
+	}
+	sw.raw([]byte{flags, r.list})
+	key, err := GobCodec{}.Marshal(r.entry.Key)
+	if err != nil {
+		return fmt.Errorf("mcache: encode key %v: %v", r.entry.Key, err)
+	}
+	sw.bytes(key)
+	if r.entry.Value != nil {
+		value, err := codec.Marshal(r.entry.Value)
+		if err != nil {
+			return fmt.Errorf("mcache: encode value of %v: %v", r.entry.Key, err)
+		}
+		sw.bytes(value)
+	}
+	e := r.entry
+	sw.varint(e.ExpirationTime)
+	sw.varint(e.CreationTime)
+	sw.varint(e.AccessTime)
+	sw.varint(e.AccessCount)
+	sw.varint(e.Cost)
+	sw.varint(int64(e.Lifetime))
+	sliding := byte(0)
+	if e.Sliding {
+		sliding = 1
+	}
+	sw.raw([]byte{sliding})
+	sw.uvarint(e.Version)
+	sw.uvarint(math.Float64bits(r.cost))
+	return sw.err
+}
+
+// snapshotReader reads the fields of a snapshot, keeping the first error
+// and feeding the bytes read to the checksum.
+// snapshotReader 读取快照的字段, 保留第一个错误, 并将读取的字节计入校验和
+type snapshotReader struct {
+	r   *bufio.Reader
+	crc hash.Hash32
+	err error
+}
+
+// maxSnapshotField bounds the length of a field.
+// maxSnapshotField 字段长度上限
+const maxSnapshotField = 1 << 30
+
+// snapshotChunk is the size of the chunks long fields are read by.
+// snapshotChunk 读取较长字段时每块的大小
+const snapshotChunk = 64 << 10
+
+// readChunks reads n bytes by chunks, so that a corrupt length fails at the
+// end of the input instead of allocating n bytes before the checksum is
+// checked. The result is never shorter than n bytes or the first chunk.
+// readChunks 按块读取 n 个字节, 长度损坏时在输入结束处失败, 而不是在校验之前分配 n 个字节。
+// 返回的切片长度不小于 n 或第一块的大小
+func readChunks(r io.Reader, n int) (p []byte, err error) {
+	for len(p) < n && err == nil {
+		m := n - len(p)
+		if m > snapshotChunk {
+			m = snapshotChunk
+		}
+		p = append(p, make([]byte, m)...)
+		_, err = io.ReadFull(r, p[len(p)-m:])
+	}
+	return p, err
+}
+
+func (sr *snapshotReader) raw(n int) []byte {
+	if sr.err != nil {
+		return make([]byte, n)
+	}
+	p, err := readChunks(sr.r, n)
+	if sr.err = err; sr.err == nil {
+		sr.crc.Write(p)
+	}
+	return p
+}
+
+func (sr *snapshotReader) varint() int64 {
+	if sr.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(&crcByteReader{sr})
+	if err != nil {
+		sr.err = err
+	}
+	return v
+}
+
+func (sr *snapshotReader) uvarint() uint64 {
+	if sr.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(&crcByteReader{sr})
+	if err != nil {
+		sr.err = err
+	}
+	return v
+}
+
+func (sr *snapshotReader) bytes() []byte {
+	n := sr.uvarint()
+	if n > maxSnapshotField {
+		sr.err = ErrSnapshotCorrupt
+	}
+	if sr.err != nil {
+		return nil
+	}
+	return sr.raw(int(n))
+}
+
+// record reads a record whose flags were read. A key or value that fails
+// to decode is still read to the end of the record, so that the caller can
+// verify the checksum before reporting the decode error.
+// record 读取一条记录, 其标志已被读取。键或值解码失败时仍读取完整条记录, 调用方可以先校验再返回解码错误
+func (sr *snapshotReader) record(flags byte, codec Codec) (rec snapshotRecord, err error) {
+	rec.list = sr.raw(1)[0]
+	key, kerr := (GobCodec{}).Unmarshal(sr.bytes())
+	if kerr != nil && sr.err == nil {
+		err = fmt.Errorf("mcache: decode key: %v", kerr)
+	}
+	rec.entry.Key = key
+	if flags&flagValue != 0 {
+		value, verr := codec.Unmarshal(sr.bytes())
+		if verr != nil && sr.err == nil && err == nil {
+			err = fmt.Errorf("mcache: decode value of %v: %v", rec.entry.Key, verr)
+		}
+		rec.entry.Value = value
+	}
+	rec.entry.ExpirationTime = sr.varint()
+	rec.entry.CreationTime = sr.varint()
+	rec.entry.AccessTime = sr.varint()
+	rec.entry.AccessCount = sr.varint()
+	rec.entry.Cost = sr.varint()
+	rec.entry.Lifetime = time.Duration(sr.varint())
+	rec.entry.Sliding = sr.raw(1)[0] == 1
+	rec.entry.Version = sr.uvarint()
+	rec.cost = math.Float64frombits(sr.uvarint())
+	return rec, err
+}
+
+// crcByteReader reads single bytes feeding them to the checksum.
+// crcByteReader 逐字节读取并计入校验和
+type crcByteReader struct {
+	sr *snapshotReader
+}
+
+func (b *crcByteReader) ReadByte() (byte, error) {
+	c, err := b.sr.r.ReadByte()
+	if err == nil {
+		b.sr.crc.Write([]byte{c})
+	}
+	return c, err
+}
@@ -0,0 +1,246 @@
+package mcache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+type snapshotValue struct {
+	Name  string
+	Count int
+}
+
+func init() {
+	gob.Register(snapshotValue{})
+}
+
+func TestSnapshot_LRU(t *testing.T) {
+	l, _ := NewLRU(4)
+	exp := time.Now().Add(time.Hour).UnixNano() / 1e6
+	l.Add("a", 1, 0)
+	l.Add("b", snapshotValue{"b", 2}, exp)
+	l.Add("c", "c", time.Now().UnixNano()/1e6+20)
+	l.Add("d", nil, 0)
+	l.Get("a")
+
+	var buf bytes.Buffer
+	if err := l.SaveTo(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	r, _ := NewLRU(4)
+	if err := r.LoadFrom(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	// 过期的 c 被跳过, 保留最近使用顺序
+	if keys := r.Keys(); !reflect.DeepEqual(keys, []interface{}{"b", "d", "a"}) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+	if v, e, ok := r.Peek("b"); !ok || v != (snapshotValue{"b", 2}) || e != exp {
+		t.Fatalf("bad: %v %v %v", v, e, ok)
+	}
+	if v, ok := r.PeekEntry("a"); !ok || v.AccessCount != 1 {
+		t.Fatalf("bad: %+v", v)
+	}
+
+	// 容量较小时保留最近使用的条目
+	small, _ := NewLRU(1)
+	small.LoadFrom(bytes.NewReader(buf.Bytes()))
+	if keys := small.Keys(); !reflect.DeepEqual(keys, []interface{}{"a"}) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+}
+
+func TestSnapshot_LFU(t *testing.T) {
+	l, _ := NewLFU(4)
+	l.Add(1, 1, 0)
+	l.Add(2, 2, 0)
+	for i := 0; i < 3; i++ {
+		l.Get(1)
+	}
+
+	var buf bytes.Buffer
+	l.SaveTo(&buf)
+	r, _ := NewLFU(4)
+	if err := r.LoadFrom(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	e, _ := r.PeekEntry(1)
+	if e.AccessCount != 4 {
+		t.Fatalf("bad weight: %v", e.AccessCount)
+	}
+	r.Resize(1)
+	if !r.Contains(1) {
+		t.Fatalf("1 should be kept")
+	}
+}
+
+func TestSnapshot_GDSF(t *testing.T) {
+	l, _ := NewGDSF(10)
+	l.AddWithSizeCost(1, 1, 2, 10, 0)
+	l.AddWithSizeCost(2, 2, 2, 1, 0)
+
+	var buf bytes.Buffer
+	l.SaveTo(&buf)
+	r, _ := NewGDSF(10)
+	if err := r.LoadFrom(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if keys := r.Keys(); !reflect.DeepEqual(keys, []interface{}{2, 1}) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+}
+
+func TestSnapshot_ARC(t *testing.T) {
+	l, _ := NewARC(2)
+	l.Add(1, 1, 0)
+	l.Add(2, 2, 0)
+	l.Get(1)
+	l.Add(3, 3, 0)
+	l.Add(2, 2, 0) // 命中 B1, 调整 p
+
+	var buf bytes.Buffer
+	l.SaveTo(&buf)
+	r, _ := NewARC(2)
+	if err := r.LoadFrom(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if r.p != l.p || r.t1.Len() != l.t1.Len() || r.t2.Len() != l.t2.Len() || r.b1.Len() != l.b1.Len() || r.b2.Len() != l.b2.Len() {
+		t.Fatalf("bad: %v %v", r.p, l.p)
+	}
+	if !reflect.DeepEqual(r.Keys(), l.Keys()) {
+		t.Fatalf("bad keys: %v %v", r.Keys(), l.Keys())
+	}
+}
+
+func TestSnapshot_2Q(t *testing.T) {
+	l, _ := New2Q(4)
+	for i := 0; i < 8; i++ {
+		l.Add(i, i, 0)
+	}
+	l.Get(6)
+
+	var buf bytes.Buffer
+	l.SaveTo(&buf)
+	r, _ := New2Q(4)
+	if err := r.LoadFrom(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(r.Keys(), l.Keys()) || r.recentEvict.Len() != l.recentEvict.Len() {
+		t.Fatalf("bad keys: %v %v", r.Keys(), l.Keys())
+	}
+}
+
+func TestSnapshot_LoadPopulated(t *testing.T) {
+	a, _ := NewARC(4)
+	q, _ := New2Q(4)
+	for i := 0; i < 4; i++ {
+		a.Add(i, i, 0)
+		a.Add(i, i, 0)
+		q.Add(i, i, 0)
+		q.Add(i, i, 0)
+	}
+	var arcBuf, qBuf bytes.Buffer
+	a.SaveTo(&arcBuf)
+	q.SaveTo(&qBuf)
+
+	// 加载到已有条目的缓存中, 总数不超过容量
+	ra, _ := NewARC(4)
+	rq, _ := New2Q(4)
+	for i := 10; i < 14; i++ {
+		ra.Add(i, i, 0)
+		rq.Add(i, i, 0)
+	}
+	if err := ra.LoadFrom(&arcBuf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := rq.LoadFrom(&qBuf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if ra.Len() > 4 || rq.Len() > 4 {
+		t.Fatalf("bad len: %v %v", ra.Len(), rq.Len())
+	}
+	for i := 0; i < 4; i++ {
+		if !ra.Contains(i) {
+			t.Fatalf("%d not loaded", i)
+		}
+	}
+}
+
+func TestSnapshot_Hashed(t *testing.T) {
+	l, _ := NewHashLRU(64, 4)
+	for i := 0; i < 32; i++ {
+		l.Add(i, i, 0)
+	}
+
+	var buf bytes.Buffer
+	l.SaveTo(&buf)
+	r, _ := NewHashLRU(64, 4)
+	if err := r.LoadFrom(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(r.Keys(), l.Keys()) {
+		t.Fatalf("bad keys: %v %v", r.Keys(), l.Keys())
+	}
+}
+
+func TestSnapshot_Corrupt(t *testing.T) {
+	l, _ := NewLRU(4)
+	l.Add(1, "value", 0)
+	var buf bytes.Buffer
+	l.SaveTo(&buf)
+	data := buf.Bytes()
+
+	// 头部之后的损坏, 包括键和值无法解码, 都在校验时发现
+	var empty bytes.Buffer
+	r, _ := NewLRU(4)
+	r.SaveTo(&empty)
+	header := empty.Len() - 6
+	for i := range data {
+		corrupt := append([]byte(nil), data...)
+		corrupt[i] ^= 0xff
+		err := r.LoadFrom(bytes.NewReader(corrupt))
+		if err == nil || (i >= header && err != ErrSnapshotCorrupt) {
+			t.Fatalf("corruption at %d not detected: %v", i, err)
+		}
+	}
+	if err := r.LoadFrom(bytes.NewReader(data[:len(data)-1])); err == nil {
+		t.Fatalf("truncation not detected")
+	}
+	if r.Len() != 0 {
+		t.Fatalf("bad len: %v", r.Len())
+	}
+
+	f, _ := NewLFU(4)
+	if err := f.LoadFrom(bytes.NewReader(data)); err == nil {
+		t.Fatalf("kind mismatch not detected")
+	}
+}
+
+func TestSnapshot_CorruptLength(t *testing.T) {
+	l, _ := NewLRU(4)
+	var buf bytes.Buffer
+	l.SaveTo(&buf)
+	// 去掉结束标志和校验和, 写入一条键长度损坏的记录
+	data := append([]byte(nil), buf.Bytes()[:buf.Len()-5]...)
+	data = append(data, flagEntry|flagValue, listMain)
+	var length [binary.MaxVarintLen64]byte
+	data = append(data, length[:binary.PutUvarint(length[:], 1<<29)]...)
+	data = append(data, 1, 2, 3)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if err := l.LoadFrom(bytes.NewReader(data)); err != ErrSnapshotCorrupt {
+		t.Fatalf("err: %v", err)
+	}
+	runtime.ReadMemStats(&after)
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Fatalf("allocated %d bytes", n)
+	}
+}
@@ -0,0 +1,220 @@
+package mcache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a snapshot of the counters of a cache.
+// Stats 缓存统计计数的快照
+type Stats struct {
+	// Hits 命中次数, 统计 Get, GetEntry, GetWithVersion
+	Hits uint64
+	// Misses 未命中次数
+	Misses uint64
+	// Adds 写入的值的数量, 包括任意写操作及 LoadFrom 写入的值, 包含替换已有值的写入
+	Adds uint64
+	// Updates 任意写操作替换已有值的次数
+	Updates uint64
+	// Evictions 按原因统计的淘汰次数, 不包含替换和过期
+	Evictions map[EvictionReason]uint64
+	// Expirations 因过期被删除的条目数量
+	Expirations uint64
+	// Loads 通过 RecordLoad 记录的加载次数
+	Loads uint64
+	// LoadErrors 失败的加载次数
+	LoadErrors uint64
+	// LoadTime 加载的总耗时
+	LoadTime time.Duration
+	// HotKeys 通过 SetHotKeys 关联的追踪器统计的热点键, 未关联时为 nil
+	HotKeys []HotKey
+}
+
+// HitRatio returns the ratio of hits to lookups, 0 without lookups.
+// HitRatio 返回命中次数占查询次数的比例, 没有查询时返回 0
+func (s Stats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// EvictionCount returns the number of evictions for all reasons.
+// EvictionCount 返回所有原因的淘汰次数之和
+func (s Stats) EvictionCount() uint64 {
+	var n uint64
+	for _, v := range s.Evictions {
+		n += v
+	}
+	return n
+}
+
+// AverageLoadTime returns the average duration of a load.
+// AverageLoadTime 返回加载的平均耗时
+func (s Stats) AverageLoadTime() time.Duration {
+	if s.Loads == 0 {
+		return 0
+	}
+	return s.LoadTime / time.Duration(s.Loads)
+}
+
+// add adds the counters of o to s.
+// add 将 o 的计数累加到 s
+func (s *Stats) add(o Stats) {
+	s.Hits += o.Hits
+	s.Misses += o.Misses
+	s.Adds += o.Adds
+	s.Updates += o.Updates
+	if s.Evictions == nil {
+		s.Evictions = make(map[EvictionReason]uint64)
+	}
+	for r, v := range o.Evictions {
+		s.Evictions[r] += v
+	}
+	s.Expirations += o.Expirations
+	s.Loads += o.Loads
+	s.LoadErrors += o.LoadErrors
+	s.LoadTime += o.LoadTime
+	if s.HotKeys == nil {
+		// 哈希缓存的各分片共用同一个追踪器
+		s.HotKeys = o.HotKeys
+	}
+}
+
+// statsCounter holds the counters of a cache or of a shard of a hashed
+// cache, updated with atomics so reading them does not take the cache lock.
+// statsCounter 缓存或哈希缓存分片的统计计数, 使用原子操作更新, 读取时不需要缓存锁
+type statsCounter struct {
+	hits       uint64
+	misses     uint64
+	adds       uint64
+	updates    uint64
+	loads      uint64
+	loadErrors uint64
+	loadTime   uint64
+	locks      uint64
+	lockWait   uint64
+	evictions  [ReasonResized + 1]uint64
+	hotKeys    atomic.Value
+	// shard 哈希缓存分片的序号, 选择记录热点键的追踪器
+	shard int
+}
+
+// get counts a lookup.
+// get 统计一次查询
+func (s *statsCounter) get(key interface{}, ok bool) {
+	if t := s.hot(); t != nil {
+		t.record(s.shard, key)
+	}
+	if ok {
+		atomic.AddUint64(&s.hits, 1)
+	} else {
+		atomic.AddUint64(&s.misses, 1)
+	}
+}
+
+// add counts a value stored by any write.
+// add 统计一次写入
+func (s *statsCounter) add(key interface{}) {
+	if t := s.hot(); t != nil {
+		t.record(s.shard, key)
+	}
+	atomic.AddUint64(&s.adds, 1)
+}
+
+// setHot sets the hot key tracker, nil removes it.
+// setHot 设置热点键追踪器, 为 nil 时移除
+func (s *statsCounter) setHot(t *HotKeys) {
+	s.hotKeys.Store(t)
+}
+
+// hot returns the hot key tracker, nil if there is none.
+// hot 返回热点键追踪器, 没有时返回 nil
+func (s *statsCounter) hot() *HotKeys {
+	t, _ := s.hotKeys.Load().(*HotKeys)
+	return t
+}
+
+// load counts a load of the key.
+// load 统计一次键的加载
+func (s *statsCounter) load(key interface{}, d time.Duration, err error) {
+	if t := s.hot(); t != nil {
+		t.record(s.shard, key)
+	}
+	atomic.AddUint64(&s.loads, 1)
+	if err != nil {
+		atomic.AddUint64(&s.loadErrors, 1)
+	}
+	if d > 0 {
+		atomic.AddUint64(&s.loadTime, uint64(d))
+	}
+}
+
+// waited records the time spent waiting for a lock.
+// waited 记录一次等待锁的时间
+func (s *statsCounter) waited(d time.Duration) {
+	atomic.AddUint64(&s.locks, 1)
+	if d > 0 {
+		atomic.AddUint64(&s.lockWait, uint64(d))
+	}
+}
+
+// evicted wraps an eviction callback so that it counts the evictions by
+// reason. The returned callback is never nil.
+// evicted 包装淘汰回调, 按原因统计淘汰次数。返回的回调不为 nil
+func (s *statsCounter) evicted(onEvicted func(key interface{}, value interface{}, expirationTime int64, reason EvictionReason)) func(key interface{}, value interface{}, expirationTime int64, reason EvictionReason) {
+	return func(key interface{}, value interface{}, expirationTime int64, reason EvictionReason) {
+		if reason == ReasonReplaced {
+			atomic.AddUint64(&s.updates, 1)
+		} else if int(reason) < len(s.evictions) {
+			atomic.AddUint64(&s.evictions[reason], 1)
+		}
+		if onEvicted != nil {
+			onEvicted(key, value, expirationTime, reason)
+		}
+	}
+}
+
+// snapshot returns the current counters.
+// snapshot 返回当前的计数
+func (s *statsCounter) snapshot() Stats {
+	st := Stats{
+		Hits:        atomic.LoadUint64(&s.hits),
+		Misses:      atomic.LoadUint64(&s.misses),
+		Adds:        atomic.LoadUint64(&s.adds),
+		Updates:     atomic.LoadUint64(&s.updates),
+		Evictions:   make(map[EvictionReason]uint64),
+		Expirations: atomic.LoadUint64(&s.evictions[ReasonExpired]),
+		Loads:       atomic.LoadUint64(&s.loads),
+		LoadErrors:  atomic.LoadUint64(&s.loadErrors),
+		LoadTime:    time.Duration(atomic.LoadUint64(&s.loadTime)),
+	}
+	for r := range s.evictions {
+		reason := EvictionReason(r)
+		if reason == ReasonReplaced || reason == ReasonExpired {
+			continue
+		}
+		st.Evictions[reason] = atomic.LoadUint64(&s.evictions[r])
+	}
+	if t := s.hot(); t != nil {
+		st.HotKeys = t.Top()
+	}
+	return st
+}
+
+// reset sets all counters to zero.
+// reset 将所有计数清零
+func (s *statsCounter) reset() {
+	atomic.StoreUint64(&s.hits, 0)
+	atomic.StoreUint64(&s.misses, 0)
+	atomic.StoreUint64(&s.adds, 0)
+	atomic.StoreUint64(&s.updates, 0)
+	atomic.StoreUint64(&s.loads, 0)
+	atomic.StoreUint64(&s.loadErrors, 0)
+	atomic.StoreUint64(&s.loadTime, 0)
+	atomic.StoreUint64(&s.locks, 0)
+	atomic.StoreUint64(&s.lockWait, 0)
+	for r := range s.evictions {
+		atomic.StoreUint64(&s.evictions[r], 0)
+	}
+}
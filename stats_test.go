@@ -0,0 +1,174 @@
+package mcache
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	l, err := NewLRU(2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1, 0)
+	l.Add(1, 2, 0)
+	l.Add(2, 2, 0)
+	l.Add(3, 3, 0)
+	l.Get(3)
+	l.Get(1)
+	l.Peek(3)
+	l.Remove(3)
+
+	// 过期的条目在 Get 时删除
+	l.Add(4, 4, time.Now().UnixNano()/1e6-1)
+	l.Get(4)
+
+	l.RecordLoad(4, 10*time.Millisecond, nil)
+	l.RecordLoad(4, 30*time.Millisecond, errors.New("failed"))
+
+	s := l.Stats()
+	if s.Hits != 1 || s.Misses != 2 || s.HitRatio() != 1.0/3 {
+		t.Fatalf("bad: %+v", s)
+	}
+	if s.Adds != 5 || s.Updates != 1 {
+		t.Fatalf("bad: %+v", s)
+	}
+	if s.Evictions[ReasonCapacity] != 1 || s.Evictions[ReasonRemoved] != 1 || s.Expirations != 1 {
+		t.Fatalf("bad: %+v", s)
+	}
+	if s.Loads != 2 || s.LoadErrors != 1 || s.AverageLoadTime() != 20*time.Millisecond {
+		t.Fatalf("bad: %+v", s)
+	}
+
+	l.ResetStats()
+	s = l.Stats()
+	if s.Hits != 0 || s.Adds != 0 || s.EvictionCount() != 0 || s.Loads != 0 {
+		t.Fatalf("bad: %+v", s)
+	}
+}
+
+func TestStats_Hashed(t *testing.T) {
+	var evicted int
+	l, err := NewHashLruWithEvict(64, 4, func(key interface{}, value interface{}, expirationTime int64) {
+		evicted++
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		l.Add(i, i, 0)
+	}
+	for i := 0; i < 100; i++ {
+		l.Get(i)
+	}
+
+	s := l.Stats()
+	if s.Adds != 100 || s.Hits+s.Misses != 100 || s.Hits != uint64(l.Len()) {
+		t.Fatalf("bad: %+v", s)
+	}
+	if s.Evictions[ReasonCapacity] != uint64(evicted) || evicted != 100-l.Len() {
+		t.Fatalf("bad: %+v %v", s, evicted)
+	}
+}
+
+func TestStats_ARC(t *testing.T) {
+	l, err := NewARC(2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1, 0)
+	l.Get(1) // 移动到 t2 不计为淘汰
+	l.Add(1, 2, 0)
+	l.Add(2, 2, 0)
+	l.Add(3, 3, 0)
+	l.Get(4)
+
+	s := l.Stats()
+	if s.Hits != 1 || s.Misses != 1 || s.Adds != 4 || s.Updates != 1 {
+		t.Fatalf("bad: %+v", s)
+	}
+	if s.EvictionCount() != 1 || s.Evictions[ReasonCapacity] != 1 {
+		t.Fatalf("bad: %+v", s)
+	}
+}
+
+func TestStats_Writes(t *testing.T) {
+	type cache interface {
+		Update(key interface{}, fn func(old interface{}, exists bool) (value interface{}, keep bool)) (value interface{}, ok bool)
+		Compute(key interface{}, expirationTime int64, fn func(old interface{}, exists bool) (value interface{}, keep bool)) (value interface{}, ok bool)
+		ComputeIfAbsent(key interface{}, expirationTime int64, fn func() (value interface{}, keep bool)) (value interface{}, ok bool)
+		Incr(key interface{}, delta int64, expirationTime int64) (value int64, err error)
+		GetWithVersion(key interface{}) (value interface{}, expirationTime int64, version uint64, ok bool)
+		CompareAndSwap(key interface{}, expectedVersion uint64, value interface{}) (version uint64, swapped bool)
+		SaveTo(w io.Writer) error
+		LoadFrom(r io.Reader) error
+		SetHotKeys(t *HotKeys)
+		RecordLoad(key interface{}, loadTime time.Duration, err error)
+		Stats() Stats
+	}
+	caches := map[string]func() cache{
+		"lru":     func() cache { c, _ := NewLRU(8); return c },
+		"lfu":     func() cache { c, _ := NewLFU(8); return c },
+		"gdsf":    func() cache { c, _ := NewGDSF(8); return c },
+		"hashlru": func() cache { c, _ := NewHashLRU(8, 2); return c },
+		"hashlfu": func() cache { c, _ := NewHashLFU(8, 2); return c },
+		"arc":     func() cache { c, _ := NewARC(8); return c },
+		"2q":      func() cache { c, _ := New2Q(8); return c },
+	}
+	for name, newCache := range caches {
+		c := newCache()
+		keep := func(interface{}, bool) (interface{}, bool) { return 1, true }
+		c.Update("update", keep)
+		c.Update("update", keep)
+		c.Compute("compute", 0, keep)
+		c.ComputeIfAbsent("absent", 0, func() (interface{}, bool) { return 1, true })
+		c.ComputeIfAbsent("absent", 0, func() (interface{}, bool) { return 2, true })
+		c.Incr("counter", 1, 0)
+		c.Incr("counter", 1, 0)
+		_, _, version, _ := c.GetWithVersion("compute")
+		c.CompareAndSwap("compute", version, 2)
+		c.CompareAndSwap("compute", version, 3)
+		if s := c.Stats(); s.Adds != 7 || s.Updates != 3 {
+			t.Fatalf("%s: bad: %+v", name, s)
+		}
+
+		// LoadFrom 载入的条目计为写入
+		var buf bytes.Buffer
+		if err := c.SaveTo(&buf); err != nil {
+			t.Fatalf("%s: err: %v", name, err)
+		}
+		r := newCache()
+		if err := r.LoadFrom(&buf); err != nil {
+			t.Fatalf("%s: err: %v", name, err)
+		}
+		if s := r.Stats(); s.Adds != 4 {
+			t.Fatalf("%s: bad: %+v", name, s)
+		}
+
+		// 加载的键计入热点键
+		h, _ := NewHotKeys(1, 0)
+		r.SetHotKeys(h)
+		r.RecordLoad("loaded", time.Millisecond, nil)
+		if s := r.Stats(); len(s.HotKeys) != 1 || s.HotKeys[0].Key != "loaded" {
+			t.Fatalf("%s: bad: %+v", name, s.HotKeys)
+		}
+	}
+}
+
+func TestStats_Resize(t *testing.T) {
+	l, _ := NewHashLRU(8, 2)
+	for i := 0; i < 8; i++ {
+		l.Add(i, i, 0)
+	}
+	n := l.Len()
+	// 返回所有分片淘汰的数量
+	if evicted := l.Resize(2); evicted != n-l.Len() || evicted == 0 {
+		t.Fatalf("bad: %v", evicted)
+	}
+}
@@ -0,0 +1,312 @@
+package mcache
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// TieredConfig holds the parameters of the disk tier of a TieredCache.
+// TieredConfig TieredCache 磁盘层的配置参数
+type TieredConfig struct {
+	// Dir 磁盘层分段文件所在的目录
+	Dir string
+	// Capacity 磁盘层的容量(字节), 必须大于0
+	Capacity int64
+	// SegmentSize 每个分段文件的大小(字节), 默认为 Capacity 的 1/4。达到容量时整段删除最旧的分段
+	SegmentSize int64
+	// Codec 值的编码, 默认为 gob
+	Codec Codec
+}
+
+// tieredL1 is the memory tier of a TieredCache, an LruCache or a LfuCache.
+// tieredL1 TieredCache 的内存层, 即 LruCache 或 LfuCache
+type tieredL1 interface {
+	Add(key, value interface{}, expirationTime int64) (evicted bool)
+	Get(key interface{}) (value interface{}, expirationTime int64, ok bool)
+	Peek(key interface{}) (value interface{}, expirationTime int64, ok bool)
+	Contains(key interface{}) bool
+	Remove(key interface{}) (present bool)
+	Purge()
+	Len() int
+}
+
+// TieredCache is a two tier cache: entries evicted from the memory tier
+// (L1) for lack of capacity are moved to a log-structured disk tier (L2),
+// and moved back to L1 when hit. Both tiers honor the expiration times.
+// The disk tier does not survive the cache, its segments are deleted when
+// it is created and closed.
+// TieredCache 两级缓存: 内存层(L1)因容量不足淘汰的条目移到日志结构的磁盘层(L2), 命中时再移回 L1。
+// 两层都遵循过期时间。磁盘层不会持久保留, 创建及关闭缓存时都会删除其分段文件
+type TieredCache struct {
+	l1   tieredL1
+	l2   *diskTier
+	keys *KeyLocker
+
+	// demoted 内存层已淘汰, 尚未写入磁盘层的条目。淘汰回调持有内存层的锁, 因此在释放后才写入磁盘
+	demoted     map[interface{}]tieredEntry
+	demotedLock sync.Mutex
+}
+
+// tieredEntry is an entry waiting to be written to the disk tier.
+// tieredEntry 等待写入磁盘层的条目
+type tieredEntry struct {
+	value          interface{}
+	expirationTime int64
+}
+
+// NewTieredLRU creates a TieredCache whose memory tier is an LRU of the
+// given size.
+// NewTieredLRU 构造一个内存层为给定大小的LRU的两级缓存
+func NewTieredLRU(size int, config TieredConfig) (*TieredCache, error) {
+	t, err := newTiered(config)
+	if err != nil {
+		return nil, err
+	}
+	if t.l1, err = NewLruWithEvictReason(size, t.demote); err != nil {
+		t.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// NewTieredLFU creates a TieredCache whose memory tier is an LFU of the
+// given size.
+// NewTieredLFU 构造一个内存层为给定大小的LFU的两级缓存
+func NewTieredLFU(size int, config TieredConfig) (*TieredCache, error) {
+	t, err := newTiered(config)
+	if err != nil {
+		return nil, err
+	}
+	if t.l1, err = NewLfuWithEvictReason(size, t.demote); err != nil {
+		t.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// newTiered creates a TieredCache without its memory tier.
+// newTiered 构造一个尚无内存层的两级缓存
+func newTiered(config TieredConfig) (*TieredCache, error) {
+	if config.Dir == "" {
+		return nil, errors.New("must provide a directory")
+	}
+	if config.Capacity <= 0 {
+		return nil, errors.New("must provide a positive capacity")
+	}
+	if config.SegmentSize <= 0 || config.SegmentSize > config.Capacity {
+		config.SegmentSize = (config.Capacity + 3) / 4
+	}
+	if config.Codec == nil {
+		config.Codec = GobCodec{}
+	}
+	l2, err := newDiskTier(config.Dir, config.Capacity, config.SegmentSize, config.Codec)
+	if err != nil {
+		return nil, err
+	}
+	t := &TieredCache{
+		l2:      l2,
+		keys:    NewKeyLocker(runtime.NumCPU()),
+		demoted: make(map[interface{}]tieredEntry),
+	}
+	return t, nil
+}
+
+// Add adds a value to the memory tier, replacing the one of the disk tier.
+// Add 向内存层添加一个值, 并替换磁盘层中的值
+func (t *TieredCache) Add(key, value interface{}, expirationTime int64) {
+	t.keys.Lock(key)
+	// 先移除磁盘层中的旧值, 再写入内存层
+	t.takeDemoted(key)
+	t.l2.remove(key)
+	t.l1.Add(key, value, expirationTime)
+	t.keys.Unlock(key)
+	t.flushDemoted()
+}
+
+// Get looks up a key's value in the memory tier, then in the disk tier,
+// moving it back to the memory tier when found there.
+// Get 依次在内存层和磁盘层中查找键的值, 在磁盘层找到时将其移回内存层
+func (t *TieredCache) Get(key interface{}) (value interface{}, expirationTime int64, ok bool) {
+	if value, expirationTime, ok = t.l1.Get(key); ok {
+		return value, expirationTime, ok
+	}
+
+	t.keys.Lock(key)
+	// 等待锁期间可能已被移回内存层
+	if value, expirationTime, ok = t.l1.Get(key); ok {
+		t.keys.Unlock(key)
+		return value, expirationTime, ok
+	}
+	if value, expirationTime, ok = t.takeDemoted(key); !ok {
+		value, expirationTime, ok = t.l2.get(key, true)
+	}
+	if ok {
+		t.l1.Add(key, value, expirationTime)
+	}
+	t.keys.Unlock(key)
+	t.flushDemoted()
+	return value, expirationTime, ok
+}
+
+// Peek returns a key's value from either tier without updating the
+// recent-ness or moving it.
+// Peek 从任意一层返回键的值, 不更新缓存的状态, 也不移动条目
+func (t *TieredCache) Peek(key interface{}) (value interface{}, expirationTime int64, ok bool) {
+	if value, expirationTime, ok = t.l1.Peek(key); ok {
+		return value, expirationTime, ok
+	}
+	if value, expirationTime, ok = t.peekDemoted(key); ok {
+		return value, expirationTime, ok
+	}
+	return t.l2.get(key, false)
+}
+
+// Contains checks if a key is in either tier.
+// Contains 检查某个键是否在任意一层中
+func (t *TieredCache) Contains(key interface{}) bool {
+	if t.l1.Contains(key) {
+		return true
+	}
+	if _, _, ok := t.peekDemoted(key); ok {
+		return true
+	}
+	return t.l2.contains(key)
+}
+
+// Remove removes a key from both tiers.
+// Remove 从两层中移除提供的键
+func (t *TieredCache) Remove(key interface{}) (present bool) {
+	t.keys.Lock(key)
+	present = t.l1.Remove(key)
+	_, _, demoted := t.takeDemoted(key)
+	present = t.l2.remove(key) || demoted || present
+	t.keys.Unlock(key)
+	return present
+}
+
+// Purge clears both tiers.
+// Purge 清空两层缓存
+func (t *TieredCache) Purge() {
+	t.l1.Purge()
+	t.demotedLock.Lock()
+	t.demoted = make(map[interface{}]tieredEntry)
+	t.demotedLock.Unlock()
+	t.l2.purge()
+}
+
+// Len returns the number of entries of both tiers, the disk tier may still
+// count expired ones.
+// Len 返回两层的条目数量, 磁盘层可能包含尚未移除的过期条目
+func (t *TieredCache) Len() int {
+	return t.l1.Len() + t.l2.len()
+}
+
+// MemoryLen returns the number of entries of the memory tier.
+// MemoryLen 返回内存层的条目数量
+func (t *TieredCache) MemoryLen() int {
+	return t.l1.Len()
+}
+
+// DiskLen returns the number of entries of the disk tier.
+// DiskLen 返回磁盘层的条目数量
+func (t *TieredCache) DiskLen() int {
+	return t.l2.len()
+}
+
+// DiskSize returns the bytes used by the disk tier.
+// DiskSize 返回磁盘层占用的字节数
+func (t *TieredCache) DiskSize() int64 {
+	return t.l2.bytes()
+}
+
+// Close deletes the disk tier, the memory tier keeps working alone.
+// Close 删除磁盘层, 之后只有内存层继续工作
+func (t *TieredCache) Close() error {
+	t.l2.lock.Lock()
+	t.l2.capacity = 0
+	t.l2.lock.Unlock()
+	return t.l2.purge()
+}
+
+// demote queues an entry evicted from the memory tier for lack of
+// capacity, unless it expired, to be moved to the disk tier by
+// flushDemoted. It is called with the lock of the memory tier held.
+// demote 将内存层因容量不足淘汰的未过期条目加入队列, 由 flushDemoted 移到磁盘层。调用时持有内存层的锁
+func (t *TieredCache) demote(key, value interface{}, expirationTime int64, reason EvictionReason) {
+	if reason != ReasonCapacity {
+		return
+	}
+	e := tieredEntry{value: value, expirationTime: expirationTime}
+	if !e.live() {
+		return
+	}
+	t.demotedLock.Lock()
+	t.demoted[key] = e
+	t.demotedLock.Unlock()
+}
+
+// flushDemoted writes the queued entries to the disk tier, each under the
+// lock of its key. The lock of the memory tier must not be held.
+// flushDemoted 将队列中的条目写入磁盘层, 写入时持有各自键的锁。调用时不能持有内存层的锁
+func (t *TieredCache) flushDemoted() {
+	for {
+		var key interface{}
+		t.demotedLock.Lock()
+		n := len(t.demoted)
+		for key = range t.demoted {
+			break
+		}
+		t.demotedLock.Unlock()
+		if n == 0 {
+			return
+		}
+
+		t.keys.Lock(key)
+		// 等待锁期间可能已被写入或移除
+		if value, expirationTime, ok := t.takeDemoted(key); ok {
+			// 无法写入磁盘层的条目直接丢弃
+			_ = t.l2.put(key, value, expirationTime)
+		}
+		t.keys.Unlock(key)
+	}
+}
+
+// takeDemoted removes a queued entry and returns it.
+// takeDemoted 从队列中移除一个条目并返回
+func (t *TieredCache) takeDemoted(key interface{}) (value interface{}, expirationTime int64, ok bool) {
+	t.demotedLock.Lock()
+	e, ok := t.demoted[key]
+	delete(t.demoted, key)
+	t.demotedLock.Unlock()
+	return e.value, e.expirationTime, ok && e.live()
+}
+
+// peekDemoted returns a queued entry.
+// peekDemoted 返回队列中的一个条目
+func (t *TieredCache) peekDemoted(key interface{}) (value interface{}, expirationTime int64, ok bool) {
+	t.demotedLock.Lock()
+	e, ok := t.demoted[key]
+	t.demotedLock.Unlock()
+	return e.value, e.expirationTime, ok && e.live()
+}
+
+// live reports whether an entry has not expired yet.
+// live 判断条目是否未过期
+func (e tieredEntry) live() bool {
+	return e.expirationTime == 0 || e.expirationTime > time.Now().UnixNano()/1e6
+}
+
+// Store adds a value to the memory tier like Add, so that the cache can be
+// a level of a ChainCache.
+// Store 与 Add 一样向内存层添加一个值, 以便作为 ChainCache 的一级
+func (t *TieredCache) Store(key, value interface{}, expirationTime int64) {
+	t.Add(key, value, expirationTime)
+}
+
+// Discard removes a key from both tiers like Remove.
+// Discard 与 Remove 一样从两层中移除提供的键
+func (t *TieredCache) Discard(key interface{}) (present bool) {
+	return t.Remove(key)
+}
@@ -0,0 +1,146 @@
+package mcache
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func tieredDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "mcache-tiered")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func TestTiered(t *testing.T) {
+	c, err := NewTieredLRU(2, TieredConfig{Dir: tieredDir(t), Capacity: 1 << 20})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer c.Close()
+
+	exp := time.Now().Add(time.Hour).UnixNano() / 1e6
+	c.Add(1, "a", exp)
+	c.Add(2, "b", 0)
+	c.Add(3, "c", 0)
+	if c.MemoryLen() != 2 || c.DiskLen() != 1 || c.DiskSize() == 0 {
+		t.Fatalf("bad len: %v %v", c.MemoryLen(), c.DiskLen())
+	}
+	if !c.Contains(1) {
+		t.Fatalf("1 should be on disk")
+	}
+	if v, e, ok := c.Peek(1); !ok || v != "a" || e != exp || c.DiskLen() != 1 {
+		t.Fatalf("bad: %v %v %v", v, e, ok)
+	}
+
+	// 命中磁盘层时移回内存层, 淘汰 2
+	if v, e, ok := c.Get(1); !ok || v != "a" || e != exp {
+		t.Fatalf("bad: %v %v %v", v, e, ok)
+	}
+	if c.MemoryLen() != 2 || c.DiskLen() != 1 || c.Len() != 3 {
+		t.Fatalf("bad len: %v %v", c.MemoryLen(), c.DiskLen())
+	}
+	if v, _, ok := c.Get(2); !ok || v != "b" {
+		t.Fatalf("bad: %v", v)
+	}
+
+	// 新值替换磁盘层中的旧值
+	c.Add(3, "c2", 0)
+	c.Add(4, "d", 0)
+	if v, _, _ := c.Get(3); v != "c2" {
+		t.Fatalf("bad: %v", v)
+	}
+
+	if !c.Remove(4) || c.Contains(4) {
+		t.Fatalf("4 should be removed")
+	}
+	c.Purge()
+	if c.Len() != 0 || c.DiskSize() != 0 {
+		t.Fatalf("bad len: %v", c.Len())
+	}
+}
+
+func TestTiered_Expire(t *testing.T) {
+	c, _ := NewTieredLFU(1, TieredConfig{Dir: tieredDir(t), Capacity: 1 << 20})
+	defer c.Close()
+
+	c.Add(1, 1, time.Now().UnixNano()/1e6+20)
+	c.Add(2, 2, 0)
+	c.Add(3, 3, time.Now().UnixNano()/1e6-1)
+	c.Add(4, 4, 0)
+	if c.DiskLen() != 2 {
+		t.Fatalf("bad len: %v", c.DiskLen())
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, _, ok := c.Get(1); ok || c.Contains(1) {
+		t.Fatalf("1 should be expired")
+	}
+	if c.DiskLen() != 1 {
+		t.Fatalf("bad len: %v", c.DiskLen())
+	}
+}
+
+func TestTiered_Capacity(t *testing.T) {
+	c, _ := NewTieredLRU(1, TieredConfig{Dir: tieredDir(t), Capacity: 4096, SegmentSize: 1024})
+	defer c.Close()
+
+	value := make([]byte, 100)
+	for i := 0; i < 200; i++ {
+		c.Add(i, value, 0)
+	}
+	if c.DiskSize() > 4096 {
+		t.Fatalf("bad size: %v", c.DiskSize())
+	}
+	// 最旧的条目随分段一起删除, 最新的仍在磁盘层
+	if c.Contains(0) || !c.Contains(198) {
+		t.Fatalf("bad contents")
+	}
+	files, _ := ioutil.ReadDir(c.l2.dir)
+	if len(files) > 5 {
+		t.Fatalf("bad segments: %v", len(files))
+	}
+
+	c.Add("big", make([]byte, 8192), 0)
+	c.Add("next", 1, 0)
+	if c.Contains("big") {
+		t.Fatalf("big should be dropped")
+	}
+}
+
+func TestTiered_Concurrent(t *testing.T) {
+	c, err := NewTieredLFU(8, TieredConfig{Dir: tieredDir(t), Capacity: 1 << 20})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				key := (g*200 + i) % 64
+				c.Add(key, key, 0)
+				if v, _, ok := c.Get((key + 7) % 64); ok && v != (key+7)%64 {
+					t.Errorf("bad: %v", v)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	// 所有淘汰的条目都已写入磁盘层
+	if c.Len() != 64 || c.MemoryLen() != 8 {
+		t.Fatalf("bad len: %v %v", c.MemoryLen(), c.DiskLen())
+	}
+	c.Remove(3)
+	if c.Contains(3) {
+		t.Fatalf("3 should be removed")
+	}
+}
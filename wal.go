@@ -0,0 +1,601 @@
+package mcache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultWALSyncInterval is the default interval between fsyncs with
+// WALSyncInterval.
+// DefaultWALSyncInterval WALSyncInterval 策略默认的 fsync 间隔
+const DefaultWALSyncInterval = time.Second
+
+// WALSyncPolicy tells when the log is flushed to stable storage.
+// WALSyncPolicy 日志刷入磁盘的策略
+type WALSyncPolicy int
+
+const (
+	// WALSyncAlways fsyncs after every record, nothing acknowledged is lost.
+	// WALSyncAlways 每条记录后执行 fsync, 不会丢失已写入的操作
+	WALSyncAlways WALSyncPolicy = iota
+	// WALSyncInterval fsyncs every SyncInterval, a crash loses at most the
+	// records of the last interval.
+	// WALSyncInterval 每隔 SyncInterval 执行一次 fsync, 崩溃时最多丢失最后一个间隔内的记录
+	WALSyncInterval
+	// WALSyncNever leaves flushing to the operating system.
+	// WALSyncNever 由操作系统决定何时刷盘
+	WALSyncNever
+)
+
+// WALConfig holds the parameters of a WAL.
+// WALConfig WAL 的配置参数
+type WALConfig struct {
+	// Sync 刷盘策略, 默认为 WALSyncAlways
+	Sync WALSyncPolicy
+	// SyncInterval WALSyncInterval 策略的 fsync 间隔
+	SyncInterval time.Duration
+	// Codec 值的编码, 为 nil 时已有日志使用其记录的编码, 新日志使用 gob
+	Codec Codec
+}
+
+// WALCache is implemented by the caches a WAL can be attached to, every
+// cache of the package.
+// WALCache 可以关联 WAL 的缓存, 即包内的所有缓存
+type WALCache interface {
+	SaveToWithCodec(w io.Writer, codec Codec) error
+	LoadFromWithCodec(r io.Reader, codec Codec) error
+
+	setWAL(w *WAL)
+	replayWAL(r walRecord)
+}
+
+// Write-ahead log format: the header "MCWAL", a format version byte and the
+// codec name, then records of a length, the record and its CRC-32 (IEEE),
+// 4 bytes big endian. A record is the operation followed, for an add, by
+// the entry as in a snapshot, or for a remove by the key (gob).
+// 预写日志格式: 头部为 "MCWAL", 格式版本及编码名称; 之后每条记录依次为长度, 记录内容及其 CRC-32(4 字节大端)。
+// 记录内容为操作类型, 写入时后跟与快照中相同格式的条目, 移除时后跟键(gob)
+const (
+	walMagic   = "MCWAL"
+	walVersion = 1
+)
+
+// 日志中的操作
+const (
+	walAdd byte = iota + 1
+	walRemove
+	walPurge
+)
+
+// ErrWALClosed is returned by the methods of a closed WAL.
+// ErrWALClosed WAL 已关闭
+var ErrWALClosed = errors.New("mcache: wal closed")
+
+// walRecord is an operation of the log, with the entry of a key after an
+// add, or only its key for a remove.
+// walRecord 日志中的一个操作, 写入时包含写入后键的条目, 移除时只包含键
+type walRecord struct {
+	op byte
+	snapshotRecord
+}
+
+// WAL is a write-ahead log of the writes of a cache, replayed by OpenWAL to
+// rebuild the cache after a restart. Every write records the resulting
+// entry of its key, with its value, expiration time and metadata, or its
+// removal. Reads are not recorded and the eviction order is not kept: the
+// expiration times extended by Get in sliding mode restart from the last
+// write, every replayed entry counts as just added, even one written by
+// Expire or CompareAndSwap, and a full cache may evict other entries while
+// replaying.
+// WAL 记录缓存写操作的预写日志, 重启后由 OpenWAL 回放以重建缓存。每次写操作记录其键写入后的条目
+// (包括值, 过期时间及元数据)或其移除。读操作不会被记录, 也不保留淘汰顺序: 滑动过期模式下 Get 延长的
+// 过期时间在回放后从最后一次写入开始计算; 回放的条目都视为刚刚添加, 即使由 Expire 或 CompareAndSwap 写入;
+// 缓存已满时回放可能淘汰不同的条目
+type WAL struct {
+	path   string
+	cache  WALCache
+	codec  Codec
+	config WALConfig
+
+	file  *os.File
+	size  int64
+	dirty bool
+	err   error
+
+	stop    chan struct{}
+	done    chan struct{}
+	lock    sync.Mutex
+	compact sync.Mutex
+}
+
+// OpenWAL rebuilds the cache from the snapshot path.snap and the log at
+// path, then attaches the log to the cache, so later writes are recorded.
+// Expired entries are not restored. A log with a torn last record, e.g.
+// after a crash, is truncated to its last complete record.
+// OpenWAL 根据快照 path.snap 和 path 处的日志重建缓存, 之后将日志关联到缓存以记录后续的写操作。
+// 已过期的条目不会恢复。最后一条记录不完整(例如崩溃后)的日志会被截断到最后一条完整的记录
+func OpenWAL(path string, cache WALCache, config WALConfig) (*WAL, error) {
+	if config.Sync == WALSyncInterval && config.SyncInterval <= 0 {
+		config.SyncInterval = DefaultWALSyncInterval
+	}
+	w := &WAL{
+		path:   path,
+		cache:  cache,
+		codec:  config.Codec,
+		config: config,
+	}
+	if err := w.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	// 压缩中途崩溃时, 旧日志中的操作可能还不在快照中
+	if _, err := w.replay(w.oldPath(), false); err != nil {
+		return nil, err
+	}
+	size, err := w.replay(path, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.open(size); err != nil {
+		return nil, err
+	}
+
+	if config.Sync == WALSyncInterval {
+		w.stop = make(chan struct{})
+		w.done = make(chan struct{})
+		go w.run(w.stop, w.done)
+	}
+	cache.setWAL(w)
+	return w, nil
+}
+
+// Compact saves the cache in the snapshot and starts a new empty log.
+// Compact 将缓存保存到快照中并开始一个新的空日志
+func (w *WAL) Compact() error {
+	w.compact.Lock()
+	defer w.compact.Unlock()
+
+	// 先切换日志再保存快照: 切换后的操作会同时出现在快照和新日志中, 回放时重复执行结果不变
+	w.lock.Lock()
+	if w.file == nil {
+		w.lock.Unlock()
+		return ErrWALClosed
+	}
+	err := w.rotate()
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+	w.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp := w.snapshotPath() + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	err = w.cache.SaveToWithCodec(bw, w.codec)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, w.snapshotPath())
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(w.oldPath())
+}
+
+// Sync flushes the log to stable storage.
+// Sync 将日志刷入磁盘
+func (w *WAL) Sync() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.file == nil {
+		return ErrWALClosed
+	}
+	return w.sync()
+}
+
+// Err returns the first error writing the log, the writes of the cache
+// itself never fail.
+// Err 返回写日志时遇到的第一个错误, 缓存本身的写操作不会因此失败
+func (w *WAL) Err() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.err
+}
+
+// Size returns the size of the log in bytes.
+// Size 返回日志的字节数
+func (w *WAL) Size() int64 {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.size
+}
+
+// Close detaches the log from the cache, flushes and closes it.
+// Close 取消日志与缓存的关联, 刷盘并关闭日志
+func (w *WAL) Close() error {
+	w.cache.setWAL(nil)
+
+	w.lock.Lock()
+	stop, done := w.stop, w.done
+	w.stop, w.done = nil, nil
+	w.lock.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.file == nil {
+		return ErrWALClosed
+	}
+	err := w.file.Sync()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	w.file = nil
+	return err
+}
+
+// add records the entry of a key after a write, a nil WAL records nothing.
+// add 记录写操作后键的条目, WAL 为 nil 时不记录
+func (w *WAL) add(rec snapshotRecord) {
+	if w != nil {
+		w.append(walRecord{op: walAdd, snapshotRecord: rec})
+	}
+}
+
+// remove records the removal of a key.
+// remove 记录一个键的移除
+func (w *WAL) remove(key interface{}) {
+	if w != nil {
+		w.append(walRecord{op: walRemove, snapshotRecord: snapshotRecord{entry: Entry{Key: key}}})
+	}
+}
+
+// purge records a Purge.
+// purge 记录一次 Purge
+func (w *WAL) purge() {
+	if w != nil {
+		w.append(walRecord{op: walPurge})
+	}
+}
+
+// append writes a record to the log, keeping the first error.
+// append 将一条记录写入日志, 保留第一个错误
+func (w *WAL) append(r walRecord) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.file == nil || w.err != nil {
+		return
+	}
+	data, err := encodeWALRecord(r, w.codec)
+	if err != nil {
+		w.err = err
+		return
+	}
+	n, err := w.file.Write(data)
+	w.size += int64(n)
+	w.dirty = true
+	if err == nil && w.config.Sync == WALSyncAlways {
+		err = w.sync()
+	}
+	if err != nil {
+		w.err = err
+	}
+}
+
+// sync fsyncs the log if it was written since the last sync.
+// sync 如果上次 fsync 后有写入, 则执行 fsync
+func (w *WAL) sync() error {
+	if !w.dirty {
+		return nil
+	}
+	w.dirty = false
+	return w.file.Sync()
+}
+
+// run fsyncs the log every SyncInterval until stop is closed.
+// run 每隔 SyncInterval 执行一次 fsync, 直到 stop 被关闭
+func (w *WAL) run(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(w.config.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			w.lock.Lock()
+			if w.file != nil {
+				if err := w.sync(); err != nil && w.err == nil {
+					w.err = err
+				}
+			}
+			w.lock.Unlock()
+		}
+	}
+}
+
+func (w *WAL) snapshotPath() string {
+	return w.path + ".snap"
+}
+
+func (w *WAL) oldPath() string {
+	return w.path + ".old"
+}
+
+// loadSnapshot loads the snapshot into the cache, if there is one.
+// loadSnapshot 如果存在快照, 将其加载到缓存
+func (w *WAL) loadSnapshot() error {
+	f, err := os.Open(w.snapshotPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := w.cache.LoadFromWithCodec(f, w.codec); err != nil {
+		return fmt.Errorf("mcache: load wal snapshot: %v", err)
+	}
+	return nil
+}
+
+// replay applies the records of a log to the cache and returns the size
+// of its valid part. A torn tail is an error unless tolerated.
+// replay 将日志中的记录应用到缓存, 返回其中有效部分的大小。除非允许, 否则最后一条记录不完整时返回错误
+func (w *WAL) replay(path string, tolerateTail bool) (int64, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	name, size, err := readWALHeader(br)
+	if err != nil {
+		if tolerateTail && err == io.EOF {
+			// 创建后还未写完头部
+			return 0, nil
+		}
+		return 0, err
+	}
+	codec, err := codecFor(name, w.codec)
+	if err != nil {
+		return 0, err
+	}
+	w.codec = codec
+
+	now := time.Now().UnixNano() / 1e6
+	for {
+		r, n, err := readWALRecord(br, codec)
+		if err == io.EOF {
+			return size, nil
+		}
+		if err == ErrSnapshotCorrupt && tolerateTail {
+			return size, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		size += n
+		if r.op == walAdd && !r.live(now) {
+			// 已过期的值不再恢复, 但要覆盖之前的值
+			r.op = walRemove
+		}
+		w.cache.replayWAL(r)
+	}
+}
+
+// open opens the log for appending, truncated to its valid size, writing
+// the header of a new log.
+// open 以追加方式打开日志并截断到有效大小, 新日志写入头部
+func (w *WAL) open(size int64) error {
+	f, err := os.OpenFile(w.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Seek(size, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+	if size == 0 {
+		if w.codec == nil {
+			w.codec = GobCodec{}
+		}
+		header := walHeader(w.codec)
+		if _, err := f.Write(header); err != nil {
+			f.Close()
+			return err
+		}
+		size = int64(len(header))
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	w.file = f
+	w.size = size
+	return nil
+}
+
+// rotate moves the log aside and starts a new one. The records are
+// appended to the log moved aside by a failed compaction, if any.
+// rotate 将当前日志移到一旁并开始一个新日志。如果之前的压缩失败留下了旧日志, 则将记录追加到旧日志中
+func (w *WAL) rotate() error {
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	old, err := os.OpenFile(w.oldPath(), os.O_WRONLY|os.O_APPEND, 0)
+	if os.IsNotExist(err) {
+		if err := w.file.Close(); err != nil {
+			return err
+		}
+		w.file = nil
+		if err := os.Rename(w.path, w.oldPath()); err != nil {
+			return err
+		}
+		return w.open(0)
+	}
+	if err != nil {
+		return err
+	}
+	defer old.Close()
+	if _, err := w.file.Seek(int64(len(walHeader(w.codec))), io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.Copy(old, w.file); err != nil {
+		return err
+	}
+	if err := old.Sync(); err != nil {
+		return err
+	}
+	if err := w.file.Truncate(0); err != nil {
+		return err
+	}
+	w.file.Close()
+	w.file = nil
+	return w.open(0)
+}
+
+// walHeader returns the header of a log written with the codec.
+// walHeader 返回使用该编码的日志头部
+func walHeader(codec Codec) []byte {
+	var buf bytes.Buffer
+	sw := &snapshotWriter{w: &buf}
+	sw.raw([]byte(walMagic))
+	sw.raw([]byte{walVersion})
+	sw.bytes([]byte(codec.Name()))
+	return buf.Bytes()
+}
+
+// readWALHeader reads the header of a log, returning the codec name and
+// the size of the header.
+// readWALHeader 读取日志头部, 返回编码名称及头部大小
+func readWALHeader(br *bufio.Reader) (name string, size int64, err error) {
+	magic := make([]byte, len(walMagic)+1)
+	if _, err := io.ReadFull(br, magic); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return "", 0, err
+	}
+	if string(magic[:len(walMagic)]) != walMagic {
+		return "", 0, errors.New("mcache: not a wal")
+	}
+	if magic[len(walMagic)] != walVersion {
+		return "", 0, fmt.Errorf("mcache: unsupported wal version %d", magic[len(walMagic)])
+	}
+	n, err := binary.ReadUvarint(br)
+	if err != nil || n > maxSnapshotField {
+		return "", 0, io.EOF
+	}
+	p, err := readChunks(br, int(n))
+	if err != nil {
+		return "", 0, io.EOF
+	}
+	size = int64(len(magic)) + int64(uvarintLen(n)) + int64(n)
+	return string(p), size, nil
+}
+
+// encodeWALRecord returns a framed record.
+// encodeWALRecord 返回带长度和校验和的记录
+func encodeWALRecord(r walRecord, codec Codec) ([]byte, error) {
+	var body bytes.Buffer
+	sw := &snapshotWriter{w: &body}
+	sw.raw([]byte{r.op})
+	switch r.op {
+	case walAdd:
+		if err := sw.record(r.snapshotRecord, codec); err != nil {
+			return nil, err
+		}
+	case walRemove:
+		key, err := GobCodec{}.Marshal(r.entry.Key)
+		if err != nil {
+			return nil, fmt.Errorf("mcache: encode key %v: %v", r.entry.Key, err)
+		}
+		sw.bytes(key)
+	}
+
+	var buf bytes.Buffer
+	fw := &snapshotWriter{w: &buf}
+	fw.bytes(body.Bytes())
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc32.ChecksumIEEE(body.Bytes()))
+	fw.raw(sum[:])
+	return buf.Bytes(), nil
+}
+
+// readWALRecord reads a framed record, returning its size. io.EOF means
+// the log ended on a record boundary.
+// readWALRecord 读取一条带长度和校验和的记录, 返回其大小。io.EOF 表示日志在记录边界处结束
+func readWALRecord(br *bufio.Reader, codec Codec) (r walRecord, size int64, err error) {
+	n, err := binary.ReadUvarint(br)
+	if err == io.EOF {
+		return r, 0, io.EOF
+	}
+	if err != nil || n > maxSnapshotField {
+		return r, 0, ErrSnapshotCorrupt
+	}
+	body, err := readChunks(br, int(n)+4)
+	if err != nil {
+		return r, 0, ErrSnapshotCorrupt
+	}
+	if binary.BigEndian.Uint32(body[n:]) != crc32.ChecksumIEEE(body[:n]) {
+		return r, 0, ErrSnapshotCorrupt
+	}
+	size = int64(uvarintLen(n)) + int64(n) + 4
+
+	sr := &snapshotReader{r: bufio.NewReader(bytes.NewReader(body[:n])), crc: crc32.NewIEEE()}
+	r.op = sr.raw(1)[0]
+	switch r.op {
+	case walAdd:
+		if r.snapshotRecord, err = sr.record(sr.raw(1)[0], codec); err != nil {
+			return r, 0, err
+		}
+	case walRemove:
+		if r.entry.Key, err = (GobCodec{}).Unmarshal(sr.bytes()); err != nil && sr.err == nil {
+			return r, 0, fmt.Errorf("mcache: decode key: %v", err)
+		}
+	}
+	if sr.err != nil {
+		return r, 0, ErrSnapshotCorrupt
+	}
+	return r, size, nil
+}
+
+// uvarintLen returns the encoded size of a uvarint.
+// uvarintLen 返回 uvarint 编码后的字节数
+func uvarintLen(v uint64) int {
+	var buf [binary.MaxVarintLen64]byte
+	return binary.PutUvarint(buf[:], v)
+}
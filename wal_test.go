@@ -0,0 +1,237 @@
+package mcache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func walPath(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "mcache-wal")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "cache.wal")
+}
+
+func TestWAL(t *testing.T) {
+	path := walPath(t)
+	l, _ := NewLRU(8)
+	w, err := OpenWAL(path, l, WALConfig{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	exp := time.Now().Add(time.Hour).UnixNano() / 1e6
+	l.Add("purged", 0, 0)
+	l.Purge()
+	l.Add("a", 1, 0)
+	l.Add("b", "b", 0)
+	l.Add("c", 3, 0)
+	l.Add("short", 4, time.Now().UnixNano()/1e6+20)
+	l.Remove("b")
+	l.Expire("a", exp)
+	l.Add("d", 5, exp)
+	l.Persist("d")
+	if err := w.Close(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	// 关闭后不再记录
+	l.Add("closed", 6, 0)
+	time.Sleep(30 * time.Millisecond)
+
+	r, _ := NewLRU(8)
+	w, err = OpenWAL(path, r, WALConfig{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer w.Close()
+	// Expire 写入的条目回放时视为刚刚添加
+	if keys := r.Keys(); !reflect.DeepEqual(keys, []interface{}{"c", "a", "d"}) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+	if _, e, _ := r.Peek("a"); e != exp {
+		t.Fatalf("bad expiration: %v", e)
+	}
+	if _, e, _ := r.Peek("d"); e != 0 {
+		t.Fatalf("bad expiration: %v", e)
+	}
+}
+
+func TestWAL_Writes(t *testing.T) {
+	path := walPath(t)
+	l, _ := NewLRU(8)
+	l.SetJitter(Jitter{Percent: 50})
+	w, err := OpenWAL(path, l, WALConfig{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	exp := time.Now().Add(time.Hour).UnixNano() / 1e6
+	l.Add("oldest", 0, 0)
+	l.AddWithCost("cost", 1, 1, exp)
+	l.Incr("counter", 2, 0)
+	l.ContainsOrAdd("contains", 3, 0)
+	l.PeekOrAdd("peek", 4, 0)
+	l.Update("update", func(interface{}, bool) (interface{}, bool) { return 5, true })
+	l.Compute("compute", exp, func(interface{}, bool) (interface{}, bool) { return 6, true })
+	l.ComputeIfAbsent("absent", 0, func() (interface{}, bool) { return 7, true })
+	_, _, version, _ := l.GetWithVersion("update")
+	l.CompareAndSwap("update", version, 8)
+	_, _, version, _ = l.GetWithVersion("absent")
+	l.CompareAndDelete("absent", version)
+	l.AddSliding("sliding", 9, time.Hour)
+	l.Touch("cost")
+	l.RemoveOldest()
+	w.Close()
+
+	r, _ := NewLRU(8)
+	r.SetJitter(Jitter{Percent: 50})
+	w, err = OpenWAL(path, r, WALConfig{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer w.Close()
+	if r.Len() != 7 || r.Contains("oldest") || r.Contains("absent") {
+		t.Fatalf("bad keys: %v", r.Keys())
+	}
+	for _, key := range l.Keys() {
+		// 过期时间与写入时相同, 没有再次随机调整
+		want, _ := l.PeekEntry(key)
+		e, ok := r.PeekEntry(key)
+		if !ok || e.Value != want.Value || e.ExpirationTime != want.ExpirationTime || e.Sliding != want.Sliding {
+			t.Fatalf("bad entry: %v != %v", e, want)
+		}
+	}
+}
+
+func TestWAL_Compact(t *testing.T) {
+	path := walPath(t)
+	l, _ := NewHashLRU(64, 4)
+	w, _ := OpenWAL(path, l, WALConfig{Sync: WALSyncNever})
+	for i := 0; i < 32; i++ {
+		l.Add(i, i, 0)
+		l.Add(i, i*2, 0)
+	}
+	size := w.Size()
+	if err := w.Compact(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if w.Size() >= size {
+		t.Fatalf("log not compacted: %v >= %v", w.Size(), size)
+	}
+	l.Remove(0)
+	l.Add(100, 100, 0)
+	w.Close()
+
+	r, _ := NewHashLRU(64, 4)
+	w, err := OpenWAL(path, r, WALConfig{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer w.Close()
+	if r.Len() != 32 || r.Contains(0) {
+		t.Fatalf("bad len: %v", r.Len())
+	}
+	if v, _, ok := r.Peek(31); !ok || v != 62 {
+		t.Fatalf("bad: %v", v)
+	}
+}
+
+func TestWAL_Crash(t *testing.T) {
+	path := walPath(t)
+	l, _ := NewLFU(8)
+	w, _ := OpenWAL(path, l, WALConfig{Sync: WALSyncInterval, SyncInterval: time.Millisecond})
+	l.Add(1, 1, 0)
+
+	// 压缩中途崩溃: 日志已切换但快照未写入
+	w.lock.Lock()
+	if err := w.rotate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	w.lock.Unlock()
+	l.Add(2, 2, 0)
+	w.lock.Lock()
+	if err := w.rotate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	w.lock.Unlock()
+	l.Add(3, 3, 0)
+	time.Sleep(5 * time.Millisecond)
+	w.Close()
+
+	// 最后一条记录不完整
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	f.Write([]byte{40, 1, 2})
+	f.Close()
+
+	r, _ := NewLFU(8)
+	w, err := OpenWAL(path, r, WALConfig{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if r.Len() != 3 {
+		t.Fatalf("bad len: %v", r.Len())
+	}
+	r.Add(4, 4, 0)
+	if err := w.Compact(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := os.Stat(path + ".old"); !os.IsNotExist(err) {
+		t.Fatalf("old log not removed: %v", err)
+	}
+	w.Close()
+
+	r, _ = NewLFU(8)
+	w, err = OpenWAL(path, r, WALConfig{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer w.Close()
+	if r.Len() != 4 || w.Err() != nil {
+		t.Fatalf("bad len: %v", r.Len())
+	}
+}
+
+func TestWAL_Caches(t *testing.T) {
+	gdsf, _ := NewGDSF(8)
+	hashLfu, _ := NewHashLFU(8, 2)
+	arc, _ := NewARC(8)
+	twoQueue, _ := New2Q(8)
+	caches := map[string]WALCache{"gdsf": gdsf, "hashlfu": hashLfu, "arc": arc, "2q": twoQueue}
+	for name, c := range caches {
+		path := walPath(t)
+		w, err := OpenWAL(path, c, WALConfig{Codec: JSONCodec{}})
+		if err != nil {
+			t.Fatalf("%s: err: %v", name, err)
+		}
+		w.add(snapshotRecord{entry: Entry{Key: "a", Value: "1"}})
+		w.add(snapshotRecord{list: listSecond, entry: Entry{Key: "b", Value: "2"}})
+		w.remove("a")
+		w.Close()
+
+		var r WALCache
+		switch name {
+		case "gdsf":
+			r, _ = NewGDSF(8)
+		case "hashlfu":
+			r, _ = NewHashLFU(8, 2)
+		case "arc":
+			r, _ = NewARC(8)
+		case "2q":
+			r, _ = New2Q(8)
+		}
+		w, err = OpenWAL(path, r, WALConfig{})
+		if err != nil {
+			t.Fatalf("%s: err: %v", name, err)
+		}
+		if v, _, ok := r.(interface {
+			Peek(key interface{}) (interface{}, int64, bool)
+		}).Peek("b"); !ok || v != "2" {
+			t.Fatalf("%s: bad: %v", name, v)
+		}
+		w.Close()
+	}
+}